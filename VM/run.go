@@ -3,48 +3,112 @@ package VM
 import (
 	"bufio"
 	"fmt"
+	"golox/lox/ast"
+	"golox/lox/checker"
+	"golox/lox/coverage"
 	"golox/lox/interpreter"
 	"golox/lox/lexer"
+	"golox/lox/optimizer"
 	"golox/lox/parser"
+	"golox/lox/profiler"
 	"golox/lox/resolver"
+	"golox/lox/trace"
 	"golox/utils"
+	"io"
 	"os"
+	"strings"
 )
 
+// VM runs Lox source through the lexer, parser, resolver, type checker and interpreter. Its standard streams default to the
+// process' ones and can be replaced per VM, e.g. to capture the output of a script.
 type VM struct {
-	hadError        bool
-	hadRuntimeError bool
-	vmLexer         *lexer.Lexer
-	vmParser        *parser.Parser
-	vmResolver      *resolver.Resolver
-	vmInterpreter   *interpreter.Interpreter
+	hadError          bool
+	hadRuntimeError   bool
+	constantFunctions bool
+	optimize          bool
+	errorCodes        bool
+	strict            bool
+	stepLimit         int64
+	profiler          *profiler.Profiler
+	coverage          *coverage.File
+	tracer            *trace.Tracer
+	hooks             []interpreter.Hooks
+	stdin             *bufio.Reader // shared by the prompt and the programs it runs, so neither loses buffered input
+	stdout            io.Writer
+	stderr            io.Writer
+	vmLexer           *lexer.Lexer
+	vmParser          *parser.Parser
+	vmResolver        *resolver.Resolver
+	vmInterpreter     *interpreter.Interpreter
+	reporter          *utils.Reporter // the reporter of the last program, see Diagnostics
 }
 
+// RunFile runs the script at path and exits with its status if it failed.
 func (v *VM) RunFile(path string) {
-	fileBytes, _ := os.ReadFile(path)
-	v.run(string(fileBytes[:]))
 	// Indicate an error in the exit code.
-	if v.hadError {
-		os.Exit(65)
+	if code := v.RunPath(path); code != 0 {
+		os.Exit(code)
 	}
-	if v.hadRuntimeError {
-		os.Exit(70)
+}
+
+// RunPath runs the script at path and returns its exit status like RunStr. A path ending in .json holds a program
+// encoded with ast.EncodeJSON rather than source.
+func (v *VM) RunPath(path string) int {
+	fileBytes, _ := os.ReadFile(path)
+	if strings.HasSuffix(path, ".json") {
+		statements, err := ast.DecodeJSON(fileBytes)
+		if err != nil {
+			_, _ = fmt.Fprintln(v.errorOutput(), path+": "+err.Error())
+			return 65
+		}
+		return v.RunAST(statements)
 	}
+	return v.RunStr(string(fileBytes[:]))
 }
 
-func (v *VM) RunStr(code string) {
+// RunStr runs code and returns the exit status a script with this content has: 0 on success, 65 after a compile
+// error and 70 after a runtime error.
+func (v *VM) RunStr(code string) int {
+	v.hadError = false
+	v.hadRuntimeError = false
 	v.run(code)
-	// Indicate an error in the exit code.
+	return v.exitCode()
+}
+
+// RunAST runs a program which is already parsed, e.g. one decoded with ast.DecodeJSON, and returns its exit status
+// like RunStr.
+func (v *VM) RunAST(statements []ast.Stmt) int {
+	v.hadError = false
+	v.hadRuntimeError = false
+	v.execute(statements, v.newReporter())
+	return v.exitCode()
+}
+
+func (v *VM) exitCode() int {
 	if v.hadError {
-		os.Exit(65)
+		return 65
+	}
+	if v.hadRuntimeError {
+		return 70
 	}
+	return 0
+}
+
+// RunTestsStr runs code like RunStr and then the test blocks it declares. There are no test results when the
+// program itself fails.
+func (v *VM) RunTestsStr(code string) (int, []interpreter.TestResult) {
+	exitCode := v.RunStr(code)
+	if exitCode != 0 {
+		return exitCode, nil
+	}
+	return exitCode, v.vmInterpreter.RunTests()
 }
 
 func (v *VM) RunPrompt() {
 	var line string
-	reader := bufio.NewReader(os.Stdin)
+	reader := v.input()
 	for true {
-		fmt.Print("> ")
+		_, _ = fmt.Fprint(v.output(), "> ")
 		lineBytes, _, err := reader.ReadLine()
 		if err != nil {
 			break
@@ -60,34 +124,79 @@ func (v *VM) RunPrompt() {
 }
 
 func (v *VM) run(source string) {
+	reporter := v.newReporter()
+	statements := v.parse(source, reporter)
+	if v.hadError {
+		return
+	}
+	v.execute(statements, reporter)
+}
+
+// ParseStr parses code without running it. Syntax errors are reported like RunStr does and make ok false.
+func (v *VM) ParseStr(code string) (statements []ast.Stmt, ok bool) {
+	v.hadError = false
+	statements = v.parse(code, v.newReporter())
+	return statements, !v.hadError
+}
+
+func (v *VM) parse(source string, reporter *utils.Reporter) []ast.Stmt {
 	v.vmLexer = lexer.NewLexer(source)
+	v.vmLexer.SetReporter(reporter)
 	tokens, lexerError := v.vmLexer.ScanTokens()
 	if lexerError.HasError {
-		utils.Report(lexerError.Line, "Lexing", lexerError.Reason)
 		v.hadError = true
 	}
 
 	v.vmParser = parser.NewParser(tokens)
+	v.vmParser.SetReporter(reporter)
+	v.vmParser.SetSource(source)
 	statements, parseError := v.vmParser.Parse()
 
 	if parseError.HasError {
 		v.hadError = true
 	}
+	return statements
+}
 
+// CompileStr parses, resolves and type checks code, and optimizes it if the VM is set to, without running it. Errors
+// are reported like RunStr does and make ok false.
+func (v *VM) CompileStr(code string) (statements []ast.Stmt, ok bool) {
+	v.hadError = false
+	reporter := v.newReporter()
+	statements = v.parse(code, reporter)
 	if v.hadError {
-		return
+		return nil, false
 	}
+	statements = v.compile(statements, interpreter.NewInterpreter(), reporter)
+	return statements, !v.hadError
+}
 
+// execute compiles statements, reporting compile errors to reporter, and interprets them.
+func (v *VM) execute(statements []ast.Stmt, reporter *utils.Reporter) {
 	v.vmInterpreter = interpreter.NewInterpreter()
-	v.vmResolver = resolver.NewResolver(v.vmInterpreter)
-
-	_, err := v.vmResolver.Resolve(statements)
-	if err != nil {
-		v.hadError = true
-	}
+	v.vmInterpreter.SetConstantFunctions(v.constantFunctions)
+	v.vmInterpreter.SetStepLimit(v.stepLimit)
+	v.vmInterpreter.SetStdin(v.input())
+	v.vmInterpreter.SetStdout(v.output())
+	v.vmInterpreter.SetStderr(v.errorOutput())
+	v.vmInterpreter.SetReporter(reporter)
+	statements = v.compile(statements, v.vmInterpreter, reporter)
 	if v.hadError {
 		return
 	}
+	if v.profiler != nil {
+		v.vmInterpreter.AddHooks(v.profiler.Hooks())
+	}
+	if v.coverage != nil {
+		v.coverage.Add(statements)
+		v.vmInterpreter.AddHooks(v.coverage.Hooks())
+	}
+	if v.tracer != nil {
+		v.vmInterpreter.AddHooks(v.tracer.Hooks())
+	}
+	for _, hooks := range v.hooks {
+		v.vmInterpreter.AddHooks(hooks)
+	}
 
 	runtimeError := v.vmInterpreter.Interpret(statements)
 
@@ -97,6 +206,130 @@ func (v *VM) run(source string) {
 
 }
 
+// compile resolves statements for target, type checks them and optimizes them if the VM is set to.
+func (v *VM) compile(statements []ast.Stmt, target *interpreter.Interpreter, reporter *utils.Reporter) []ast.Stmt {
+	v.vmResolver = resolver.NewResolver(target)
+	v.vmResolver.SetConstantFunctions(v.constantFunctions)
+	v.vmResolver.SetStrict(v.strict)
+	v.vmResolver.SetReporter(reporter)
+
+	_, err := v.vmResolver.Resolve(statements)
+	if err != nil {
+		v.hadError = true
+	}
+	if v.hadError {
+		return statements
+	}
+	typeChecker := checker.NewChecker()
+	typeChecker.SetReporter(reporter)
+	if typeChecker.Check(statements) != nil {
+		v.hadError = true
+		return statements
+	}
+	if v.optimize {
+		statements = optimizer.Optimize(statements)
+	}
+	return statements
+}
+
+// newReporter returns the reporter of a new program, reporting to the VM's standard error.
+func (v *VM) newReporter() *utils.Reporter {
+	v.reporter = utils.NewReporter(v.errorOutput())
+	v.reporter.SetCodes(v.errorCodes)
+	return v.reporter
+}
+
+// Diagnostics returns the compile and runtime errors of the last program the VM parsed, compiled or ran, with the
+// fixes suggested for them, for tools like editors which need them structured.
+func (v *VM) Diagnostics() []utils.Diagnostic {
+	if v.reporter == nil {
+		return nil
+	}
+	return v.reporter.Diagnostics()
+}
+
 func (v *VM) SetError(error bool) {
 	v.hadError = error
 }
+
+// SetConstantFunctions makes function declarations immutable in the programs run by this VM.
+func (v *VM) SetConstantFunctions(constant bool) {
+	v.constantFunctions = constant
+}
+
+// SetErrorCodes shows the catalog code of each error reported, see Reporter.SetCodes.
+func (v *VM) SetErrorCodes(errorCodes bool) {
+	v.errorCodes = errorCodes
+}
+
+// SetStrict rejects references to undeclared globals when compiling, see Resolver.SetStrict.
+func (v *VM) SetStrict(strict bool) {
+	v.strict = strict
+}
+
+// SetOptimize runs the optimizer on programs between resolving and interpreting them.
+func (v *VM) SetOptimize(optimize bool) {
+	v.optimize = optimize
+}
+
+// SetStepLimit bounds the number of statements a program may execute, see Interpreter.SetStepLimit.
+func (v *VM) SetStepLimit(limit int64) {
+	v.stepLimit = limit
+}
+
+// SetProfiler reports the statements and calls of the programs run by this VM to p.
+func (v *VM) SetProfiler(p *profiler.Profiler) {
+	v.profiler = p
+}
+
+// SetCoverage measures the coverage of the programs run by this VM into file, see package coverage.
+func (v *VM) SetCoverage(file *coverage.File) {
+	v.coverage = file
+}
+
+// SetTracer logs the statements and calls of the programs run by this VM to tracer, see package trace.
+func (v *VM) SetTracer(tracer *trace.Tracer) {
+	v.tracer = tracer
+}
+
+// AddHooks attaches hooks to the interpreters of the programs run by this VM, see Interpreter.AddHooks. The hooks
+// are shared by all the programs.
+func (v *VM) AddHooks(hooks interpreter.Hooks) {
+	v.hooks = append(v.hooks, hooks)
+}
+
+// SetStdin sets the stream read by the prompt and by the readLine native.
+func (v *VM) SetStdin(stdin io.Reader) {
+	v.stdin = bufio.NewReader(stdin)
+}
+
+// SetStdout sets the stream receiving the output of print statements and the prompt.
+func (v *VM) SetStdout(stdout io.Writer) {
+	v.stdout = stdout
+}
+
+// SetStderr sets the stream compile and runtime errors are reported to.
+func (v *VM) SetStderr(stderr io.Writer) {
+	v.stderr = stderr
+}
+
+func (v *VM) input() *bufio.Reader {
+	if v.stdin == nil {
+		v.stdin = bufio.NewReader(os.Stdin)
+	}
+	return v.stdin
+}
+
+func (v *VM) output() io.Writer {
+	if v.stdout == nil {
+		return os.Stdout
+	}
+	return v.stdout
+}
+
+func (v *VM) errorOutput() io.Writer {
+	if v.stderr == nil {
+		return os.Stderr
+	}
+	return v.stderr
+}
@@ -1,9 +1,21 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"golox/VM"
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/coverage"
+	"golox/lox/profiler"
+	"golox/lox/trace"
+	"golox/lox/transpiler"
+	"golox/testrunner"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 var vm *VM.VM
@@ -12,14 +24,351 @@ func init() {
 	vm = &VM.VM{}
 }
 
+const usage = `Usage:
+  golox                      start an interactive prompt
+  golox [run] [flags] script run a script, optimized with -O, profiled with -profile, covered with -cover or
+                             traced with -trace, checked for undeclared globals with -strict; -codes shows the
+                             code of each error
+  golox test [flags] [path]  run the golden-file tests and test blocks under path (default "."), checked for
+                             undeclared globals with -strict
+  golox ast [flags] script   print the syntax tree of a script as JSON or S-expressions
+  golox transpile [flags] script
+                             translate a script to a Go module, built with go build in the output directory
+  golox explain [code]       explain an error code like LOX-P004, or list them all`
+
 func main() {
-	if len(os.Args) > 2 {
-		log.Error("Usage: golox [script]")
+	if len(os.Args) < 2 {
+		vm.RunPrompt()
+		return
+	}
+	switch os.Args[1] {
+	case "run":
+		runCommand(os.Args[2:])
+	case "test":
+		testCommand(os.Args[2:])
+	case "ast":
+		astCommand(os.Args[2:])
+	case "transpile":
+		transpileCommand(os.Args[2:])
+	case "explain":
+		explainCommand(os.Args[2:])
+	case "help", "-h", "-help", "--help":
+		fmt.Println(usage)
+	default:
+		runCommand(os.Args[1:])
+	}
+}
+
+// parseFlags parses the flags of a command wherever they appear among its arguments, so that both
+// `golox ast -format=sexpr a.lox` and `golox ast a.lox -format=sexpr` work; the arguments which aren't flags are
+// left in flags.Args(). Everything after `--` is taken as arguments.
+func parseFlags(flags *flag.FlagSet, args []string) {
+	var positional []string
+	for len(args) > 0 {
+		if args[0] == "--" {
+			positional = append(positional, args[1:]...)
+			break
+		}
+		_ = flags.Parse(args)
+		args = flags.Args()
+		if len(args) > 0 && args[0] != "--" {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+	}
+	_ = flags.Parse(append([]string{"--"}, positional...))
+}
+
+func runCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	optimize := flags.Bool("O", false, "optimize the program before running it")
+	profile := flags.String("profile", "", "write a pprof profile of the program to `file`, see go tool pprof")
+	top := flags.Int("profile-top", 10, "number of functions and lines of the profile summary printed to stderr, 0 for none")
+	cover := newCoverFlags(flags)
+	tracing := flags.Bool("trace", false, "log every statement, call and return of the program")
+	traceFormat := flags.String("trace-format", "text", "trace format: text or json, one event per line")
+	traceEnvironment := flags.Bool("trace-env", false, "add the bindings of the innermost scope to every statement traced")
+	traceOutput := flags.String("trace-out", "", "write the trace to `file` instead of stderr")
+	codes := flags.Bool("codes", false, "show the code of each error, see golox explain")
+	strict := flags.Bool("strict", false, "reject references to undeclared globals before running, like a \"use strict\"; pragma")
+	parseFlags(flags, args)
+	format, ok := trace.Formats[*traceFormat]
+	if flags.NArg() != 1 || !ok {
+		_, _ = fmt.Fprintln(os.Stderr, usage)
 		os.Exit(64)
-	} else if len(os.Args) == 2 {
-		vm.RunFile(os.Args[1])
+	}
+	path := flags.Arg(0)
+	vm.SetOptimize(*optimize)
+	vm.SetErrorCodes(*codes)
+	vm.SetStrict(*strict)
+	var tracer *trace.Tracer
+	if *tracing {
+		output := os.Stderr
+		if *traceOutput != "" {
+			file, err := os.Create(*traceOutput)
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err)
+				os.Exit(74)
+			}
+			defer file.Close()
+			output = file
+		}
+		tracer = trace.NewTracer(output, format)
+		tracer.SetEnvironment(*traceEnvironment)
+		vm.SetTracer(tracer)
+	}
+	var p *profiler.Profiler
+	if *profile != "" {
+		p = profiler.NewProfiler(path)
+		vm.SetProfiler(p)
+	}
+	var coverageProfile *coverage.Profile
+	if cover.enabled() {
+		source, _ := os.ReadFile(path)
+		coverageProfile = coverage.NewProfile()
+		vm.SetCoverage(coverageProfile.File(path, string(source)))
+	}
+	code := vm.RunPath(path)
+	if tracer != nil && tracer.Err() != nil {
+		_, _ = fmt.Fprintln(os.Stderr, tracer.Err())
+		code = 74
+	}
+	if p != nil {
+		if err := writeProfile(p, *profile, *top); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(74)
+		}
+	}
+	if coverageProfile != nil {
+		// the output of the script owns stdout
+		if err := cover.write(coverageProfile, os.Stderr); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(74)
+		}
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// coverFlags are the coverage flags shared by the run and test commands.
+type coverFlags struct {
+	summary *bool
+	lcov    *string
+	html    *string
+}
+
+func newCoverFlags(flags *flag.FlagSet) coverFlags {
+	return coverFlags{
+		summary: flags.Bool("cover", false, "measure statement and branch coverage and print a summary"),
+		lcov:    flags.String("cover-lcov", "", "write the coverage to `file` in the LCOV format, implies -cover"),
+		html:    flags.String("cover-html", "", "write the sources annotated with their coverage to `file` as HTML, implies -cover"),
+	}
+}
+
+func (c coverFlags) enabled() bool {
+	return *c.summary || *c.lcov != "" || *c.html != ""
+}
+
+// write prints the summary of profile to summary and writes the reports asked for.
+func (c coverFlags) write(profile *coverage.Profile, summary io.Writer) error {
+	if err := profile.WriteText(summary); err != nil {
+		return err
+	}
+	reports := []struct {
+		path  string
+		write func(io.Writer) error
+	}{{*c.lcov, profile.WriteLCOV}, {*c.html, profile.WriteHTML}}
+	for _, report := range reports {
+		if report.path == "" {
+			continue
+		}
+		file, err := os.Create(report.path)
+		if err != nil {
+			return err
+		}
+		if err := report.write(file); err != nil {
+			_ = file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeProfile writes the profile to path and its top functions and lines to stderr.
+func writeProfile(p *profiler.Profiler, path string, top int) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.WriteProfile(file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if top > 0 {
+		return p.WriteTop(os.Stderr, top)
+	}
+	return nil
+}
+
+func testCommand(args []string) {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	stepLimit := flags.Int64("step-limit", testrunner.DefaultStepLimit, "maximum number of statements a test may execute")
+	format := flags.String("format", "text", "report format: text, tap or junit")
+	optimize := flags.Bool("O", false, "optimize the scripts before running them")
+	strict := flags.Bool("strict", false, "reject references to undeclared globals before running, like a \"use strict\"; pragma")
+	cover := newCoverFlags(flags)
+	coverMin := flags.Float64("cover-min", 0, "fail unless at least `percent` of the statements ran, implies -cover")
+	parseFlags(flags, args)
+	report, ok := testrunner.Formats[*format]
+	if !ok {
+		_, _ = fmt.Fprintln(os.Stderr, "unknown report format "+*format)
+		os.Exit(64)
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	options := testrunner.Options{StepLimit: *stepLimit, Optimize: *optimize, Strict: *strict}
+	if cover.enabled() || *coverMin > 0 {
+		options.Coverage = coverage.NewProfile()
+	}
+	results, err := testrunner.Run(paths, options)
+	if err != nil {
+		log.Error(err)
+		os.Exit(64)
+	}
+	failed := report(os.Stdout, results) > 0
+	if options.Coverage != nil {
+		// TAP and JUnit reports are read by tools, which the summary would confuse
+		summary := io.Writer(os.Stdout)
+		if *format != "text" {
+			summary = os.Stderr
+		}
+		if err := cover.write(options.Coverage, summary); err != nil {
+			log.Error(err)
+			os.Exit(74)
+		}
+		if covered := options.Coverage.Summary().StatementPercent(); covered < *coverMin {
+			_, _ = fmt.Fprintf(os.Stderr, "statement coverage %.1f%% is below %.1f%%\n", covered, *coverMin)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func astCommand(args []string) {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	format := flags.String("format", "json", "output format: json or sexpr")
+	parseFlags(flags, args)
+	if flags.NArg() != 1 || (*format != "json" && *format != "sexpr") {
+		_, _ = fmt.Fprintln(os.Stderr, usage)
+		os.Exit(64)
+	}
+	path := flags.Arg(0)
+	source, err := os.ReadFile(path)
+	if err != nil {
+		log.Error(err)
+		os.Exit(66)
+	}
+	var statements []ast.Stmt
+	if strings.HasSuffix(path, ".json") {
+		statements, err = ast.DecodeJSON(source)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, path+": "+err.Error())
+			os.Exit(65)
+		}
 	} else {
-		vm.RunPrompt()
+		var ok bool
+		if statements, ok = vm.ParseStr(string(source)); !ok {
+			os.Exit(65)
+		}
+	}
+	if *format == "sexpr" {
+		fmt.Print((&ast.AstPrinter{}).PrintProgram(statements))
+		return
+	}
+	encoded, err := ast.EncodeJSON(statements)
+	if err != nil {
+		log.Error(err)
+		os.Exit(70)
 	}
+	fmt.Println(string(encoded))
+}
+
+func transpileCommand(args []string) {
+	flags := flag.NewFlagSet("transpile", flag.ExitOnError)
+	target := flags.String("target", "go", "target language, only go is supported")
+	output := flags.String("o", "", "output directory (default: the script's name with -go appended)")
+	optimize := flags.Bool("O", false, "optimize the program before translating it")
+	parseFlags(flags, args)
+	if flags.NArg() != 1 || *target != "go" {
+		_, _ = fmt.Fprintln(os.Stderr, usage)
+		os.Exit(64)
+	}
+	path := flags.Arg(0)
+	source, err := os.ReadFile(path)
+	if err != nil {
+		log.Error(err)
+		os.Exit(66)
+	}
+	vm.SetOptimize(*optimize)
+	statements, ok := vm.CompileStr(string(source))
+	if !ok {
+		os.Exit(65)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	dir := *output
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(path), name+"-go")
+	}
+	err = transpiler.WriteModule(dir, modulePath(name), statements, transpiler.Options{Source: filepath.Base(path)})
+	if err != nil {
+		log.Error(err)
+		os.Exit(74)
+	}
+}
 
+// modulePath turns the name of a script into a Go module path: characters a module path can't hold become dashes,
+// and a name left without any letter or digit becomes "program".
+func modulePath(name string) string {
+	path := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, name)
+	path = strings.Trim(path, "-_")
+	if path == "" {
+		return "program"
+	}
+	return path
+}
+
+// explainCommand prints the entry of the catalog for a code, or the list of codes without one.
+func explainCommand(args []string) {
+	if len(args) > 1 {
+		_, _ = fmt.Fprintln(os.Stderr, usage)
+		os.Exit(64)
+	}
+	if len(args) == 0 {
+		for _, entry := range catalog.Entries() {
+			fmt.Println(entry.Code + "  " + entry.Title)
+		}
+		return
+	}
+	entry, ok := catalog.Lookup(strings.ToUpper(args[0]))
+	if !ok {
+		_, _ = fmt.Fprintln(os.Stderr, "unknown error code "+args[0]+", golox explain lists them")
+		os.Exit(64)
+	}
+	fmt.Print(catalog.Explain(entry))
 }
@@ -0,0 +1,6 @@
+// Package ast defines the syntax tree of Lox programs. The node types, their visitor interfaces and the traversals
+// in walk.go are generated from ast.schema by tool/genAST.go: edit the schema and run `go generate` rather than
+// the generated files.
+package ast
+
+//go:generate go run ../../tool/genAST.go ast.schema .
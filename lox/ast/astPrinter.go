@@ -1,13 +1,12 @@
-//go:build ignore
-// +build ignore
-
 package ast
 
 import (
 	"errors"
 	"strconv"
+	"strings"
 )
 
+// AstPrinter prints syntax trees as S-expressions, e.g. `(var x (+ 1 (group 2)))`.
 type AstPrinter struct{}
 
 func (a *AstPrinter) Print(expr Expr) (interface{}, error) {
@@ -17,45 +16,287 @@ func (a *AstPrinter) Print(expr Expr) (interface{}, error) {
 	return expr.Accept(a)
 }
 
+// PrintProgram prints every statement of a program on a line of its own.
+func (a *AstPrinter) PrintProgram(statements []Stmt) string {
+	var builder strings.Builder
+	for _, statement := range statements {
+		builder.WriteString(a.stmt(statement))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+func (a *AstPrinter) stmt(stmt Stmt) string {
+	if stmt == nil {
+		return "nil"
+	}
+	str, _ := stmt.Accept(a)
+	return str.(string)
+}
+
+func (a *AstPrinter) expr(expr Expr) string {
+	if expr == nil {
+		return "nil"
+	}
+	str, _ := expr.Accept(a)
+	return str.(string)
+}
+
+func (a *AstPrinter) pattern(pattern Pattern) string {
+	str, _ := pattern.Accept(a)
+	return str.(string)
+}
+
+func (a *AstPrinter) parenthesize(name string, exprs ...Expr) string {
+	parts := make([]string, 0, len(exprs))
+	for _, expr := range exprs {
+		parts = append(parts, a.expr(expr))
+	}
+	return a.list(name, parts...)
+}
+
+func (a *AstPrinter) list(name string, parts ...string) string {
+	if len(parts) == 0 {
+		return "(" + name + ")"
+	}
+	return "(" + name + " " + strings.Join(parts, " ") + ")"
+}
+
+func (a *AstPrinter) stmts(statements []Stmt) []string {
+	parts := make([]string, 0, len(statements))
+	for _, statement := range statements {
+		parts = append(parts, a.stmt(statement))
+	}
+	return parts
+}
+
+func (a *AstPrinter) function(name string, params []Param, body []Stmt) string {
+	names := make([]string, 0, len(params))
+	for _, param := range params {
+		switch {
+		case param.Rest:
+			names = append(names, "..."+param.Name.Lexeme)
+		case param.Default != nil:
+			names = append(names, a.list("=", param.Name.Lexeme, a.expr(param.Default)))
+		default:
+			names = append(names, param.Name.Lexeme)
+		}
+	}
+	return a.list(name, append([]string{"(" + strings.Join(names, " ") + ")"}, a.stmts(body)...)...)
+}
+
+func (a *AstPrinter) VisitBlockStmt(stmt *Block) (interface{}, error) {
+	return a.list("block", a.stmts(stmt.Statements)...), nil
+}
+
+func (a *AstPrinter) VisitExpressionStmt(stmt *Expression) (interface{}, error) {
+	return a.parenthesize(";", stmt.Expression), nil
+}
+
+func (a *AstPrinter) VisitFunctionStmt(stmt *Function) (interface{}, error) {
+	if stmt.Generator {
+		return a.function("fun* "+stmt.Name.Lexeme, stmt.Params, stmt.Body), nil
+	}
+	return a.function("fun "+stmt.Name.Lexeme, stmt.Params, stmt.Body), nil
+}
+
+func (a *AstPrinter) VisitIfStmt(stmt *If) (interface{}, error) {
+	if stmt.ElseBranch == nil {
+		return a.list("if", a.expr(stmt.Condition), a.stmt(stmt.ThenBranch)), nil
+	}
+	return a.list("if", a.expr(stmt.Condition), a.stmt(stmt.ThenBranch), a.stmt(stmt.ElseBranch)), nil
+}
+
+func (a *AstPrinter) VisitPrintStmt(stmt *Print) (interface{}, error) {
+	return a.parenthesize("print", stmt.Expression), nil
+}
+
+func (a *AstPrinter) VisitReturnStmt(stmt *Return) (interface{}, error) {
+	if stmt.Value == nil {
+		return "(return)", nil
+	}
+	return a.parenthesize("return", stmt.Value), nil
+}
+
+func (a *AstPrinter) VisitVarStmt(stmt *Var) (interface{}, error) {
+	keyword := "var"
+	if stmt.Constant {
+		keyword = "const"
+	}
+	if stmt.Initializer == nil {
+		return a.list(keyword, stmt.Name.Lexeme), nil
+	}
+	return a.list(keyword, stmt.Name.Lexeme, a.expr(stmt.Initializer)), nil
+}
+
+func (a *AstPrinter) VisitWhileStmt(stmt *While) (interface{}, error) {
+	if stmt.OptionalMutate != nil {
+		return a.list("while", a.expr(stmt.Condition), a.stmt(stmt.Body), a.expr(stmt.OptionalMutate)), nil
+	}
+	return a.list("while", a.expr(stmt.Condition), a.stmt(stmt.Body)), nil
+}
+
+func (a *AstPrinter) VisitBreakStmt(_ *Break) (interface{}, error) {
+	return "(break)", nil
+}
+
+func (a *AstPrinter) VisitContinueStmt(_ *Continue) (interface{}, error) {
+	return "(continue)", nil
+}
+
+func (a *AstPrinter) VisitMatchStmt(stmt *Match) (interface{}, error) {
+	parts := []string{a.expr(stmt.Subject)}
+	for _, matchCase := range stmt.Cases {
+		patterns := make([]string, 0, len(matchCase.Patterns))
+		for _, pattern := range matchCase.Patterns {
+			patterns = append(patterns, a.pattern(pattern))
+		}
+		caseParts := []string{"(" + strings.Join(patterns, " ") + ")"}
+		if matchCase.Guard != nil {
+			caseParts = append(caseParts, a.parenthesize("if", matchCase.Guard))
+		}
+		parts = append(parts, a.list("case", append(caseParts, a.stmt(matchCase.Body))...))
+	}
+	return a.list("match", parts...), nil
+}
+
+func (a *AstPrinter) VisitForInStmt(stmt *ForIn) (interface{}, error) {
+	return a.list("for-in", stmt.Name.Lexeme, a.expr(stmt.Iterable), a.stmt(stmt.Body)), nil
+}
+
+func (a *AstPrinter) VisitYieldStmt(stmt *Yield) (interface{}, error) {
+	if stmt.Value == nil {
+		return "(yield)", nil
+	}
+	return a.parenthesize("yield", stmt.Value), nil
+}
+
+func (a *AstPrinter) VisitAssertStmt(stmt *Assert) (interface{}, error) {
+	if stmt.Message == nil {
+		return a.parenthesize("assert", stmt.Condition), nil
+	}
+	return a.parenthesize("assert", stmt.Condition, stmt.Message), nil
+}
+
+func (a *AstPrinter) VisitTestStmt(stmt *Test) (interface{}, error) {
+	return a.list("test", append([]string{strconv.Quote(stmt.Name)}, a.stmts(stmt.Body)...)...), nil
+}
+
 func (a *AstPrinter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
 }
 
+func (a *AstPrinter) VisitCallExpr(expr *Call) (interface{}, error) {
+	return a.parenthesize("call", append([]Expr{expr.Callee}, expr.Arguments...)...), nil
+}
+
+func (a *AstPrinter) VisitFunctionExpr(expr *FunctionExpr) (interface{}, error) {
+	if expr.Generator {
+		return a.function("fun*", expr.Params, expr.Body), nil
+	}
+	return a.function("fun", expr.Params, expr.Body), nil
+}
+
 func (a *AstPrinter) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
 	return a.parenthesize("group", expr.Expression), nil
 }
 
 func (a *AstPrinter) VisitLiteralExpr(expr *Literal) (interface{}, error) {
-	switch v := expr.Value.(type) {
-	case string:
-		if v == "" {
-			return "nil", nil
-		}
-	}
-	return expr.Value, nil
+	return a.literal(expr.Value), nil
+}
+
+func (a *AstPrinter) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
 }
 
 func (a *AstPrinter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Right), nil
 }
 
-func (a *AstPrinter) parenthesize(name string, exprs ...Expr) string {
-	tmpStr := "(" + name
-	for _, expr := range exprs {
-		tmpStr += " "
-		str, _ := expr.Accept(a)
-		switch v := str.(type) {
-		case string:
-			tmpStr += v
-		case int:
-			tmpStr += strconv.Itoa(v)
-		case float64:
-			tmpStr += strconv.FormatFloat(v, 'f', -1, 64)
-		case float32:
-			tmpStr += strconv.FormatFloat(float64(v), 'f', -1, 32)
-		}
+func (a *AstPrinter) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return expr.Name.Lexeme, nil
+}
+
+func (a *AstPrinter) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return a.list(expr.Operator.Lexeme, expr.Name.Lexeme, a.expr(expr.Value)), nil
+}
+
+func (a *AstPrinter) VisitTernaryExpr(expr *Ternary) (interface{}, error) {
+	return a.parenthesize("?:", expr.ConditionalExpr, expr.ThenExpr, expr.ElseExpr), nil
+}
+
+func (a *AstPrinter) VisitUpdateExpr(expr *Update) (interface{}, error) {
+	if expr.Prefix {
+		return a.parenthesize("pre"+expr.Operator.Lexeme, expr.Target), nil
+	}
+	return a.parenthesize("post"+expr.Operator.Lexeme, expr.Target), nil
+}
+
+func (a *AstPrinter) VisitSpreadExpr(expr *Spread) (interface{}, error) {
+	return a.parenthesize("...", expr.Expression), nil
+}
+
+func (a *AstPrinter) VisitNamedArgExpr(expr *NamedArg) (interface{}, error) {
+	return a.parenthesize(expr.Name.Lexeme+":", expr.Value), nil
+}
+
+func (a *AstPrinter) VisitSpawnExpr(expr *Spawn) (interface{}, error) {
+	return a.parenthesize("spawn", expr.Call), nil
+}
+
+func (a *AstPrinter) VisitTypeCheckExpr(expr *TypeCheck) (interface{}, error) {
+	return a.list("check", strings.ReplaceAll(expr.Type.String(), " ", ""), a.expr(expr.Expression)), nil
+}
+
+func (a *AstPrinter) VisitMapExpr(expr *Map) (interface{}, error) {
+	entries := make([]string, 0, len(expr.Keys))
+	for index, key := range expr.Keys {
+		entries = append(entries, a.list(a.expr(key), a.expr(expr.Values[index])))
+	}
+	return a.list("map", entries...), nil
+}
 
+func (a *AstPrinter) VisitLiteralPattern(pattern *LiteralPattern) (interface{}, error) {
+	return a.literal(pattern.Value), nil
+}
+
+func (a *AstPrinter) VisitBindingPattern(pattern *BindingPattern) (interface{}, error) {
+	return pattern.Name.Lexeme, nil
+}
+
+func (a *AstPrinter) VisitWildcardPattern(_ *WildcardPattern) (interface{}, error) {
+	return "_", nil
+}
+
+func (a *AstPrinter) VisitListPattern(pattern *ListPattern) (interface{}, error) {
+	elements := make([]string, 0, len(pattern.Elements)+1)
+	for _, element := range pattern.Elements {
+		elements = append(elements, a.pattern(element))
+	}
+	if pattern.Rest != nil {
+		elements = append(elements, "..."+pattern.Rest.Lexeme)
+	}
+	return a.list("list", elements...), nil
+}
+
+func (a *AstPrinter) VisitMapPattern(pattern *MapPattern) (interface{}, error) {
+	entries := make([]string, 0, len(pattern.Keys))
+	for index, key := range pattern.Keys {
+		entries = append(entries, a.list(a.pattern(key), a.pattern(pattern.Values[index])))
+	}
+	return a.list("map", entries...), nil
+}
+
+func (a *AstPrinter) literal(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
 	}
-	tmpStr += ")"
-	return tmpStr
+	return "nil"
 }
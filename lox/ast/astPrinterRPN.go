@@ -1,5 +1,3 @@
-//go:build ignore
-
 package ast
 
 import (
@@ -13,7 +11,10 @@ import (
 //（E）	                        E的逆波兰式
 // E1 op E2 （二元运算）	        E1的逆波兰式 E2的逆波兰式 op
 // op E     （一元运算）	        E的逆波兰式 op
+// f(E1, E2)（调用）	            f E1的逆波兰式 E2的逆波兰式 call/2
 
+// AstPrinterRPN prints expressions in reverse Polish notation, e.g. `1 2 + 4 3 - *`. Only expressions have an
+// RPN form, statements are printed with AstPrinter.
 type AstPrinterRPN struct{}
 
 func (a *AstPrinterRPN) Print(expr Expr) (interface{}, error) {
@@ -24,20 +25,30 @@ func (a *AstPrinterRPN) VisitBinaryExpr(expr *Binary) (interface{}, error) {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
 }
 
+func (a *AstPrinterRPN) VisitCallExpr(expr *Call) (interface{}, error) {
+	return a.parenthesize("call/"+strconv.Itoa(len(expr.Arguments)), append([]Expr{expr.Callee}, expr.Arguments...)...), nil
+}
+
 func (a *AstPrinterRPN) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
 	return a.parenthesize("group", expr.Expression), nil
 }
 
 func (a *AstPrinterRPN) VisitLiteralExpr(expr *Literal) (interface{}, error) {
 	switch v := expr.Value.(type) {
+	case nil:
+		return "nil", nil
 	case string:
-		if v == "" {
-			return "nil", nil
-		}
+		return strconv.Quote(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
 	}
 	return expr.Value, nil
 }
 
+func (a *AstPrinterRPN) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
+}
+
 func (a *AstPrinterRPN) VisitUnaryExpr(expr *Unary) (interface{}, error) {
 	if expr.Operator.Lexeme == "-" {
 		return a.parenthesize("~", expr.Right), nil // handle special case where Negate and Minus conflict or say ambiguous
@@ -45,6 +56,53 @@ func (a *AstPrinterRPN) VisitUnaryExpr(expr *Unary) (interface{}, error) {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Right), nil
 }
 
+func (a *AstPrinterRPN) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return expr.Name.Lexeme, nil
+}
+
+func (a *AstPrinterRPN) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return expr.Name.Lexeme + " " + a.parenthesize(expr.Operator.Lexeme, expr.Value), nil
+}
+
+func (a *AstPrinterRPN) VisitTernaryExpr(expr *Ternary) (interface{}, error) {
+	return a.parenthesize("?:", expr.ConditionalExpr, expr.ThenExpr, expr.ElseExpr), nil
+}
+
+func (a *AstPrinterRPN) VisitFunctionExpr(expr *FunctionExpr) (interface{}, error) {
+	return "<fn/" + strconv.Itoa(len(expr.Params)) + ">", nil
+}
+
+func (a *AstPrinterRPN) VisitUpdateExpr(expr *Update) (interface{}, error) {
+	if expr.Prefix {
+		return a.parenthesize("pre"+expr.Operator.Lexeme, expr.Target), nil
+	}
+	return a.parenthesize("post"+expr.Operator.Lexeme, expr.Target), nil
+}
+
+func (a *AstPrinterRPN) VisitSpreadExpr(expr *Spread) (interface{}, error) {
+	return a.parenthesize("...", expr.Expression), nil
+}
+
+func (a *AstPrinterRPN) VisitNamedArgExpr(expr *NamedArg) (interface{}, error) {
+	return a.parenthesize(expr.Name.Lexeme+":", expr.Value), nil
+}
+
+func (a *AstPrinterRPN) VisitSpawnExpr(expr *Spawn) (interface{}, error) {
+	return a.parenthesize("spawn", expr.Call), nil
+}
+
+func (a *AstPrinterRPN) VisitTypeCheckExpr(expr *TypeCheck) (interface{}, error) {
+	return a.parenthesize("check", expr.Expression), nil
+}
+
+func (a *AstPrinterRPN) VisitMapExpr(expr *Map) (interface{}, error) {
+	entries := make([]Expr, 0, 2*len(expr.Keys))
+	for index, key := range expr.Keys {
+		entries = append(entries, key, expr.Values[index])
+	}
+	return a.parenthesize("map", entries...), nil
+}
+
 func (a *AstPrinterRPN) parenthesize(name string, exprs ...Expr) string {
 	tmpStr := ""
 	for _, expr := range exprs {
@@ -0,0 +1,357 @@
+package ast
+
+import (
+	. "golox/lox/lexer"
+	"strconv"
+	"strings"
+)
+
+// Formatter prints a syntax tree back as Lox source, one statement per line with blocks indented by two spaces.
+// Parsing its output yields the same tree again, groupings included, although desugared constructs come back in
+// their desugared form: a `for` loop is printed as a block holding its initializer and a `for (; cond; step)`.
+type Formatter struct {
+	indent int
+}
+
+// Format returns the source of a whole program.
+func Format(statements []Stmt) string {
+	formatter := &Formatter{}
+	var builder strings.Builder
+	for _, statement := range statements {
+		builder.WriteString(formatter.stmt(statement))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// FormatExpr returns the source of a single expression.
+func FormatExpr(expr Expr) string {
+	return (&Formatter{}).expr(expr)
+}
+
+func (f *Formatter) stmt(stmt Stmt) string {
+	text, _ := stmt.Accept(f)
+	return text.(string)
+}
+
+func (f *Formatter) expr(expr Expr) string {
+	text, _ := expr.Accept(f)
+	return text.(string)
+}
+
+func (f *Formatter) pattern(pattern Pattern) string {
+	text, _ := pattern.Accept(f)
+	return text.(string)
+}
+
+// block prints statements between braces, each on a line of its own one level deeper than the braces.
+func (f *Formatter) block(statements []Stmt) string {
+	if len(statements) == 0 {
+		return "{}"
+	}
+	f.indent++
+	var builder strings.Builder
+	builder.WriteString("{\n")
+	for _, statement := range statements {
+		builder.WriteString(strings.Repeat("  ", f.indent))
+		builder.WriteString(f.stmt(statement))
+		builder.WriteString("\n")
+	}
+	f.indent--
+	builder.WriteString(strings.Repeat("  ", f.indent))
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// function prints the parameters and body of a function after head, which is everything before the '('.
+func (f *Formatter) function(head string, params []Param, returnType *TypeAnnotation, body []Stmt) string {
+	formatted := make([]string, 0, len(params))
+	for _, param := range params {
+		text := param.Name.Lexeme
+		if param.Rest {
+			text = "..." + text
+		}
+		text += annotation(param.Type)
+		if param.Default != nil {
+			text += " = " + f.expr(param.Default)
+		}
+		formatted = append(formatted, text)
+	}
+	return head + "(" + strings.Join(formatted, ", ") + ")" + annotation(returnType) + " " + f.block(body)
+}
+
+// annotation prints a type annotation with its leading colon, or nothing for a missing one.
+func annotation(annotation *TypeAnnotation) string {
+	if annotation == nil {
+		return ""
+	}
+	return ": " + annotation.String()
+}
+
+// String returns the annotation as written, without its colon, e.g. `number | nil` for `number?`.
+func (t *TypeAnnotation) String() string {
+	names := make([]string, 0, len(t.Names))
+	for _, name := range t.Names {
+		names = append(names, name.Lexeme)
+	}
+	return strings.Join(names, " | ")
+}
+
+func (f *Formatter) VisitBlockStmt(stmt *Block) (interface{}, error) {
+	return f.block(stmt.Statements), nil
+}
+
+func (f *Formatter) VisitExpressionStmt(stmt *Expression) (interface{}, error) {
+	if startsWithMap(stmt.Expression) {
+		// a statement starting with a brace is a block
+		return "(" + f.expr(stmt.Expression) + ");", nil
+	}
+	return f.expr(stmt.Expression) + ";", nil
+}
+
+// startsWithMap tells whether the source of expr starts with a map literal.
+func startsWithMap(expr Expr) bool {
+	switch e := expr.(type) {
+	case *Map:
+		return true
+	case *Binary:
+		return startsWithMap(e.Left)
+	case *Logical:
+		return startsWithMap(e.Left)
+	case *Call:
+		return startsWithMap(e.Callee)
+	case *Ternary:
+		return startsWithMap(e.ConditionalExpr)
+	case *Update:
+		return !e.Prefix && startsWithMap(e.Target)
+	case *TypeCheck:
+		return startsWithMap(e.Expression)
+	}
+	return false
+}
+
+func (f *Formatter) VisitFunctionStmt(stmt *Function) (interface{}, error) {
+	keyword := "fun"
+	if stmt.Generator {
+		keyword += "*"
+	}
+	return f.function(keyword+" "+stmt.Name.Lexeme, stmt.Params, stmt.ReturnType, stmt.Body), nil
+}
+
+func (f *Formatter) VisitIfStmt(stmt *If) (interface{}, error) {
+	text := "if (" + f.expr(stmt.Condition) + ") " + f.stmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		text += " else " + f.stmt(stmt.ElseBranch)
+	}
+	return text, nil
+}
+
+func (f *Formatter) VisitPrintStmt(stmt *Print) (interface{}, error) {
+	return "print " + f.expr(stmt.Expression) + ";", nil
+}
+
+func (f *Formatter) VisitReturnStmt(stmt *Return) (interface{}, error) {
+	if stmt.Value == nil {
+		return "return;", nil
+	}
+	return "return " + f.expr(stmt.Value) + ";", nil
+}
+
+func (f *Formatter) VisitVarStmt(stmt *Var) (interface{}, error) {
+	keyword := "var"
+	if stmt.Constant {
+		keyword = "const"
+	}
+	declaration := keyword + " " + stmt.Name.Lexeme + annotation(stmt.Type)
+	if stmt.Initializer == nil {
+		return declaration + ";", nil
+	}
+	return declaration + " = " + f.expr(stmt.Initializer) + ";", nil
+}
+
+func (f *Formatter) VisitWhileStmt(stmt *While) (interface{}, error) {
+	if stmt.OptionalMutate != nil {
+		return "for (; " + f.expr(stmt.Condition) + "; " + f.expr(stmt.OptionalMutate) + ") " + f.stmt(stmt.Body), nil
+	}
+	return "while (" + f.expr(stmt.Condition) + ") " + f.stmt(stmt.Body), nil
+}
+
+func (f *Formatter) VisitBreakStmt(_ *Break) (interface{}, error) {
+	return "break;", nil
+}
+
+func (f *Formatter) VisitContinueStmt(_ *Continue) (interface{}, error) {
+	return "continue;", nil
+}
+
+func (f *Formatter) VisitMatchStmt(stmt *Match) (interface{}, error) {
+	var builder strings.Builder
+	builder.WriteString("match (" + f.expr(stmt.Subject) + ") {\n")
+	f.indent++
+	for _, matchCase := range stmt.Cases {
+		patterns := make([]string, 0, len(matchCase.Patterns))
+		for _, pattern := range matchCase.Patterns {
+			patterns = append(patterns, f.pattern(pattern))
+		}
+		builder.WriteString(strings.Repeat("  ", f.indent) + "case " + strings.Join(patterns, ", "))
+		if matchCase.Guard != nil {
+			builder.WriteString(" if " + f.expr(matchCase.Guard))
+		}
+		builder.WriteString(" => " + f.stmt(matchCase.Body) + "\n")
+	}
+	f.indent--
+	builder.WriteString(strings.Repeat("  ", f.indent) + "}")
+	return builder.String(), nil
+}
+
+func (f *Formatter) VisitForInStmt(stmt *ForIn) (interface{}, error) {
+	return "for (" + stmt.Name.Lexeme + " in " + f.expr(stmt.Iterable) + ") " + f.stmt(stmt.Body), nil
+}
+
+func (f *Formatter) VisitYieldStmt(stmt *Yield) (interface{}, error) {
+	if stmt.Value == nil {
+		return "yield;", nil
+	}
+	return "yield " + f.expr(stmt.Value) + ";", nil
+}
+
+func (f *Formatter) VisitAssertStmt(stmt *Assert) (interface{}, error) {
+	if stmt.Message == nil {
+		return "assert " + f.expr(stmt.Condition) + ";", nil
+	}
+	return "assert " + f.expr(stmt.Condition) + ", " + f.expr(stmt.Message) + ";", nil
+}
+
+func (f *Formatter) VisitTestStmt(stmt *Test) (interface{}, error) {
+	return "test \"" + stmt.Name + "\" " + f.block(stmt.Body), nil
+}
+
+func (f *Formatter) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	return f.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + f.expr(expr.Right), nil
+}
+
+func (f *Formatter) VisitCallExpr(expr *Call) (interface{}, error) {
+	arguments := make([]string, 0, len(expr.Arguments))
+	for _, argument := range expr.Arguments {
+		arguments = append(arguments, f.expr(argument))
+	}
+	return f.expr(expr.Callee) + "(" + strings.Join(arguments, ", ") + ")", nil
+}
+
+func (f *Formatter) VisitFunctionExpr(expr *FunctionExpr) (interface{}, error) {
+	if expr.Generator {
+		return f.function("fun* ", expr.Params, expr.ReturnType, expr.Body), nil
+	}
+	return f.function("fun ", expr.Params, expr.ReturnType, expr.Body), nil
+}
+
+func (f *Formatter) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	return "(" + f.expr(expr.Expression) + ")", nil
+}
+
+func (f *Formatter) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	return formatValue(expr.Value), nil
+}
+
+func (f *Formatter) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	return f.expr(expr.Left) + " " + expr.Operator.Lexeme + " " + f.expr(expr.Right), nil
+}
+
+func (f *Formatter) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	right := f.expr(expr.Right)
+	// keep `- -x` from turning into the decrement `--x`
+	if expr.Operator.Type0 == MINUS && strings.HasPrefix(right, "-") {
+		return "- " + right, nil
+	}
+	return expr.Operator.Lexeme + right, nil
+}
+
+func (f *Formatter) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return expr.Name.Lexeme, nil
+}
+
+func (f *Formatter) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return expr.Name.Lexeme + " " + expr.Operator.Lexeme + " " + f.expr(expr.Value), nil
+}
+
+func (f *Formatter) VisitTernaryExpr(expr *Ternary) (interface{}, error) {
+	return f.expr(expr.ConditionalExpr) + " ? " + f.expr(expr.ThenExpr) + " : " + f.expr(expr.ElseExpr), nil
+}
+
+func (f *Formatter) VisitUpdateExpr(expr *Update) (interface{}, error) {
+	if expr.Prefix {
+		return expr.Operator.Lexeme + f.expr(expr.Target), nil
+	}
+	return f.expr(expr.Target) + expr.Operator.Lexeme, nil
+}
+
+func (f *Formatter) VisitSpreadExpr(expr *Spread) (interface{}, error) {
+	return "..." + f.expr(expr.Expression), nil
+}
+
+func (f *Formatter) VisitNamedArgExpr(expr *NamedArg) (interface{}, error) {
+	return expr.Name.Lexeme + ": " + f.expr(expr.Value), nil
+}
+
+func (f *Formatter) VisitSpawnExpr(expr *Spawn) (interface{}, error) {
+	return "spawn " + f.expr(expr.Call), nil
+}
+
+// VisitTypeCheckExpr prints only the checked expression, the check is not part of the source.
+func (f *Formatter) VisitTypeCheckExpr(expr *TypeCheck) (interface{}, error) {
+	return f.expr(expr.Expression), nil
+}
+
+func (f *Formatter) VisitMapExpr(expr *Map) (interface{}, error) {
+	entries := make([]string, 0, len(expr.Keys))
+	for index, key := range expr.Keys {
+		entries = append(entries, f.expr(key)+": "+f.expr(expr.Values[index]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}", nil
+}
+
+func (f *Formatter) VisitLiteralPattern(pattern *LiteralPattern) (interface{}, error) {
+	return formatValue(pattern.Value), nil
+}
+
+func (f *Formatter) VisitBindingPattern(pattern *BindingPattern) (interface{}, error) {
+	return pattern.Name.Lexeme, nil
+}
+
+func (f *Formatter) VisitWildcardPattern(_ *WildcardPattern) (interface{}, error) {
+	return "_", nil
+}
+
+func (f *Formatter) VisitListPattern(pattern *ListPattern) (interface{}, error) {
+	elements := make([]string, 0, len(pattern.Elements)+1)
+	for _, element := range pattern.Elements {
+		elements = append(elements, f.pattern(element))
+	}
+	if pattern.Rest != nil {
+		elements = append(elements, "..."+pattern.Rest.Lexeme)
+	}
+	return "[" + strings.Join(elements, ", ") + "]", nil
+}
+
+func (f *Formatter) VisitMapPattern(pattern *MapPattern) (interface{}, error) {
+	entries := make([]string, 0, len(pattern.Keys))
+	for index, key := range pattern.Keys {
+		entries = append(entries, f.pattern(key)+": "+f.pattern(pattern.Values[index]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}", nil
+}
+
+// formatValue prints the value of a literal the way it is written in source.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return "\"" + v + "\""
+	}
+	return "nil"
+}
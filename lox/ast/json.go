@@ -0,0 +1,614 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	. "golox/lox/lexer"
+)
+
+// JSONVersion is the version of the JSON encoding written by EncodeJSON. DecodeJSON rejects any other version, so
+// it must be bumped whenever the encoding of a node changes.
+const JSONVersion = 6
+
+// jsonProgram is the document EncodeJSON writes. Every node is an object whose "node" member names its Go type and
+// whose other members are its fields in lower camel case. Tokens are objects with their type name, lexeme, line,
+// column and, for numbers and strings, literal value. Type annotations are arrays of the tokens naming their types.
+// Absent expressions, statements and annotations are null.
+type jsonProgram struct {
+	Version    int           `json:"version"`
+	Statements []interface{} `json:"statements"`
+}
+
+// EncodeJSON encodes a program as JSON, see jsonProgram for the layout.
+func EncodeJSON(statements []Stmt) ([]byte, error) {
+	encoder := &jsonEncoder{}
+	program := jsonProgram{Version: JSONVersion, Statements: encoder.stmts(statements)}
+	return json.MarshalIndent(program, "", "  ")
+}
+
+// DecodeJSON rebuilds the program encoded by EncodeJSON. The tree it returns can be resolved and interpreted like
+// one coming from the parser.
+func DecodeJSON(data []byte) ([]Stmt, error) {
+	var program jsonProgram
+	if err := json.Unmarshal(data, &program); err != nil {
+		return nil, err
+	}
+	if program.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported AST version %d, expected %d", program.Version, JSONVersion)
+	}
+	decoder := &jsonDecoder{}
+	statements := make([]Stmt, 0, len(program.Statements))
+	for _, statement := range program.Statements {
+		statements = append(statements, decoder.stmt(statement))
+	}
+	if decoder.err != nil {
+		return nil, decoder.err
+	}
+	return statements, nil
+}
+
+type jsonNode map[string]interface{}
+
+type jsonEncoder struct{}
+
+func (e *jsonEncoder) stmt(stmt Stmt) interface{} {
+	if stmt == nil {
+		return nil
+	}
+	node, _ := stmt.Accept(e)
+	return node
+}
+
+func (e *jsonEncoder) stmts(statements []Stmt) []interface{} {
+	nodes := make([]interface{}, 0, len(statements))
+	for _, statement := range statements {
+		nodes = append(nodes, e.stmt(statement))
+	}
+	return nodes
+}
+
+func (e *jsonEncoder) expr(expr Expr) interface{} {
+	if expr == nil {
+		return nil
+	}
+	node, _ := expr.Accept(e)
+	return node
+}
+
+func (e *jsonEncoder) exprs(expressions []Expr) []interface{} {
+	nodes := make([]interface{}, 0, len(expressions))
+	for _, expression := range expressions {
+		nodes = append(nodes, e.expr(expression))
+	}
+	return nodes
+}
+
+func (e *jsonEncoder) pattern(pattern Pattern) interface{} {
+	node, _ := pattern.Accept(e)
+	return node
+}
+
+func (e *jsonEncoder) patterns(patterns []Pattern) []interface{} {
+	nodes := make([]interface{}, 0, len(patterns))
+	for _, pattern := range patterns {
+		nodes = append(nodes, e.pattern(pattern))
+	}
+	return nodes
+}
+
+func (e *jsonEncoder) token(token Token) jsonNode {
+	node := jsonNode{"type": TokenTypeMapper[int(token.Type0)], "lexeme": token.Lexeme, "line": token.Line, "column": token.Column}
+	if token.Type0 == NUMBER || token.Type0 == STRING {
+		node["literal"] = token.Literal
+	}
+	return node
+}
+
+func (e *jsonEncoder) params(params []Param) []interface{} {
+	nodes := make([]interface{}, 0, len(params))
+	for _, param := range params {
+		nodes = append(nodes, jsonNode{"name": e.token(param.Name), "default": e.expr(param.Default), "rest": param.Rest,
+			"type": e.typeAnnotation(param.Type)})
+	}
+	return nodes
+}
+
+func (e *jsonEncoder) typeAnnotation(annotation *TypeAnnotation) interface{} {
+	if annotation == nil {
+		return nil
+	}
+	names := make([]interface{}, 0, len(annotation.Names))
+	for _, name := range annotation.Names {
+		names = append(names, e.token(name))
+	}
+	return names
+}
+
+func (e *jsonEncoder) VisitBlockStmt(stmt *Block) (interface{}, error) {
+	return jsonNode{"node": "Block", "statements": e.stmts(stmt.Statements)}, nil
+}
+
+func (e *jsonEncoder) VisitExpressionStmt(stmt *Expression) (interface{}, error) {
+	return jsonNode{"node": "Expression", "expression": e.expr(stmt.Expression)}, nil
+}
+
+func (e *jsonEncoder) VisitFunctionStmt(stmt *Function) (interface{}, error) {
+	return jsonNode{"node": "Function", "name": e.token(stmt.Name), "params": e.params(stmt.Params),
+		"body": e.stmts(stmt.Body), "generator": stmt.Generator, "returnType": e.typeAnnotation(stmt.ReturnType), "source": stmt.Source}, nil
+}
+
+func (e *jsonEncoder) VisitIfStmt(stmt *If) (interface{}, error) {
+	return jsonNode{"node": "If", "keyword": e.token(stmt.Keyword), "condition": e.expr(stmt.Condition), "thenBranch": e.stmt(stmt.ThenBranch),
+		"elseBranch": e.stmt(stmt.ElseBranch)}, nil
+}
+
+func (e *jsonEncoder) VisitPrintStmt(stmt *Print) (interface{}, error) {
+	return jsonNode{"node": "Print", "keyword": e.token(stmt.Keyword), "expression": e.expr(stmt.Expression)}, nil
+}
+
+func (e *jsonEncoder) VisitReturnStmt(stmt *Return) (interface{}, error) {
+	return jsonNode{"node": "Return", "keyword": e.token(stmt.KeyWord), "value": e.expr(stmt.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitVarStmt(stmt *Var) (interface{}, error) {
+	return jsonNode{"node": "Var", "name": e.token(stmt.Name), "initializer": e.expr(stmt.Initializer),
+		"constant": stmt.Constant, "type": e.typeAnnotation(stmt.Type)}, nil
+}
+
+func (e *jsonEncoder) VisitWhileStmt(stmt *While) (interface{}, error) {
+	return jsonNode{"node": "While", "keyword": e.token(stmt.Keyword), "condition": e.expr(stmt.Condition), "body": e.stmt(stmt.Body),
+		"optionalMutate": e.expr(stmt.OptionalMutate)}, nil
+}
+
+func (e *jsonEncoder) VisitBreakStmt(stmt *Break) (interface{}, error) {
+	return jsonNode{"node": "Break", "keyword": e.token(stmt.Keyword)}, nil
+}
+
+func (e *jsonEncoder) VisitContinueStmt(stmt *Continue) (interface{}, error) {
+	return jsonNode{"node": "Continue", "keyword": e.token(stmt.Keyword)}, nil
+}
+
+func (e *jsonEncoder) VisitMatchStmt(stmt *Match) (interface{}, error) {
+	cases := make([]interface{}, 0, len(stmt.Cases))
+	for _, matchCase := range stmt.Cases {
+		patterns := make([]interface{}, 0, len(matchCase.Patterns))
+		for _, pattern := range matchCase.Patterns {
+			patterns = append(patterns, e.pattern(pattern))
+		}
+		cases = append(cases, jsonNode{"patterns": patterns, "guard": e.expr(matchCase.Guard), "body": e.stmt(matchCase.Body)})
+	}
+	return jsonNode{"node": "Match", "keyword": e.token(stmt.Keyword), "subject": e.expr(stmt.Subject), "cases": cases}, nil
+}
+
+func (e *jsonEncoder) VisitForInStmt(stmt *ForIn) (interface{}, error) {
+	return jsonNode{"node": "ForIn", "keyword": e.token(stmt.Keyword), "name": e.token(stmt.Name),
+		"iterable": e.expr(stmt.Iterable), "body": e.stmt(stmt.Body)}, nil
+}
+
+func (e *jsonEncoder) VisitYieldStmt(stmt *Yield) (interface{}, error) {
+	return jsonNode{"node": "Yield", "keyword": e.token(stmt.Keyword), "value": e.expr(stmt.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitAssertStmt(stmt *Assert) (interface{}, error) {
+	return jsonNode{"node": "Assert", "keyword": e.token(stmt.Keyword), "condition": e.expr(stmt.Condition),
+		"message": e.expr(stmt.Message), "source": stmt.Source}, nil
+}
+
+func (e *jsonEncoder) VisitTestStmt(stmt *Test) (interface{}, error) {
+	return jsonNode{"node": "Test", "keyword": e.token(stmt.Keyword), "name": stmt.Name, "body": e.stmts(stmt.Body)}, nil
+}
+
+func (e *jsonEncoder) VisitBinaryExpr(expr *Binary) (interface{}, error) {
+	return jsonNode{"node": "Binary", "left": e.expr(expr.Left), "operator": e.token(expr.Operator), "right": e.expr(expr.Right)}, nil
+}
+
+func (e *jsonEncoder) VisitCallExpr(expr *Call) (interface{}, error) {
+	return jsonNode{"node": "Call", "callee": e.expr(expr.Callee), "paren": e.token(expr.Paren), "arguments": e.exprs(expr.Arguments)}, nil
+}
+
+func (e *jsonEncoder) VisitGroupingExpr(expr *Grouping) (interface{}, error) {
+	return jsonNode{"node": "Grouping", "expression": e.expr(expr.Expression)}, nil
+}
+
+func (e *jsonEncoder) VisitLiteralExpr(expr *Literal) (interface{}, error) {
+	return jsonNode{"node": "Literal", "type": TokenTypeMapper[int(expr.Type)], "value": expr.Value}, nil
+}
+
+func (e *jsonEncoder) VisitLogicalExpr(expr *Logical) (interface{}, error) {
+	return jsonNode{"node": "Logical", "left": e.expr(expr.Left), "operator": e.token(expr.Operator), "right": e.expr(expr.Right)}, nil
+}
+
+func (e *jsonEncoder) VisitUnaryExpr(expr *Unary) (interface{}, error) {
+	return jsonNode{"node": "Unary", "operator": e.token(expr.Operator), "right": e.expr(expr.Right)}, nil
+}
+
+func (e *jsonEncoder) VisitVariableExpr(expr *Variable) (interface{}, error) {
+	return jsonNode{"node": "Variable", "name": e.token(expr.Name)}, nil
+}
+
+func (e *jsonEncoder) VisitAssignExpr(expr *Assign) (interface{}, error) {
+	return jsonNode{"node": "Assign", "name": e.token(expr.Name), "operator": e.token(expr.Operator), "value": e.expr(expr.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitTernaryExpr(expr *Ternary) (interface{}, error) {
+	return jsonNode{"node": "Ternary", "conditionalExpr": e.expr(expr.ConditionalExpr), "thenExpr": e.expr(expr.ThenExpr),
+		"elseExpr": e.expr(expr.ElseExpr)}, nil
+}
+
+func (e *jsonEncoder) VisitFunctionExpr(expr *FunctionExpr) (interface{}, error) {
+	return jsonNode{"node": "FunctionExpr", "params": e.params(expr.Params), "body": e.stmts(expr.Body), "generator": expr.Generator,
+		"returnType": e.typeAnnotation(expr.ReturnType), "source": expr.Source}, nil
+}
+
+func (e *jsonEncoder) VisitUpdateExpr(expr *Update) (interface{}, error) {
+	return jsonNode{"node": "Update", "operator": e.token(expr.Operator), "target": e.expr(expr.Target), "prefix": expr.Prefix}, nil
+}
+
+func (e *jsonEncoder) VisitSpreadExpr(expr *Spread) (interface{}, error) {
+	return jsonNode{"node": "Spread", "operator": e.token(expr.Operator), "expression": e.expr(expr.Expression)}, nil
+}
+
+func (e *jsonEncoder) VisitNamedArgExpr(expr *NamedArg) (interface{}, error) {
+	return jsonNode{"node": "NamedArg", "name": e.token(expr.Name), "value": e.expr(expr.Value)}, nil
+}
+
+func (e *jsonEncoder) VisitSpawnExpr(expr *Spawn) (interface{}, error) {
+	return jsonNode{"node": "Spawn", "keyword": e.token(expr.Keyword), "call": e.expr(expr.Call)}, nil
+}
+
+func (e *jsonEncoder) VisitTypeCheckExpr(expr *TypeCheck) (interface{}, error) {
+	return jsonNode{"node": "TypeCheck", "token": e.token(expr.Token), "expression": e.expr(expr.Expression),
+		"type": e.typeAnnotation(expr.Type)}, nil
+}
+
+func (e *jsonEncoder) VisitMapExpr(expr *Map) (interface{}, error) {
+	return jsonNode{"node": "Map", "brace": e.token(expr.Brace), "keys": e.exprs(expr.Keys), "values": e.exprs(expr.Values)}, nil
+}
+
+func (e *jsonEncoder) VisitLiteralPattern(pattern *LiteralPattern) (interface{}, error) {
+	return jsonNode{"node": "LiteralPattern", "token": e.token(pattern.Token), "value": pattern.Value}, nil
+}
+
+func (e *jsonEncoder) VisitBindingPattern(pattern *BindingPattern) (interface{}, error) {
+	return jsonNode{"node": "BindingPattern", "name": e.token(pattern.Name)}, nil
+}
+
+func (e *jsonEncoder) VisitWildcardPattern(pattern *WildcardPattern) (interface{}, error) {
+	return jsonNode{"node": "WildcardPattern", "token": e.token(pattern.Token)}, nil
+}
+
+func (e *jsonEncoder) VisitListPattern(pattern *ListPattern) (interface{}, error) {
+	elements := make([]interface{}, 0, len(pattern.Elements))
+	for _, element := range pattern.Elements {
+		elements = append(elements, e.pattern(element))
+	}
+	var rest interface{}
+	if pattern.Rest != nil {
+		rest = e.token(*pattern.Rest)
+	}
+	return jsonNode{"node": "ListPattern", "bracket": e.token(pattern.Bracket), "elements": elements, "rest": rest}, nil
+}
+
+func (e *jsonEncoder) VisitMapPattern(pattern *MapPattern) (interface{}, error) {
+	return jsonNode{"node": "MapPattern", "brace": e.token(pattern.Brace), "keys": e.patterns(pattern.Keys), "values": e.patterns(pattern.Values)}, nil
+}
+
+// jsonDecoder rebuilds nodes from the generic values encoding/json decodes a document into. The first problem it
+// meets is kept in err, after which it only returns zero values.
+type jsonDecoder struct {
+	err error
+}
+
+func (d *jsonDecoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+// object returns value as a node, failing unless it is an object or, when nullable, null.
+func (d *jsonDecoder) object(value interface{}, what string, nullable bool) jsonNode {
+	if value == nil {
+		if !nullable {
+			d.fail("missing %s", what)
+		}
+		return nil
+	}
+	node, ok := value.(map[string]interface{})
+	if !ok {
+		d.fail("%s must be an object", what)
+		return nil
+	}
+	return node
+}
+
+func (d *jsonDecoder) list(node jsonNode, key string) []interface{} {
+	if node[key] == nil {
+		return nil
+	}
+	list, ok := node[key].([]interface{})
+	if !ok {
+		d.fail("%s.%s must be an array", node["node"], key)
+	}
+	return list
+}
+
+func (d *jsonDecoder) string(node jsonNode, key string) string {
+	text, ok := node[key].(string)
+	if !ok {
+		d.fail("%s.%s must be a string", node["node"], key)
+	}
+	return text
+}
+
+func (d *jsonDecoder) bool(node jsonNode, key string) bool {
+	if node[key] == nil {
+		return false
+	}
+	value, ok := node[key].(bool)
+	if !ok {
+		d.fail("%s.%s must be a boolean", node["node"], key)
+	}
+	return value
+}
+
+// value checks that a literal value is one the interpreter knows: nil, a boolean, a number or a string.
+func (d *jsonDecoder) value(node jsonNode, key string) interface{} {
+	switch value := node[key].(type) {
+	case nil, bool, float64, string:
+		return value
+	}
+	d.fail("%s.%s must be a literal value", node["node"], key)
+	return nil
+}
+
+func (d *jsonDecoder) tokenType(name string) TokenType {
+	tokenType, ok := TokenTypeByName(name)
+	if !ok {
+		d.fail("unknown token type %q", name)
+	}
+	return tokenType
+}
+
+func (d *jsonDecoder) token(value interface{}, what string) Token {
+	node := d.object(value, what, false)
+	if node == nil {
+		return Token{}
+	}
+	line, ok := node["line"].(float64)
+	if !ok {
+		d.fail("%s.line must be a number", what)
+	}
+	token := Token{Type0: d.tokenType(d.string(node, "type")), Lexeme: d.string(node, "lexeme"), Line: int(line), Literal: ""}
+	if column, ok := node["column"].(float64); ok {
+		token.Column = int(column)
+	}
+	if token.Type0 == NUMBER || token.Type0 == STRING {
+		token.Literal = d.value(node, "literal")
+	}
+	return token
+}
+
+func (d *jsonDecoder) stmts(node jsonNode, key string) []Stmt {
+	values := d.list(node, key)
+	statements := make([]Stmt, 0, len(values))
+	for _, value := range values {
+		statements = append(statements, d.stmt(value))
+	}
+	return statements
+}
+
+func (d *jsonDecoder) exprs(node jsonNode, key string) []Expr {
+	values := d.list(node, key)
+	expressions := make([]Expr, 0, len(values))
+	for _, value := range values {
+		expressions = append(expressions, d.expr(value))
+	}
+	return expressions
+}
+
+func (d *jsonDecoder) params(node jsonNode) []Param {
+	values := d.list(node, "params")
+	params := make([]Param, 0, len(values))
+	for _, value := range values {
+		param := d.object(value, "parameter", false)
+		if param == nil {
+			continue
+		}
+		params = append(params, Param{Name: d.token(param["name"], "parameter name"), Default: d.optionalExpr(param["default"]), Rest: d.bool(param, "rest"),
+			Type: d.typeAnnotation(param["type"], "Param.type")})
+	}
+	return params
+}
+
+// typeAnnotation decodes an annotation, which is null when the annotated node has none.
+func (d *jsonDecoder) typeAnnotation(value interface{}, what string) *TypeAnnotation {
+	if value == nil {
+		return nil
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		d.fail("%s must be an array", what)
+		return nil
+	}
+	annotation := &TypeAnnotation{Names: make([]Token, 0, len(values))}
+	for _, name := range values {
+		annotation.Names = append(annotation.Names, d.token(name, what))
+	}
+	return annotation
+}
+
+func (d *jsonDecoder) stmt(value interface{}) Stmt {
+	if value == nil {
+		d.fail("missing statement")
+		return nil
+	}
+	return d.optionalStmt(value)
+}
+
+// optionalStmt decodes a statement which may be absent, null giving nil.
+func (d *jsonDecoder) optionalStmt(value interface{}) Stmt {
+	node := d.object(value, "statement", true)
+	if node == nil {
+		return nil
+	}
+	switch kind := node["node"]; kind {
+	case "Block":
+		return &Block{Statements: d.stmts(node, "statements")}
+	case "Expression":
+		return &Expression{Expression: d.expr(node["expression"])}
+	case "Function":
+		return &Function{Name: d.token(node["name"], "Function.name"), Params: d.params(node), Body: d.stmts(node, "body"), Generator: d.bool(node, "generator"),
+			ReturnType: d.typeAnnotation(node["returnType"], "Function.returnType"), Source: d.string(node, "source")}
+	case "If":
+		return &If{Keyword: d.token(node["keyword"], "If.keyword"), Condition: d.expr(node["condition"]), ThenBranch: d.stmt(node["thenBranch"]), ElseBranch: d.optionalStmt(node["elseBranch"])}
+	case "Print":
+		return &Print{Keyword: d.token(node["keyword"], "Print.keyword"), Expression: d.expr(node["expression"])}
+	case "Return":
+		return &Return{KeyWord: d.token(node["keyword"], "Return.keyword"), Value: d.optionalExpr(node["value"])}
+	case "Var":
+		return &Var{Name: d.token(node["name"], "Var.name"), Initializer: d.optionalExpr(node["initializer"]), Constant: d.bool(node, "constant"),
+			Type: d.typeAnnotation(node["type"], "Var.type")}
+	case "While":
+		return &While{Keyword: d.token(node["keyword"], "While.keyword"), Condition: d.expr(node["condition"]), Body: d.stmt(node["body"]), OptionalMutate: d.optionalExpr(node["optionalMutate"])}
+	case "Break":
+		return &Break{Keyword: d.token(node["keyword"], "Break.keyword")}
+	case "Continue":
+		return &Continue{Keyword: d.token(node["keyword"], "Continue.keyword")}
+	case "Match":
+		stmt := &Match{Keyword: d.token(node["keyword"], "Match.keyword"), Subject: d.expr(node["subject"])}
+		for _, value := range d.list(node, "cases") {
+			matchCase := d.object(value, "match case", false)
+			if matchCase == nil {
+				continue
+			}
+			patterns := make([]Pattern, 0)
+			for _, pattern := range d.list(matchCase, "patterns") {
+				patterns = append(patterns, d.pattern(pattern))
+			}
+			stmt.Cases = append(stmt.Cases, MatchCase{Patterns: patterns, Guard: d.optionalExpr(matchCase["guard"]), Body: d.stmt(matchCase["body"])})
+		}
+		return stmt
+	case "ForIn":
+		return &ForIn{Keyword: d.token(node["keyword"], "ForIn.keyword"), Name: d.token(node["name"], "ForIn.name"), Iterable: d.expr(node["iterable"]), Body: d.stmt(node["body"])}
+	case "Yield":
+		return &Yield{Keyword: d.token(node["keyword"], "Yield.keyword"), Value: d.optionalExpr(node["value"])}
+	case "Assert":
+		return &Assert{Keyword: d.token(node["keyword"], "Assert.keyword"), Condition: d.expr(node["condition"]), Message: d.optionalExpr(node["message"]), Source: d.string(node, "source")}
+	case "Test":
+		return &Test{Keyword: d.token(node["keyword"], "Test.keyword"), Name: d.string(node, "name"), Body: d.stmts(node, "body")}
+	default:
+		d.fail("unknown statement %v", kind)
+		return nil
+	}
+}
+
+func (d *jsonDecoder) expr(value interface{}) Expr {
+	if value == nil {
+		d.fail("missing expression")
+		return nil
+	}
+	return d.optionalExpr(value)
+}
+
+// optionalExpr decodes an expression which may be absent, null giving nil.
+func (d *jsonDecoder) optionalExpr(value interface{}) Expr {
+	node := d.object(value, "expression", true)
+	if node == nil {
+		return nil
+	}
+	switch kind := node["node"]; kind {
+	case "Binary":
+		return &Binary{Left: d.expr(node["left"]), Operator: d.token(node["operator"], "Binary.operator"), Right: d.expr(node["right"])}
+	case "Call":
+		return d.call(node)
+	case "Grouping":
+		return &Grouping{Expression: d.expr(node["expression"])}
+	case "Literal":
+		return &Literal{Type: d.tokenType(d.string(node, "type")), Value: d.value(node, "value")}
+	case "Logical":
+		return &Logical{Left: d.expr(node["left"]), Operator: d.token(node["operator"], "Logical.operator"), Right: d.expr(node["right"])}
+	case "Unary":
+		return &Unary{Operator: d.token(node["operator"], "Unary.operator"), Right: d.expr(node["right"])}
+	case "Variable":
+		return &Variable{Name: d.token(node["name"], "Variable.name")}
+	case "Assign":
+		return &Assign{Name: d.token(node["name"], "Assign.name"), Operator: d.token(node["operator"], "Assign.operator"), Value: d.expr(node["value"])}
+	case "Ternary":
+		return &Ternary{ConditionalExpr: d.expr(node["conditionalExpr"]), ThenExpr: d.expr(node["thenExpr"]), ElseExpr: d.expr(node["elseExpr"])}
+	case "FunctionExpr":
+		return &FunctionExpr{Params: d.params(node), Body: d.stmts(node, "body"), Generator: d.bool(node, "generator"),
+			ReturnType: d.typeAnnotation(node["returnType"], "FunctionExpr.returnType"), Source: d.string(node, "source")}
+	case "Update":
+		return &Update{Operator: d.token(node["operator"], "Update.operator"), Target: d.expr(node["target"]), Prefix: d.bool(node, "prefix")}
+	case "Spread":
+		return &Spread{Operator: d.token(node["operator"], "Spread.operator"), Expression: d.expr(node["expression"])}
+	case "NamedArg":
+		return &NamedArg{Name: d.token(node["name"], "NamedArg.name"), Value: d.expr(node["value"])}
+	case "Spawn":
+		call := d.object(node["call"], "Spawn.call", false)
+		if call == nil || call["node"] != "Call" {
+			d.fail("Spawn.call must be a Call")
+			return nil
+		}
+		return &Spawn{Keyword: d.token(node["keyword"], "Spawn.keyword"), Call: d.call(call)}
+	case "TypeCheck":
+		annotation := d.typeAnnotation(node["type"], "TypeCheck.type")
+		if annotation == nil {
+			d.fail("missing TypeCheck.type")
+		}
+		return &TypeCheck{Token: d.token(node["token"], "TypeCheck.token"), Expression: d.expr(node["expression"]), Type: annotation}
+	case "Map":
+		expr := &Map{Brace: d.token(node["brace"], "Map.brace"), Keys: d.exprs(node, "keys"), Values: d.exprs(node, "values")}
+		if len(expr.Keys) != len(expr.Values) {
+			d.fail("Map.keys and Map.values must have the same length")
+		}
+		return expr
+	default:
+		d.fail("unknown expression %v", kind)
+		return nil
+	}
+}
+
+func (d *jsonDecoder) call(node jsonNode) *Call {
+	return &Call{Callee: d.expr(node["callee"]), Paren: d.token(node["paren"], "Call.paren"), Arguments: d.exprs(node, "arguments")}
+}
+
+func (d *jsonDecoder) pattern(value interface{}) Pattern {
+	node := d.object(value, "pattern", false)
+	if node == nil {
+		return nil
+	}
+	switch kind := node["node"]; kind {
+	case "LiteralPattern":
+		return &LiteralPattern{Token: d.token(node["token"], "LiteralPattern.token"), Value: d.value(node, "value")}
+	case "BindingPattern":
+		return &BindingPattern{Name: d.token(node["name"], "BindingPattern.name")}
+	case "WildcardPattern":
+		return &WildcardPattern{Token: d.token(node["token"], "WildcardPattern.token")}
+	case "ListPattern":
+		pattern := &ListPattern{Bracket: d.token(node["bracket"], "ListPattern.bracket")}
+		for _, element := range d.list(node, "elements") {
+			pattern.Elements = append(pattern.Elements, d.pattern(element))
+		}
+		if node["rest"] != nil {
+			rest := d.token(node["rest"], "ListPattern.rest")
+			pattern.Rest = &rest
+		}
+		return pattern
+	case "MapPattern":
+		pattern := &MapPattern{Brace: d.token(node["brace"], "MapPattern.brace")}
+		for _, key := range d.list(node, "keys") {
+			pattern.Keys = append(pattern.Keys, d.pattern(key))
+		}
+		for _, value := range d.list(node, "values") {
+			pattern.Values = append(pattern.Values, d.pattern(value))
+		}
+		if len(pattern.Keys) != len(pattern.Values) {
+			d.fail("MapPattern.keys and MapPattern.values must have the same length")
+		}
+		return pattern
+	default:
+		d.fail("unknown pattern %v", kind)
+		return nil
+	}
+}
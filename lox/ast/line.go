@@ -0,0 +1,104 @@
+package ast
+
+// Line returns the line a node starts on, the line of its leftmost token, so that tools like the profiler can locate
+// statements. It is 0 for a node holding no token at all, like a lone literal or an empty block.
+func Line(node Node) int {
+	switch n := node.(type) {
+	case *Binary:
+		return first(Line(n.Left), n.Operator.Line)
+	case *Call:
+		return first(Line(n.Callee), n.Paren.Line)
+	case *Grouping:
+		return Line(n.Expression)
+	case *Logical:
+		return first(Line(n.Left), n.Operator.Line)
+	case *Unary:
+		return n.Operator.Line
+	case *Variable:
+		return n.Name.Line
+	case *Assign:
+		return n.Name.Line
+	case *Ternary:
+		return first(Line(n.ConditionalExpr), Line(n.ThenExpr), Line(n.ElseExpr))
+	case *FunctionExpr:
+		if len(n.Params) > 0 {
+			return n.Params[0].Name.Line
+		}
+		return lines(n.Body)
+	case *Update:
+		if n.Prefix {
+			return n.Operator.Line
+		}
+		return first(Line(n.Target), n.Operator.Line)
+	case *Spread:
+		return n.Operator.Line
+	case *NamedArg:
+		return n.Name.Line
+	case *Spawn:
+		return n.Keyword.Line
+	case *TypeCheck:
+		return first(Line(n.Expression), n.Token.Line)
+	case *Map:
+		return n.Brace.Line
+	case *Block:
+		return lines(n.Statements)
+	case *Expression:
+		return Line(n.Expression)
+	case *Function:
+		return n.Name.Line
+	case *If:
+		return n.Keyword.Line
+	case *Print:
+		return n.Keyword.Line
+	case *Return:
+		return n.KeyWord.Line
+	case *Var:
+		return n.Name.Line
+	case *While:
+		return n.Keyword.Line
+	case *Break:
+		return n.Keyword.Line
+	case *Continue:
+		return n.Keyword.Line
+	case *Match:
+		return n.Keyword.Line
+	case *ForIn:
+		return n.Keyword.Line
+	case *Yield:
+		return n.Keyword.Line
+	case *Assert:
+		return n.Keyword.Line
+	case *Test:
+		return n.Keyword.Line
+	case *LiteralPattern:
+		return n.Token.Line
+	case *BindingPattern:
+		return n.Name.Line
+	case *WildcardPattern:
+		return n.Token.Line
+	case *ListPattern:
+		return n.Bracket.Line
+	case *MapPattern:
+		return n.Brace.Line
+	}
+	return 0
+}
+
+// first returns the first of lines which is known.
+func first(lines ...int) int {
+	for _, line := range lines {
+		if line != 0 {
+			return line
+		}
+	}
+	return 0
+}
+
+func lines(statements []Stmt) int {
+	for _, statement := range statements {
+		if line := Line(statement); line != 0 {
+			return line
+		}
+	}
+	return 0
+}
@@ -0,0 +1,526 @@
+// Code generated by tool/genAST.go from ast.schema. DO NOT EDIT.
+
+package ast
+
+// Node is a node of the syntax tree: Expr, Stmt or Pattern.
+type Node interface{}
+
+// Walker is called by Walk for every node, see Walk.
+type Walker interface {
+	Visit(node Node) (w Walker)
+}
+
+// Walk traverses the tree rooted at node depth-first. It starts by calling w.Visit(node); unless that returns nil,
+// Walk then walks every child of node with the walker returned, and finally calls its Visit with nil.
+func Walk(w Walker, node Node) {
+	if w = w.Visit(node); w == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *Binary:
+		if n.Left != nil {
+			Walk(w, n.Left)
+		}
+		if n.Right != nil {
+			Walk(w, n.Right)
+		}
+	case *Call:
+		if n.Callee != nil {
+			Walk(w, n.Callee)
+		}
+		for _, child := range n.Arguments {
+			Walk(w, child)
+		}
+	case *Grouping:
+		if n.Expression != nil {
+			Walk(w, n.Expression)
+		}
+	case *Logical:
+		if n.Left != nil {
+			Walk(w, n.Left)
+		}
+		if n.Right != nil {
+			Walk(w, n.Right)
+		}
+	case *Unary:
+		if n.Right != nil {
+			Walk(w, n.Right)
+		}
+	case *Assign:
+		if n.Value != nil {
+			Walk(w, n.Value)
+		}
+	case *Ternary:
+		if n.ConditionalExpr != nil {
+			Walk(w, n.ConditionalExpr)
+		}
+		if n.ThenExpr != nil {
+			Walk(w, n.ThenExpr)
+		}
+		if n.ElseExpr != nil {
+			Walk(w, n.ElseExpr)
+		}
+	case *FunctionExpr:
+		for _, child := range n.Params {
+			walkParam(w, child)
+		}
+		for _, child := range n.Body {
+			Walk(w, child)
+		}
+	case *Update:
+		if n.Target != nil {
+			Walk(w, n.Target)
+		}
+	case *Spread:
+		if n.Expression != nil {
+			Walk(w, n.Expression)
+		}
+	case *NamedArg:
+		if n.Value != nil {
+			Walk(w, n.Value)
+		}
+	case *Spawn:
+		if n.Call != nil {
+			Walk(w, n.Call)
+		}
+	case *TypeCheck:
+		if n.Expression != nil {
+			Walk(w, n.Expression)
+		}
+	case *Map:
+		for _, child := range n.Keys {
+			Walk(w, child)
+		}
+		for _, child := range n.Values {
+			Walk(w, child)
+		}
+	case *Block:
+		for _, child := range n.Statements {
+			Walk(w, child)
+		}
+	case *Expression:
+		if n.Expression != nil {
+			Walk(w, n.Expression)
+		}
+	case *Function:
+		for _, child := range n.Params {
+			walkParam(w, child)
+		}
+		for _, child := range n.Body {
+			Walk(w, child)
+		}
+	case *If:
+		if n.Condition != nil {
+			Walk(w, n.Condition)
+		}
+		if n.ThenBranch != nil {
+			Walk(w, n.ThenBranch)
+		}
+		if n.ElseBranch != nil {
+			Walk(w, n.ElseBranch)
+		}
+	case *Print:
+		if n.Expression != nil {
+			Walk(w, n.Expression)
+		}
+	case *Return:
+		if n.Value != nil {
+			Walk(w, n.Value)
+		}
+	case *Var:
+		if n.Initializer != nil {
+			Walk(w, n.Initializer)
+		}
+	case *While:
+		if n.Condition != nil {
+			Walk(w, n.Condition)
+		}
+		if n.Body != nil {
+			Walk(w, n.Body)
+		}
+		if n.OptionalMutate != nil {
+			Walk(w, n.OptionalMutate)
+		}
+	case *Match:
+		if n.Subject != nil {
+			Walk(w, n.Subject)
+		}
+		for _, child := range n.Cases {
+			walkMatchCase(w, child)
+		}
+	case *ForIn:
+		if n.Iterable != nil {
+			Walk(w, n.Iterable)
+		}
+		if n.Body != nil {
+			Walk(w, n.Body)
+		}
+	case *Yield:
+		if n.Value != nil {
+			Walk(w, n.Value)
+		}
+	case *Assert:
+		if n.Condition != nil {
+			Walk(w, n.Condition)
+		}
+		if n.Message != nil {
+			Walk(w, n.Message)
+		}
+	case *Test:
+		for _, child := range n.Body {
+			Walk(w, child)
+		}
+	case *ListPattern:
+		for _, child := range n.Elements {
+			Walk(w, child)
+		}
+	case *MapPattern:
+		for _, child := range n.Keys {
+			Walk(w, child)
+		}
+		for _, child := range n.Values {
+			Walk(w, child)
+		}
+	}
+	w.Visit(nil)
+}
+
+func walkParam(w Walker, n Param) {
+	if n.Default != nil {
+		Walk(w, n.Default)
+	}
+}
+
+func walkMatchCase(w Walker, n MatchCase) {
+	for _, child := range n.Patterns {
+		Walk(w, child)
+	}
+	if n.Guard != nil {
+		Walk(w, n.Guard)
+	}
+	if n.Body != nil {
+		Walk(w, n.Body)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Walker {
+	if node != nil && f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node depth-first, calling f for every node. The children of a node are
+// skipped when f returns false for it.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Transform rewrites the tree rooted at node bottom-up and returns the new root. The children of a node are
+// transformed first and replaced by the results, then f is called on the node itself and its result takes the
+// node's place. f returns its argument to keep a node, or another node of the same kind: an Expr for an Expr, a
+// *Call for a *Call and so on. Returning nil removes a node from a list and clears an optional field. The tree is
+// rewritten in place.
+func Transform(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+	case *Binary:
+		n.Left = transformExpr(n.Left, f)
+		n.Right = transformExpr(n.Right, f)
+	case *Call:
+		n.Callee = transformExpr(n.Callee, f)
+		n.Arguments = transformExprList(n.Arguments, f)
+	case *Grouping:
+		n.Expression = transformExpr(n.Expression, f)
+	case *Logical:
+		n.Left = transformExpr(n.Left, f)
+		n.Right = transformExpr(n.Right, f)
+	case *Unary:
+		n.Right = transformExpr(n.Right, f)
+	case *Assign:
+		n.Value = transformExpr(n.Value, f)
+	case *Ternary:
+		n.ConditionalExpr = transformExpr(n.ConditionalExpr, f)
+		n.ThenExpr = transformExpr(n.ThenExpr, f)
+		n.ElseExpr = transformExpr(n.ElseExpr, f)
+	case *FunctionExpr:
+		for index := range n.Params {
+			transformParam(&n.Params[index], f)
+		}
+		n.Body = transformStmtList(n.Body, f)
+	case *Update:
+		n.Target = transformExpr(n.Target, f)
+	case *Spread:
+		n.Expression = transformExpr(n.Expression, f)
+	case *NamedArg:
+		n.Value = transformExpr(n.Value, f)
+	case *Spawn:
+		if n.Call != nil {
+			n.Call, _ = Transform(n.Call, f).(*Call)
+		}
+	case *TypeCheck:
+		n.Expression = transformExpr(n.Expression, f)
+	case *Map:
+		n.Keys = transformExprList(n.Keys, f)
+		n.Values = transformExprList(n.Values, f)
+	case *Block:
+		n.Statements = transformStmtList(n.Statements, f)
+	case *Expression:
+		n.Expression = transformExpr(n.Expression, f)
+	case *Function:
+		for index := range n.Params {
+			transformParam(&n.Params[index], f)
+		}
+		n.Body = transformStmtList(n.Body, f)
+	case *If:
+		n.Condition = transformExpr(n.Condition, f)
+		n.ThenBranch = transformStmt(n.ThenBranch, f)
+		n.ElseBranch = transformStmt(n.ElseBranch, f)
+	case *Print:
+		n.Expression = transformExpr(n.Expression, f)
+	case *Return:
+		n.Value = transformExpr(n.Value, f)
+	case *Var:
+		n.Initializer = transformExpr(n.Initializer, f)
+	case *While:
+		n.Condition = transformExpr(n.Condition, f)
+		n.Body = transformStmt(n.Body, f)
+		n.OptionalMutate = transformExpr(n.OptionalMutate, f)
+	case *Match:
+		n.Subject = transformExpr(n.Subject, f)
+		for index := range n.Cases {
+			transformMatchCase(&n.Cases[index], f)
+		}
+	case *ForIn:
+		n.Iterable = transformExpr(n.Iterable, f)
+		n.Body = transformStmt(n.Body, f)
+	case *Yield:
+		n.Value = transformExpr(n.Value, f)
+	case *Assert:
+		n.Condition = transformExpr(n.Condition, f)
+		n.Message = transformExpr(n.Message, f)
+	case *Test:
+		n.Body = transformStmtList(n.Body, f)
+	case *ListPattern:
+		n.Elements = transformPatternList(n.Elements, f)
+	case *MapPattern:
+		n.Keys = transformPatternList(n.Keys, f)
+		n.Values = transformPatternList(n.Values, f)
+	}
+	return f(node)
+}
+
+func transformExpr(node Expr, f func(Node) Node) Expr {
+	if node == nil {
+		return nil
+	}
+	result, _ := Transform(node, f).(Expr)
+	return result
+}
+
+func transformExprList(nodes []Expr, f func(Node) Node) []Expr {
+	result := nodes[:0]
+	for _, node := range nodes {
+		if node = transformExpr(node, f); node != nil {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func transformStmt(node Stmt, f func(Node) Node) Stmt {
+	if node == nil {
+		return nil
+	}
+	result, _ := Transform(node, f).(Stmt)
+	return result
+}
+
+func transformStmtList(nodes []Stmt, f func(Node) Node) []Stmt {
+	result := nodes[:0]
+	for _, node := range nodes {
+		if node = transformStmt(node, f); node != nil {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func transformPattern(node Pattern, f func(Node) Node) Pattern {
+	if node == nil {
+		return nil
+	}
+	result, _ := Transform(node, f).(Pattern)
+	return result
+}
+
+func transformPatternList(nodes []Pattern, f func(Node) Node) []Pattern {
+	result := nodes[:0]
+	for _, node := range nodes {
+		if node = transformPattern(node, f); node != nil {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+func transformParam(n *Param, f func(Node) Node) {
+	n.Default = transformExpr(n.Default, f)
+}
+
+func transformMatchCase(n *MatchCase, f func(Node) Node) {
+	n.Patterns = transformPatternList(n.Patterns, f)
+	n.Guard = transformExpr(n.Guard, f)
+	n.Body = transformStmt(n.Body, f)
+}
+
+// BaseVisitor implements every visitor interface by doing nothing. Embedding it lets a visitor implement only
+// the methods it cares about.
+type BaseVisitor struct{}
+
+func (BaseVisitor) VisitBinaryExpr(*Binary) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitCallExpr(*Call) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitGroupingExpr(*Grouping) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitLiteralExpr(*Literal) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitLogicalExpr(*Logical) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitUnaryExpr(*Unary) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitVariableExpr(*Variable) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitAssignExpr(*Assign) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitTernaryExpr(*Ternary) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitFunctionExpr(*FunctionExpr) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitUpdateExpr(*Update) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitSpreadExpr(*Spread) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitNamedArgExpr(*NamedArg) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitSpawnExpr(*Spawn) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitTypeCheckExpr(*TypeCheck) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitMapExpr(*Map) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitBlockStmt(*Block) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitExpressionStmt(*Expression) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitFunctionStmt(*Function) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitIfStmt(*If) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitPrintStmt(*Print) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitReturnStmt(*Return) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitVarStmt(*Var) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitWhileStmt(*While) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitBreakStmt(*Break) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitContinueStmt(*Continue) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitMatchStmt(*Match) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitForInStmt(*ForIn) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitYieldStmt(*Yield) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitAssertStmt(*Assert) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitTestStmt(*Test) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitLiteralPattern(*LiteralPattern) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitBindingPattern(*BindingPattern) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitWildcardPattern(*WildcardPattern) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitListPattern(*ListPattern) (interface{}, error) {
+	return nil, nil
+}
+
+func (BaseVisitor) VisitMapPattern(*MapPattern) (interface{}, error) {
+	return nil, nil
+}
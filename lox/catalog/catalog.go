@@ -0,0 +1,317 @@
+// Package catalog is the registry of the codes of the diagnostics golox reports, like LOX-P004 for a missing
+// semicolon. Codes are stable, so that documentation and CI annotations can refer to them: a code is never reused for
+// another mistake, and messages may change without their code changing. The letter after LOX- tells which stage
+// usually finds the mistake: L for the lexer, P for the parser, R for the resolver, T for the type checker and E for
+// the interpreter. Several messages share a code when they are the same mistake, like the many missing semicolons.
+package catalog
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	UnexpectedCharacter   = "LOX-L001"
+	UnterminatedString    = "LOX-L002"
+	InvalidNumber         = "LOX-L003"
+	ExpectExpression      = "LOX-P001"
+	MissingClosing        = "LOX-P002"
+	MissingOpening        = "LOX-P003"
+	MissingSemicolon      = "LOX-P004"
+	ExpectName            = "LOX-P005"
+	InvalidTarget         = "LOX-P006"
+	TooManyArguments      = "LOX-P007"
+	ParameterOrder        = "LOX-P008"
+	ArgumentOrder         = "LOX-P009"
+	ConstantInitializer   = "LOX-P010"
+	MatchSyntax           = "LOX-P011"
+	SpawnWithoutCall      = "LOX-P012"
+	TernaryColon          = "LOX-P013"
+	TopLevelReturn        = "LOX-R001"
+	GeneratorReturn       = "LOX-R002"
+	YieldOutsideFunction  = "LOX-R003"
+	NestedTest            = "LOX-R004"
+	JumpOutsideLoop       = "LOX-R005"
+	DuplicateBinding      = "LOX-R006"
+	OwnInitializer        = "LOX-R007"
+	Redeclaration         = "LOX-R008"
+	AssignConstant        = "LOX-R009"
+	AlternativeBindings   = "LOX-R010"
+	UnknownType           = "LOX-T001"
+	TypeMismatch          = "LOX-T002"
+	GeneratorReturnType   = "LOX-T003"
+	MissingReturn         = "LOX-T004"
+	Internal              = "LOX-E000"
+	UndefinedVariable     = "LOX-E001"
+	OperandType           = "LOX-E002"
+	NotCallable           = "LOX-E003"
+	ArgumentCount         = "LOX-E004"
+	NamedArgument         = "LOX-E005"
+	MisplacedSpread       = "LOX-E006"
+	StackOverflow         = "LOX-E007"
+	StepLimit             = "LOX-E008"
+	NotIterable           = "LOX-E009"
+	GeneratorMisuse       = "LOX-E010"
+	NonExhaustiveMatch    = "LOX-E011"
+	AssertionFailed       = "LOX-E012"
+	InvalidNativeArgument = "LOX-E013"
+	ClosedChannel         = "LOX-E014"
+	Deadlock              = "LOX-E015"
+	InvalidMapKey         = "LOX-E016"
+)
+
+// Entry documents a code: what the mistake is, an Example of it and the Fixed example.
+type Entry struct {
+	Code        string
+	Title       string
+	Explanation string
+	Example     string
+	Fixed       string
+}
+
+// Lookup returns the entry of code.
+func Lookup(code string) (Entry, bool) {
+	entry, ok := entries[code]
+	return entry, ok
+}
+
+// Entries returns all the entries ordered by code.
+func Entries() []Entry {
+	list := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Code < list[j].Code
+	})
+	return list
+}
+
+// Explain formats entry for reading in a terminal: its code and title, the explanation wrapped at 100 columns and
+// the example with its fix, indented.
+func Explain(entry Entry) string {
+	var builder strings.Builder
+	builder.WriteString(entry.Code + ": " + entry.Title + "\n\n")
+	line := 0
+	for _, word := range strings.Fields(entry.Explanation) {
+		if line > 0 && line+1+len(word) > 100 {
+			builder.WriteString("\n")
+			line = 0
+		} else if line > 0 {
+			builder.WriteString(" ")
+			line++
+		}
+		builder.WriteString(word)
+		line += len(word)
+	}
+	builder.WriteString("\n")
+	if entry.Example != "" {
+		builder.WriteString("\nFor example:\n\n" + indent(entry.Example) + "\nFixed:\n\n" + indent(entry.Fixed))
+	}
+	return builder.String()
+}
+
+func indent(code string) string {
+	var builder strings.Builder
+	for _, line := range strings.Split(code, "\n") {
+		builder.WriteString("    " + line + "\n")
+	}
+	return builder.String()
+}
+
+var entries = make(map[string]Entry)
+
+func add(code string, title string, explanation string, example string, fixed string) {
+	entries[code] = Entry{Code: code, Title: title, Explanation: explanation, Example: example, Fixed: fixed}
+}
+
+func init() {
+	add(UnexpectedCharacter, "Unexpected character",
+		"The source holds a character which starts no token of Lox, like '@' or '#'. Comments start with //.",
+		"var total = 1 # the total", "var total = 1; // the total")
+	add(UnterminatedString, "Unterminated string",
+		"A string literal runs to the end of the file because its closing double quote is missing. Strings may span "+
+			"several lines, so the error is reported where the file ends.",
+		"print \"hello;", "print \"hello\";")
+	add(InvalidNumber, "Invalid number literal",
+		"A number literal can't be read as a number because it is too large: numbers are 64-bit floats, which "+
+			"don't go beyond 309 digits.",
+		"print 1000...000; // 1 followed by 400 zeros", "print 1000...000; // 1 followed by 300 zeros")
+
+	add(ExpectExpression, "Expected an expression",
+		"The parser expected a value, like a literal, a variable, a call or an operator applied to them, but found "+
+			"something else: a binary operator with no left operand, a closing delimiter or the end of the file.",
+		"var x = * 2;", "var x = 3 * 2;")
+	add(MissingClosing, "Missing closing delimiter",
+		"A parenthesis, brace or bracket is never closed where the construct requires it, like at the end of the "+
+			"arguments of a call, of a block or of a list pattern.",
+		"print max(1, 2;", "print max(1, 2);")
+	add(MissingOpening, "Missing opening delimiter",
+		"A construct requires an opening parenthesis or brace: the condition of if, while and for and the subject "+
+			"of match are parenthesized, and the bodies of functions, tests and match statements are braced.",
+		"if x > 1 print x;", "if (x > 1) print x;")
+	add(MissingSemicolon, "Missing semicolon",
+		"Every statement which doesn't end with a block ends with a semicolon: expression statements, print, var, "+
+			"return, yield, assert, break and continue. A missing one is reported at the token following the statement, "+
+			"often on the next line. If the statement starts with a misspelled keyword the error suggests the keyword.",
+		"print \"hello\"\nprint \"world\";", "print \"hello\";\nprint \"world\";")
+	add(ExpectName, "Expected a name",
+		"A declaration lacks the name of the variable, function or parameter it declares, or a type annotation the "+
+			"name of a type. Keywords can't be used as names.",
+		"var class = 1;", "var kind = 1;")
+	add(InvalidTarget, "Invalid assignment target",
+		"Only variables can be assigned to, incremented or decremented.",
+		"1 = x;\n(a + b)++;", "x = 1;\na++;")
+	add(TooManyArguments, "Too many arguments or parameters",
+		"A function can declare at most 255 parameters and a call pass at most 255 arguments. Pass a list instead, "+
+			"with a rest parameter collecting it.",
+		"fun f(a1, a2, a3, ..., a256) {}", "fun f(...values) {}")
+	add(ParameterOrder, "Parameters in the wrong order",
+		"The parameters of a function come in order: the required ones, then the ones with a default value, then "+
+			"an optional rest parameter, which has no default value.",
+		"fun f(a = 1, b) {}\nfun g(...rest, last) {}", "fun f(b, a = 1) {}\nfun g(last, ...rest) {}")
+	add(ArgumentOrder, "Arguments in the wrong order",
+		"Named arguments follow the positional ones, and each parameter is named at most once.",
+		"f(a: 1, 2);\nf(a: 1, a: 2);", "f(2, a: 1);\nf(a: 2);")
+	add(ConstantInitializer, "Constant without a value",
+		"A constant declared with const or let can never be assigned, so it must be initialized where it is "+
+			"declared.",
+		"const limit;", "const limit = 10;")
+	add(MatchSyntax, "Malformed match statement",
+		"A match statement holds at least one `case pattern => statement`. Patterns are literals, negative numbers, "+
+			"names, _ or list patterns like [first, ...rest].",
+		"match (x) { 1 => print \"one\"; }", "match (x) { case 1 => print \"one\"; }")
+	add(SpawnWithoutCall, "Spawn without a call",
+		"spawn runs a call as a task, so it must be followed by a call expression.",
+		"var task = spawn worker;", "var task = spawn worker();")
+	add(TernaryColon, "Missing colon in a conditional expression",
+		"A conditional expression has both branches: `condition ? then : otherwise`.",
+		"var sign = x < 0 ? -1;", "var sign = x < 0 ? -1 : 1;")
+
+	add(TopLevelReturn, "Return outside of a function",
+		"return leaves a function, so it can't be used in the top-level code of a script. Use an if statement to "+
+			"skip the rest of the script instead.",
+		"if (done) return;\nwork();", "if (!done) work();")
+	add(GeneratorReturn, "Return with a value in a generator",
+		"A generator produces its values with yield. It may return early, but without a value.",
+		"fun* numbers() { return 1; }", "fun* numbers() { yield 1; return; }")
+	add(YieldOutsideFunction, "Yield outside of a function",
+		"yield produces a value of a generator, so it can only be used in the body of a function, which it turns "+
+			"into a generator.",
+		"yield 1;", "fun* one() { yield 1; }")
+	add(NestedTest, "Test block not at the top level",
+		"test blocks are collected from the top level of a script by golox test, they can't be nested in functions, "+
+			"blocks or other tests.",
+		"fun check() { test \"sum\" { assert 1 + 1 == 2; } }", "test \"sum\" { assert 1 + 1 == 2; }")
+	add(JumpOutsideLoop, "Break or continue outside of a loop",
+		"break and continue jump out of or to the next iteration of the innermost loop, so they must be inside one, "+
+			"and not in a function declared inside the loop.",
+		"if (done) break;", "while (!done) { if (check()) break; }")
+	add(DuplicateBinding, "Name bound twice in a pattern",
+		"A pattern binds each name once; use _ for parts of the value which don't matter.",
+		"match (pair) { case [x, x] => print x; }", "match (pair) { case [x, _] => print x; }")
+	add(OwnInitializer, "Local variable read in its own initializer",
+		"A local variable can't be used to compute its own initial value, it isn't defined yet. Inside a block, "+
+			"this is often meant to read a variable of an enclosing scope with the same name: give them different "+
+			"names.",
+		"var a = 1;\n{ var a = a + 1; }", "var a = 1;\n{ var b = a + 1; }")
+	add(Redeclaration, "Variable declared twice",
+		"A scope can't declare the same local variable twice, and a constant can't be declared again at all. "+
+			"Assign to the existing variable, or use another name.",
+		"{ var x = 1; var x = 2; }", "{ var x = 1; x = 2; }")
+	add(AssignConstant, "Assignment to a constant",
+		"A constant declared with const or let can't be assigned. The resolver rejects assignments to constants it "+
+			"knows about, the interpreter those to global constants it learns about when the program runs.",
+		"const limit = 10;\nlimit = 20;", "var limit = 10;\nlimit = 20;")
+	add(AlternativeBindings, "Alternatives binding different names",
+		"The body of a case runs with the variables of the alternative which matched, so every alternative of a case "+
+			"must bind the same names. Use _ where an alternative has nothing to bind, or split the case.",
+		"match (2) { case [a], 2 => print a; }", "match (2) { case [a] => print a; case 2 => print 2; }")
+
+	add(UnknownType, "Unknown type",
+		"A type annotation names a type which doesn't exist. The types are any, number, string, bool, nil, list, "+
+			"function, range, generator, task and channel, combined with | or made nullable with ?.",
+		"var n: int = 1;", "var n: number = 1;")
+	add(TypeMismatch, "Type mismatch",
+		"A value doesn't have the type annotated on the variable, parameter or return value it flows into. The type "+
+			"checker reports the mismatches it can prove, the interpreter checks the values whose type is only known "+
+			"at runtime.",
+		"var name: string = 42;", "var name: string = \"42\";")
+	add(GeneratorReturnType, "Return type on a generator",
+		"Calling a generator function always returns a generator, so it can't declare a return type.",
+		"fun* numbers(): number { yield 1; }", "fun* numbers() { yield 1; }")
+	add(MissingReturn, "Missing return",
+		"A function declaring a return type which doesn't allow nil must return a value on every path; falling off "+
+			"the end of its body returns nil.",
+		"fun sign(x): number { if (x < 0) return -1; }", "fun sign(x): number { if (x < 0) return -1; return 1; }")
+
+	add(Internal, "Internal error",
+		"The interpreter reached a state which should be impossible. Please report it along with the script.",
+		"", "")
+	add(UndefinedVariable, "Undefined variable",
+		"A variable is read or assigned but no variable with this name is defined. Global variables are looked up "+
+			"when the code runs, so a function may use a global declared after it, as long as it is called after the "+
+			"declaration ran. In strict mode, set by a first statement \"use strict\"; or golox run -strict, the "+
+			"resolver reports a global which the program never declares before it runs. The error suggests a defined "+
+			"name or a keyword which is close in spelling.",
+		"var length = 3;\nprint lenght;", "var length = 3;\nprint length;")
+	add(OperandType, "Operand of the wrong type",
+		"Arithmetic and comparison operators apply to numbers. + also concatenates strings, converting a number "+
+			"added to a string, but no other value. Convert or check the values before combining them.",
+		"print true + \" answers\";", "print \"true answers\";")
+	add(NotCallable, "Call of a value which isn't a function",
+		"Only functions and natives can be called.",
+		"var count = 3;\ncount();", "fun count() { return 3; }\ncount();")
+	add(ArgumentCount, "Wrong number of arguments",
+		"A call passes fewer arguments than the function has required parameters, or more than it has parameters "+
+			"and no rest parameter collects them.",
+		"fun add(a, b) { return a + b; }\nadd(1);", "fun add(a, b) { return a + b; }\nadd(1, 2);")
+	add(NamedArgument, "Invalid named argument",
+		"Named arguments are bound to the parameters of Lox functions with the same name, so they must name such a "+
+			"parameter which isn't given by position too. Natives only take positional arguments.",
+		"fun greet(name) { print name; }\ngreet(nam: \"Reader\");", "fun greet(name) { print name; }\ngreet(name: \"Reader\");")
+	add(MisplacedSpread, "Invalid spread or named argument",
+		"Only lists can be spread in the arguments of a call. Spread and named arguments are only allowed in calls, "+
+			"which the parser ensures but a syntax tree built otherwise, like one decoded from JSON, may not.",
+		"fun sum(...numbers) {}\nsum(...1);", "fun sum(...numbers) {}\nsum(1);")
+	add(StackOverflow, "Stack overflow",
+		"Function calls are nested more than 10000 deep, usually because a recursive function lacks a base case.",
+		"fun count(n) { return count(n + 1); }\ncount(0);",
+		"fun count(n) { if (n == 10) return n; return count(n + 1); }\ncount(0);")
+	add(StepLimit, "Step limit exceeded",
+		"The program executed more statements than the limit it runs with, like the one golox test sets to stop "+
+			"scripts looping forever.",
+		"while (true) {}", "while (!done()) {}")
+	add(NotIterable, "Value can't be iterated",
+		"for-in loops iterate over strings, lists, maps, ranges, generators and functions taking no argument. A generator "+
+			"function must be called to create the generator.",
+		"fun* numbers() { yield 1; }\nfor (n in numbers) print n;", "fun* numbers() { yield 1; }\nfor (n in numbers()) print n;")
+	add(GeneratorMisuse, "Invalid use of a generator",
+		"A generator can't be resumed from its own body, and yield only works in the body of a generator.",
+		"", "")
+	add(NonExhaustiveMatch, "No case matches",
+		"None of the cases of a match statement matches the subject. Add a case for the value, or a final case _ "+
+			"matching everything.",
+		"var x = 2;\nmatch (x) { case 1 => print \"one\"; }",
+		"var x = 2;\nmatch (x) { case 1 => print \"one\"; case _ => print \"other\"; }")
+	add(AssertionFailed, "Assertion failed",
+		"The condition of an assert statement is false. The error shows the condition as written, the operands "+
+			"of a failed comparison and the message of the assertion.",
+		"assert 1 + 1 == 3, \"arithmetic\";", "assert 1 + 1 == 2, \"arithmetic\";")
+	add(InvalidNativeArgument, "Invalid argument of a native",
+		"A native function got an argument it can't use, like a non-channel passed to send() or a step of zero "+
+			"passed to range().",
+		"for (i in range(0, 10, 0)) print i;", "for (i in range(0, 10, 1)) print i;")
+	add(ClosedChannel, "Use of a closed channel",
+		"A closed channel can still be received from, but not sent to or closed again.",
+		"var c = channel(1);\nclose(c);\nsend(c, 1);", "var c = channel(1);\nsend(c, 1);\nclose(c);")
+	add(Deadlock, "Deadlock",
+		"Every task of the program waits for a channel or for another task, so none of them can ever go on, like a "+
+			"receive from a channel nobody sends to or a send on an unbuffered channel nobody receives from. The "+
+			"operation which would wait fails instead.",
+		"var c = channel();\nsend(c, 1);\nprint receive(c);", "var c = channel(1);\nsend(c, 1);\nprint receive(c);")
+	add(InvalidMapKey, "Invalid map key",
+		"The keys of a map literal must be nil, booleans, numbers or strings, the values a map pattern can look up. "+
+			"Other values, like lists or functions, can only be map values.",
+		"fun f() {}\nprint {f: 1};", "fun f() {}\nprint {\"f\": f};")
+}
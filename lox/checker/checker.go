@@ -0,0 +1,627 @@
+// Package checker is the gradual type checker. It runs after the resolver and checks the values flowing into
+// annotated variables, parameters and return values: a value whose type is known statically is checked at compile
+// time, a value of unknown type, e.g. one coming from unannotated code, is wrapped in an ast.TypeCheck which checks
+// it at runtime. A program without annotations is left as it is.
+package checker
+
+import (
+	"errors"
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/interpreter"
+	"golox/lox/lexer"
+	"golox/utils"
+)
+
+// binding is what the checker knows about a declared name.
+type binding struct {
+	key        interface{}         // the declaring node, identifying the binding across both passes
+	annotation *ast.TypeAnnotation // nil for an unannotated binding
+	declared   typeSet             // the annotated type
+	inferred   typeSet             // the type of the initial value of an unannotated binding
+	assigned   bool                // assigned after its declaration, unannotated it may then hold anything
+	signature  *signature          // the function a `fun` declaration binds
+}
+
+// staticType is the type of the values the binding may hold.
+func (b *binding) staticType() typeSet {
+	if b.annotation != nil {
+		return b.declared
+	}
+	if b.assigned {
+		return nil
+	}
+	return b.inferred
+}
+
+// signature is the part of a function declaration its calls are checked against.
+type signature struct {
+	params     []ast.Param
+	returnType *ast.TypeAnnotation
+	generator  bool
+}
+
+// function is the function whose body is being checked.
+type function struct {
+	returnType *ast.TypeAnnotation
+	declared   typeSet
+}
+
+type Checker struct {
+	scopes     []map[string]*binding // scopes[0] holds the globals
+	assigned   map[interface{}]bool  // the keys of the bindings assigned somewhere, found by the first pass
+	collecting bool                  // the first pass only collects assignments
+	current    *function
+	hadError   bool
+	reporter   *utils.Reporter
+}
+
+func NewChecker() *Checker {
+	return &Checker{assigned: make(map[interface{}]bool), reporter: utils.DefaultReporter}
+}
+
+// SetReporter sets where type errors are reported.
+func (c *Checker) SetReporter(reporter *utils.Reporter) {
+	c.reporter = reporter
+}
+
+// Check checks a resolved program and inserts the runtime checks it needs. It takes two passes: the first finds the
+// variables which are assigned, the type of the others is the one of their initializer.
+func (c *Checker) Check(statements []ast.Stmt) error {
+	c.collecting = true
+	c.checkProgram(statements)
+	c.collecting = false
+	c.checkProgram(statements)
+	if c.hadError {
+		return errors.New("type error")
+	}
+	return nil
+}
+
+// checkProgram declares every global before checking the statements, so that a function assigning a global
+// declared after it is found by the first pass.
+func (c *Checker) checkProgram(statements []ast.Stmt) {
+	declarations := make(map[string]int)
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *ast.Var:
+			declarations[s.Name.Lexeme]++
+		case *ast.Function:
+			declarations[s.Name.Lexeme]++
+		}
+	}
+	globals := make(map[string]*binding)
+	for _, statement := range statements {
+		switch s := statement.(type) {
+		case *ast.Var:
+			globals[s.Name.Lexeme] = c.newBinding(s, s.Type)
+		case *ast.Function:
+			globals[s.Name.Lexeme] = c.newBinding(s, nil)
+		}
+	}
+	for name, count := range declarations {
+		// nothing is known about a global declared several times, each declaration may be the one in effect
+		if count > 1 {
+			globals[name] = &binding{assigned: true}
+		}
+	}
+	c.scopes = []map[string]*binding{globals}
+	c.current = nil
+	c.stmts(statements)
+}
+
+func (c *Checker) newBinding(key interface{}, annotation *ast.TypeAnnotation) *binding {
+	return &binding{key: key, annotation: annotation, declared: c.typeOf(annotation), assigned: c.assigned[key]}
+}
+
+// typeOf is the type an annotation names, unknown for a missing annotation or one including `any`. Names which
+// aren't types are left out, checkAnnotation reports them.
+func (c *Checker) typeOf(annotation *ast.TypeAnnotation) typeSet {
+	if annotation == nil {
+		return nil
+	}
+	result := make(typeSet)
+	for _, name := range annotation.Names {
+		switch {
+		case name.Type0 == lexer.NIL:
+			result["nil"] = true
+		case name.Lexeme == "any":
+			return nil
+		case isTypeName(name.Lexeme):
+			result[name.Lexeme] = true
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// checkAnnotation reports the names in an annotation which aren't types.
+func (c *Checker) checkAnnotation(annotation *ast.TypeAnnotation) {
+	if annotation == nil {
+		return
+	}
+	for _, name := range annotation.Names {
+		if name.Type0 != lexer.NIL && name.Lexeme != "any" && !isTypeName(name.Lexeme) {
+			c.error(name, catalog.UnknownType, "Unknown type '"+name.Lexeme+"'.")
+		}
+	}
+}
+
+func isTypeName(name string) bool {
+	for _, typeName := range interpreter.TypeNames {
+		if typeName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// declare binds a name in the innermost scope. The globals are already declared by checkProgram, unless they were
+// declared several times.
+func (c *Checker) declare(name lexer.Token, b *binding) {
+	scope := c.scopes[len(c.scopes)-1]
+	if len(c.scopes) == 1 {
+		if global, ok := scope[name.Lexeme]; ok && global.key == nil {
+			return
+		}
+	}
+	scope[name.Lexeme] = b
+}
+
+// lookup finds the binding a name refers to, nil for an undeclared global such as a native function.
+func (c *Checker) lookup(name lexer.Token) *binding {
+	for index := len(c.scopes) - 1; index >= 0; index-- {
+		if b, ok := c.scopes[index][name.Lexeme]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// global returns the binding checkProgram declared for a top-level declaration.
+func (c *Checker) global(key interface{}, name lexer.Token) *binding {
+	if len(c.scopes) == 1 {
+		if b, ok := c.scopes[0][name.Lexeme]; ok && b.key == key {
+			return b
+		}
+	}
+	return nil
+}
+
+func (c *Checker) markAssigned(name lexer.Token) {
+	if b := c.lookup(name); b != nil && b.key != nil && c.collecting {
+		c.assigned[b.key] = true
+	}
+}
+
+func (c *Checker) beginScope() {
+	c.scopes = append(c.scopes, make(map[string]*binding))
+}
+
+func (c *Checker) endScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *Checker) error(token lexer.Token, code string, message string) {
+	if c.collecting {
+		return
+	}
+	c.hadError = true
+	c.reporter.Diagnose(utils.Diagnostic{Line: token.Line, Where: "at '" + token.Lexeme + "'", Code: code, Message: message})
+}
+
+// coerce checks a value of type actual flowing into a binding annotated with annotation: a value which can't have
+// the annotated type is an error, a value which may have it is wrapped in a runtime check. expr is returned with
+// the check inserted.
+func (c *Checker) coerce(expr ast.Expr, actual typeSet, annotation *ast.TypeAnnotation, declared typeSet, token lexer.Token) ast.Expr {
+	switch {
+	case actual.subsetOf(declared):
+	case actual.disjoint(declared):
+		c.error(token, catalog.TypeMismatch, "Expected "+annotation.String()+" but got "+actual.String()+".")
+	case expr != nil && !c.collecting:
+		return &ast.TypeCheck{Token: token, Expression: expr, Type: annotation}
+	}
+	return expr
+}
+
+func (c *Checker) expr(expr ast.Expr) typeSet {
+	result, _ := expr.Accept(c)
+	if t, ok := result.(typeSet); ok {
+		return t
+	}
+	return nil
+}
+
+func (c *Checker) stmt(stmt ast.Stmt) {
+	_, _ = stmt.Accept(c)
+}
+
+func (c *Checker) stmts(statements []ast.Stmt) {
+	for _, statement := range statements {
+		c.stmt(statement)
+	}
+}
+
+// checkFunction checks the parameters and body of a function in a scope of their own, like the resolver.
+func (c *Checker) checkFunction(params []ast.Param, body []ast.Stmt, returnType *ast.TypeAnnotation, generator bool, name lexer.Token) {
+	enclosing := c.current
+	c.current = &function{returnType: returnType, declared: c.typeOf(returnType)}
+	defer func() {
+		c.current = enclosing
+	}()
+	c.checkAnnotation(returnType)
+	if returnType != nil && generator {
+		c.error(returnType.Names[0], catalog.GeneratorReturnType, "A generator can't declare a return type.")
+		c.current.returnType = nil
+	}
+	c.beginScope()
+	for index := range params {
+		param := &params[index]
+		c.checkAnnotation(param.Type)
+		b := c.newBinding(param, param.Type)
+		if param.Rest {
+			// the annotation of a rest parameter is the type of its elements
+			b = &binding{key: param, inferred: single("list"), assigned: c.assigned[param]}
+		}
+		if param.Default != nil {
+			actual := c.expr(param.Default)
+			if param.Type != nil && actual.disjoint(b.declared) {
+				c.error(param.Name, catalog.TypeMismatch, "Expected "+param.Type.String()+" for argument '"+param.Name.Lexeme+"' but got "+actual.String()+".")
+			}
+		}
+		c.declare(param.Name, b)
+	}
+	c.stmts(body)
+	c.endScope()
+	if c.current.returnType != nil && !c.current.declared["nil"] && c.current.declared != nil && !alwaysReturns(body) {
+		c.error(name, catalog.MissingReturn, "Missing return in function returning "+returnType.String()+".")
+	}
+}
+
+// alwaysReturns reports whether running statements surely ends with a return statement, as far as it can be told
+// from their structure.
+func alwaysReturns(statements []ast.Stmt) bool {
+	for _, statement := range statements {
+		if returns(statement) {
+			return true
+		}
+	}
+	return false
+}
+
+func returns(statement ast.Stmt) bool {
+	switch s := statement.(type) {
+	case *ast.Return:
+		return true
+	case *ast.Block:
+		return alwaysReturns(s.Statements)
+	case *ast.If:
+		return s.ElseBranch != nil && returns(s.ThenBranch) && returns(s.ElseBranch)
+	case *ast.While:
+		// `while (true)` only ends by returning, unless it breaks
+		condition, ok := s.Condition.(*ast.Literal)
+		if !ok || condition.Value != true {
+			return false
+		}
+		breaks := false
+		ast.Inspect(s.Body, func(node ast.Node) bool {
+			switch node.(type) {
+			case *ast.Break:
+				breaks = true
+			case *ast.While, *ast.ForIn, *ast.FunctionExpr, *ast.Function:
+				return false
+			}
+			return !breaks
+		})
+		return !breaks
+	}
+	return false
+}
+
+func (c *Checker) VisitBlockStmt(stmt *ast.Block) (interface{}, error) {
+	c.beginScope()
+	c.stmts(stmt.Statements)
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Checker) VisitExpressionStmt(stmt *ast.Expression) (interface{}, error) {
+	c.expr(stmt.Expression)
+	return nil, nil
+}
+
+func (c *Checker) VisitFunctionStmt(stmt *ast.Function) (interface{}, error) {
+	b := c.global(stmt, stmt.Name)
+	if b == nil {
+		b = c.newBinding(stmt, nil)
+		c.declare(stmt.Name, b)
+	}
+	b.inferred = single("function")
+	b.signature = &signature{params: stmt.Params, returnType: stmt.ReturnType, generator: stmt.Generator}
+	c.checkFunction(stmt.Params, stmt.Body, stmt.ReturnType, stmt.Generator, stmt.Name)
+	return nil, nil
+}
+
+func (c *Checker) VisitIfStmt(stmt *ast.If) (interface{}, error) {
+	c.expr(stmt.Condition)
+	c.stmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		c.stmt(stmt.ElseBranch)
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitPrintStmt(stmt *ast.Print) (interface{}, error) {
+	c.expr(stmt.Expression)
+	return nil, nil
+}
+
+func (c *Checker) VisitReturnStmt(stmt *ast.Return) (interface{}, error) {
+	actual := single("nil")
+	if stmt.Value != nil {
+		actual = c.expr(stmt.Value)
+	}
+	if c.current != nil && c.current.returnType != nil {
+		stmt.Value = c.coerce(stmt.Value, actual, c.current.returnType, c.current.declared, stmt.KeyWord)
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitVarStmt(stmt *ast.Var) (interface{}, error) {
+	actual := single("nil")
+	if stmt.Initializer != nil {
+		actual = c.expr(stmt.Initializer)
+	}
+	c.checkAnnotation(stmt.Type)
+	b := c.global(stmt, stmt.Name)
+	if b == nil {
+		b = c.newBinding(stmt, stmt.Type)
+	}
+	if stmt.Type != nil {
+		stmt.Initializer = c.coerce(stmt.Initializer, actual, stmt.Type, b.declared, stmt.Name)
+	}
+	b.inferred = actual
+	c.declare(stmt.Name, b)
+	return nil, nil
+}
+
+func (c *Checker) VisitWhileStmt(stmt *ast.While) (interface{}, error) {
+	c.expr(stmt.Condition)
+	if stmt.OptionalMutate != nil {
+		c.expr(stmt.OptionalMutate)
+	}
+	c.stmt(stmt.Body)
+	return nil, nil
+}
+
+func (c *Checker) VisitBreakStmt(_ *ast.Break) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *Checker) VisitContinueStmt(_ *ast.Continue) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *Checker) VisitMatchStmt(stmt *ast.Match) (interface{}, error) {
+	c.expr(stmt.Subject)
+	for _, matchCase := range stmt.Cases {
+		c.beginScope()
+		for _, pattern := range matchCase.Patterns {
+			ast.Inspect(pattern, func(node ast.Node) bool {
+				switch p := node.(type) {
+				case *ast.BindingPattern:
+					c.declare(p.Name, &binding{assigned: true})
+				case *ast.ListPattern:
+					if p.Rest != nil {
+						c.declare(*p.Rest, &binding{assigned: true})
+					}
+				}
+				return true
+			})
+		}
+		if matchCase.Guard != nil {
+			c.expr(matchCase.Guard)
+		}
+		c.stmt(matchCase.Body)
+		c.endScope()
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitForInStmt(stmt *ast.ForIn) (interface{}, error) {
+	c.expr(stmt.Iterable)
+	c.beginScope()
+	c.declare(stmt.Name, &binding{assigned: true})
+	c.stmt(stmt.Body)
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Checker) VisitYieldStmt(stmt *ast.Yield) (interface{}, error) {
+	if stmt.Value != nil {
+		c.expr(stmt.Value)
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitAssertStmt(stmt *ast.Assert) (interface{}, error) {
+	c.expr(stmt.Condition)
+	if stmt.Message != nil {
+		c.expr(stmt.Message)
+	}
+	return nil, nil
+}
+
+func (c *Checker) VisitTestStmt(stmt *ast.Test) (interface{}, error) {
+	c.beginScope()
+	c.stmts(stmt.Body)
+	c.endScope()
+	return nil, nil
+}
+
+func (c *Checker) VisitBinaryExpr(expr *ast.Binary) (interface{}, error) {
+	left := c.expr(expr.Left)
+	right := c.expr(expr.Right)
+	return binaryType(expr.Operator.Type0, left, right), nil
+}
+
+// VisitCallExpr checks the arguments of a call to a function declaration which is never reassigned. Its result has
+// the declared return type.
+func (c *Checker) VisitCallExpr(expr *ast.Call) (interface{}, error) {
+	c.expr(expr.Callee)
+	arguments := make([]typeSet, 0, len(expr.Arguments))
+	for _, argument := range expr.Arguments {
+		arguments = append(arguments, c.expr(argument))
+	}
+	variable, ok := expr.Callee.(*ast.Variable)
+	if !ok {
+		return nil, nil
+	}
+	b := c.lookup(variable.Name)
+	if b == nil || b.assigned || b.signature == nil {
+		return nil, nil
+	}
+	c.checkArguments(b.signature, expr, arguments)
+	if b.signature.generator {
+		return single("generator"), nil
+	}
+	return c.typeOf(b.signature.returnType), nil
+}
+
+// checkArguments reports the arguments which can't have the type of their parameter. Calls spreading a list are
+// only checked at runtime.
+func (c *Checker) checkArguments(signature *signature, call *ast.Call, arguments []typeSet) {
+	for _, argument := range call.Arguments {
+		if _, ok := argument.(*ast.Spread); ok {
+			return
+		}
+	}
+	for index, argument := range call.Arguments {
+		var param *ast.Param
+		if named, ok := argument.(*ast.NamedArg); ok {
+			for p := range signature.params {
+				if signature.params[p].Name.Lexeme == named.Name.Lexeme && !signature.params[p].Rest {
+					param = &signature.params[p]
+				}
+			}
+		} else if len(signature.params) > 0 {
+			param = &signature.params[len(signature.params)-1]
+			if index < len(signature.params) {
+				param = &signature.params[index]
+			}
+			if !param.Rest && index >= len(signature.params) {
+				param = nil
+			}
+		}
+		if param == nil || param.Type == nil {
+			continue
+		}
+		if arguments[index].disjoint(c.typeOf(param.Type)) {
+			c.error(call.Paren, catalog.TypeMismatch, "Expected "+param.Type.String()+" for argument '"+param.Name.Lexeme+"' but got "+arguments[index].String()+".")
+		}
+	}
+}
+
+func (c *Checker) VisitGroupingExpr(expr *ast.Grouping) (interface{}, error) {
+	return c.expr(expr.Expression), nil
+}
+
+func (c *Checker) VisitLiteralExpr(expr *ast.Literal) (interface{}, error) {
+	return literalType(expr.Value), nil
+}
+
+func (c *Checker) VisitLogicalExpr(expr *ast.Logical) (interface{}, error) {
+	left := c.expr(expr.Left)
+	right := c.expr(expr.Right)
+	return union(left, right), nil
+}
+
+func (c *Checker) VisitUnaryExpr(expr *ast.Unary) (interface{}, error) {
+	c.expr(expr.Right)
+	if expr.Operator.Type0 == lexer.BANG {
+		return single("bool"), nil
+	}
+	return single("number"), nil
+}
+
+func (c *Checker) VisitVariableExpr(expr *ast.Variable) (interface{}, error) {
+	if b := c.lookup(expr.Name); b != nil {
+		return b.staticType(), nil
+	}
+	return nil, nil
+}
+
+// VisitAssignExpr checks the value assigned to an annotated variable. A compound `+=` on a variable which may hold a
+// number but not a string needs a number, or it would turn the variable into a string.
+func (c *Checker) VisitAssignExpr(expr *ast.Assign) (interface{}, error) {
+	value := c.expr(expr.Value)
+	c.markAssigned(expr.Name)
+	b := c.lookup(expr.Name)
+	if b == nil {
+		return value, nil
+	}
+	if expr.Operator.Type0 == lexer.EQUAL {
+		if b.annotation != nil {
+			expr.Value = c.coerce(expr.Value, value, b.annotation, b.declared, expr.Name)
+		}
+		return value, nil
+	}
+	result := binaryType(expr.Operator.Type0, b.staticType(), value)
+	if b.annotation != nil && expr.Operator.Type0 == lexer.PLUS_EQUAL && b.declared != nil && b.declared["number"] && !b.declared["string"] {
+		expr.Value = c.coerce(expr.Value, value, numberAnnotation(expr.Operator), single("number"), expr.Name)
+	}
+	return result, nil
+}
+
+func (c *Checker) VisitTernaryExpr(expr *ast.Ternary) (interface{}, error) {
+	c.expr(expr.ConditionalExpr)
+	then := c.expr(expr.ThenExpr)
+	otherwise := c.expr(expr.ElseExpr)
+	return union(then, otherwise), nil
+}
+
+func (c *Checker) VisitFunctionExpr(expr *ast.FunctionExpr) (interface{}, error) {
+	name := lexer.Token{Type0: lexer.FUN, Lexeme: "fun"}
+	if expr.ReturnType != nil {
+		name = expr.ReturnType.Names[0]
+	}
+	c.checkFunction(expr.Params, expr.Body, expr.ReturnType, expr.Generator, name)
+	return single("function"), nil
+}
+
+func (c *Checker) VisitUpdateExpr(expr *ast.Update) (interface{}, error) {
+	if variable, ok := expr.Target.(*ast.Variable); ok {
+		c.markAssigned(variable.Name)
+	}
+	c.expr(expr.Target)
+	return single("number"), nil
+}
+
+func (c *Checker) VisitSpreadExpr(expr *ast.Spread) (interface{}, error) {
+	c.expr(expr.Expression)
+	return nil, nil
+}
+
+func (c *Checker) VisitNamedArgExpr(expr *ast.NamedArg) (interface{}, error) {
+	return c.expr(expr.Value), nil
+}
+
+func (c *Checker) VisitSpawnExpr(expr *ast.Spawn) (interface{}, error) {
+	c.expr(expr.Call)
+	return single("task"), nil
+}
+
+func (c *Checker) VisitMapExpr(expr *ast.Map) (interface{}, error) {
+	for index, key := range expr.Keys {
+		c.expr(key)
+		c.expr(expr.Values[index])
+	}
+	return single("map"), nil
+}
+
+func (c *Checker) VisitTypeCheckExpr(expr *ast.TypeCheck) (interface{}, error) {
+	c.expr(expr.Expression)
+	return c.typeOf(expr.Type), nil
+}
@@ -0,0 +1,106 @@
+package checker
+
+import (
+	"golox/lox/ast"
+	"golox/lox/interpreter"
+	"golox/lox/lexer"
+	"strings"
+)
+
+// typeSet is the static type of an expression: the names of the runtime types its value may have, see
+// interpreter.TypeName. A nil typeSet is unknown, the value may have any type.
+type typeSet map[string]bool
+
+func single(name string) typeSet {
+	return typeSet{name: true}
+}
+
+// union is the type of a value having either type, unknown when one of them is.
+func union(a typeSet, b typeSet) typeSet {
+	if a == nil || b == nil {
+		return nil
+	}
+	result := make(typeSet, len(a)+len(b))
+	for name := range a {
+		result[name] = true
+	}
+	for name := range b {
+		result[name] = true
+	}
+	return result
+}
+
+func (t typeSet) is(name string) bool {
+	return len(t) == 1 && t[name]
+}
+
+// subsetOf reports whether every value of type t has type other. Nothing is known about an unknown type.
+func (t typeSet) subsetOf(other typeSet) bool {
+	if t == nil || other == nil {
+		return other == nil
+	}
+	for name := range t {
+		if !other[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// disjoint reports whether no value of type t has type other, which requires both types to be known.
+func (t typeSet) disjoint(other typeSet) bool {
+	if t == nil || other == nil {
+		return false
+	}
+	for name := range t {
+		if other[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// String joins the type names in the order of interpreter.TypeNames.
+func (t typeSet) String() string {
+	if t == nil {
+		return "any"
+	}
+	names := make([]string, 0, len(t))
+	for _, name := range interpreter.TypeNames {
+		if t[name] {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, " | ")
+}
+
+// literalType is the type of a literal value.
+func literalType(value interface{}) typeSet {
+	return single(interpreter.TypeName(value))
+}
+
+// binaryType is the type of the result of a binary operator applied to operands of types left and right. Operands
+// the operator doesn't accept fail at runtime, so they don't add to the type.
+func binaryType(operator lexer.TokenType, left typeSet, right typeSet) typeSet {
+	switch operator {
+	case lexer.EQUAL_EQUAL, lexer.BANG_EQUAL, lexer.GREATER, lexer.GREATER_EQUAL, lexer.LESS, lexer.LESS_EQUAL:
+		return single("bool")
+	case lexer.PLUS, lexer.PLUS_EQUAL:
+		switch {
+		case left.is("number") && right.is("number"):
+			return single("number")
+		case left.is("string") || right.is("string"):
+			return single("string")
+		}
+		return textual
+	}
+	return single("number")
+}
+
+// textual are the types `+` yields.
+var textual = typeSet{"number": true, "string": true}
+
+// numberAnnotation is the annotation of the runtime checks requiring a number.
+func numberAnnotation(token lexer.Token) *ast.TypeAnnotation {
+	return &ast.TypeAnnotation{Names: []lexer.Token{{Type0: lexer.IDENTIFIER, Lexeme: "number", Line: token.Line}}}
+}
@@ -1,11 +1,16 @@
 package common
 
-import "golox/lox/lexer"
+import (
+	"golox/lox/lexer"
+	"golox/utils"
+)
 
 type RuntimeError struct {
 	HasError bool
 	Token    lexer.Token
 	Reason   string
+	Code     string     // the code of the error in the catalog, like "LOX-E001"
+	Fix      *utils.Fix // suggests a correction, like a defined name for an undefined one
 }
 
 func (r RuntimeError) Error() string { return "" }
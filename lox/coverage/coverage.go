@@ -0,0 +1,220 @@
+// Package coverage records which statements of Lox programs ran and which way their branches went: both outcomes
+// of the condition of an If, While or Ternary, and whether a Logical short-circuited. The programs are registered
+// with File.Add before they run, so that the statements which never run are known too, and the interpreter hooks
+// of the file count every statement executed and every branch taken. A Profile is reported as a text summary, in
+// the LCOV format or as an annotated HTML view of the sources.
+package coverage
+
+import (
+	"golox/lox/ast"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Profile is the coverage of a set of scripts, like the ones of a test run. It is safe for concurrent use.
+type Profile struct {
+	mutex sync.Mutex
+	files map[string]*File
+}
+
+func NewProfile() *Profile {
+	return &Profile{files: make(map[string]*File)}
+}
+
+// File returns the coverage of the script at path, whose text is source, creating it on first use.
+func (p *Profile) File(path string, source string) *File {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	file, ok := p.files[path]
+	if !ok {
+		file = &File{Path: path, Source: source, statements: make(map[ast.Stmt]*Statement), branches: make(map[ast.Node]*Branch)}
+		p.files[path] = file
+	}
+	return file
+}
+
+// Files returns the files of the profile ordered by path.
+func (p *Profile) Files() []*File {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	files := make([]*File, 0, len(p.files))
+	for _, file := range p.files {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// File is the coverage of one script. It is safe for concurrent use, so the tasks of a program can share it.
+type File struct {
+	Path   string
+	Source string
+
+	mutex          sync.RWMutex
+	statements     map[ast.Stmt]*Statement
+	branches       map[ast.Node]*Branch
+	statementOrder []*Statement
+	branchOrder    []*Branch
+}
+
+// Statement is a statement and the number of times it ran.
+type Statement struct {
+	Line  int
+	Count int64
+}
+
+// Branch is a two-way branch. Counts[0] is the number of times the condition of an If, While or Ternary was true
+// or a Logical short-circuited, Counts[1] the number of times it went the other way.
+type Branch struct {
+	Line   int
+	Kind   string // "if", "while", "and", "or" or "ternary"
+	Counts [2]int64
+}
+
+// Add registers the statements and branches of a program which is about to run. Nodes registered already keep
+// their counts.
+func (f *File) Add(statements []ast.Stmt) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, statement := range statements {
+		ast.Inspect(statement, func(node ast.Node) bool {
+			line := ast.Line(node)
+			if line == 0 {
+				return true
+			}
+			if stmt, ok := node.(ast.Stmt); ok && f.statements[stmt] == nil {
+				entry := &Statement{Line: line}
+				f.statements[stmt] = entry
+				f.statementOrder = append(f.statementOrder, entry)
+			}
+			if kind := branchKind(node); kind != "" && f.branches[node] == nil {
+				entry := &Branch{Line: line, Kind: kind}
+				f.branches[node] = entry
+				f.branchOrder = append(f.branchOrder, entry)
+			}
+			return true
+		})
+	}
+}
+
+func branchKind(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.If:
+		return "if"
+	case *ast.While:
+		return "while"
+	case *ast.Logical:
+		return n.Operator.Lexeme
+	case *ast.Ternary:
+		return "ternary"
+	}
+	return ""
+}
+
+// countStatement counts a run of stmt.
+func (f *File) countStatement(stmt ast.Stmt) {
+	f.mutex.RLock()
+	entry := f.statements[stmt]
+	f.mutex.RUnlock()
+	if entry != nil {
+		atomic.AddInt64(&entry.Count, 1)
+	}
+}
+
+// countBranch counts that node went the way branch, 0 or 1, see Branch.Counts.
+func (f *File) countBranch(node ast.Node, branch int) {
+	f.mutex.RLock()
+	entry := f.branches[node]
+	f.mutex.RUnlock()
+	if entry != nil {
+		atomic.AddInt64(&entry.Counts[branch], 1)
+	}
+}
+
+// Statements returns a snapshot of the statements of the file ordered by line.
+func (f *File) Statements() []Statement {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	statements := make([]Statement, len(f.statementOrder))
+	for index, entry := range f.statementOrder {
+		statements[index] = Statement{Line: entry.Line, Count: atomic.LoadInt64(&entry.Count)}
+	}
+	sort.SliceStable(statements, func(i, j int) bool {
+		return statements[i].Line < statements[j].Line
+	})
+	return statements
+}
+
+// Branches returns a snapshot of the branches of the file ordered by line.
+func (f *File) Branches() []Branch {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	branches := make([]Branch, len(f.branchOrder))
+	for index, entry := range f.branchOrder {
+		branches[index] = Branch{Line: entry.Line, Kind: entry.Kind, Counts: [2]int64{atomic.LoadInt64(&entry.Counts[0]), atomic.LoadInt64(&entry.Counts[1])}}
+	}
+	sort.SliceStable(branches, func(i, j int) bool {
+		return branches[i].Line < branches[j].Line
+	})
+	return branches
+}
+
+// Summary counts the statements and branch outcomes of a file or a profile and how many of them ran.
+type Summary struct {
+	Statements        int
+	CoveredStatements int
+	Branches          int // every branch has two outcomes
+	CoveredBranches   int
+}
+
+func (f *File) Summary() Summary {
+	var summary Summary
+	for _, statement := range f.Statements() {
+		summary.Statements++
+		if statement.Count > 0 {
+			summary.CoveredStatements++
+		}
+	}
+	for _, branch := range f.Branches() {
+		for _, count := range branch.Counts {
+			summary.Branches++
+			if count > 0 {
+				summary.CoveredBranches++
+			}
+		}
+	}
+	return summary
+}
+
+// Summary sums up the summaries of the files.
+func (p *Profile) Summary() Summary {
+	var total Summary
+	for _, file := range p.Files() {
+		summary := file.Summary()
+		total.Statements += summary.Statements
+		total.CoveredStatements += summary.CoveredStatements
+		total.Branches += summary.Branches
+		total.CoveredBranches += summary.CoveredBranches
+	}
+	return total
+}
+
+// StatementPercent is the percentage of statements which ran, 100 when there are none.
+func (s Summary) StatementPercent() float64 {
+	return percent(s.CoveredStatements, s.Statements)
+}
+
+// BranchPercent is the percentage of branch outcomes which happened, 100 when there are none.
+func (s Summary) BranchPercent() float64 {
+	return percent(s.CoveredBranches, s.Branches)
+}
+
+func percent(covered int, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(covered) * 100 / float64(total)
+}
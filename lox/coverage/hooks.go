@@ -0,0 +1,30 @@
+package coverage
+
+import (
+	"golox/lox/ast"
+	"golox/lox/interpreter"
+)
+
+// Hooks returns the interpreter hooks counting into the file, see Interpreter.AddHooks. The program must have been
+// added to the file.
+func (f *File) Hooks() interpreter.Hooks {
+	return fileHooks{file: f}
+}
+
+// fileHooks are shared by all the tasks of a program, the file being safe for concurrent use.
+type fileHooks struct {
+	interpreter.NoHooks
+	file *File
+}
+
+func (h fileHooks) OnStatement(_ *interpreter.Interpreter, stmt ast.Stmt) {
+	h.file.countStatement(stmt)
+}
+
+func (h fileHooks) OnBranch(_ *interpreter.Interpreter, node ast.Node, branch int) {
+	h.file.countBranch(node, branch)
+}
+
+func (h fileHooks) OnFork(*interpreter.Interpreter, *interpreter.Interpreter, interpreter.Fork) interpreter.Hooks {
+	return h
+}
@@ -0,0 +1,139 @@
+package coverage
+
+import (
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// htmlLine is a line of source in the HTML view. Class is empty for a line without statements, else "covered",
+// "partial" when some of its statements or branch outcomes didn't happen, or "uncovered".
+type htmlLine struct {
+	Number int
+	Text   string
+	Hits   string
+	Class  string
+	Title  string
+}
+
+type htmlFile struct {
+	Path    string
+	Summary string
+	Lines   []htmlLine
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lox coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; white-space: pre; }
+td { padding: 0 0.5em; }
+td.number, td.hits { color: #888; text-align: right; }
+tr.covered td.source { background: #d6f5d6; }
+tr.partial td.source { background: #fff3c4; }
+tr.uncovered td.source { background: #f8d0d0; }
+</style>
+</head>
+<body>
+<h1>Lox coverage</h1>
+<p>{{.Summary}}</p>
+<ul>
+{{- range $index, $file := .Files}}
+<li><a href="#file{{$index}}">{{$file.Path}}</a> {{$file.Summary}}</li>
+{{- end}}
+</ul>
+{{- range $index, $file := .Files}}
+<h2 id="file{{$index}}">{{$file.Path}}</h2>
+<table>
+{{- range $file.Lines}}
+<tr{{if .Class}} class="{{.Class}}"{{end}}{{if .Title}} title="{{.Title}}"{{end}}><td class="number">{{.Number}}</td><td class="hits">{{.Hits}}</td><td class="source">{{.Text}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes an HTML page showing the source of every file with its lines colored by coverage, their hit
+// counts and, on hover, the outcomes of their branches.
+func (p *Profile) WriteHTML(w io.Writer) error {
+	var files []htmlFile
+	for _, file := range p.Files() {
+		files = append(files, htmlFile{Path: file.Path, Summary: summaryText(file.Summary()), Lines: annotate(file)})
+	}
+	return htmlTemplate.Execute(w, struct {
+		Summary string
+		Files   []htmlFile
+	}{summaryText(p.Summary()), files})
+}
+
+func summaryText(summary Summary) string {
+	return "statements " + ratio(summary.StatementPercent(), summary.CoveredStatements, summary.Statements) +
+		", branches " + ratio(summary.BranchPercent(), summary.CoveredBranches, summary.Branches)
+}
+
+func annotate(file *File) []htmlLine {
+	statements := make(map[int][]int64)
+	for _, statement := range file.Statements() {
+		statements[statement.Line] = append(statements[statement.Line], statement.Count)
+	}
+	branches := make(map[int][]Branch)
+	for _, branch := range file.Branches() {
+		branches[branch.Line] = append(branches[branch.Line], branch)
+	}
+	source := strings.Split(strings.TrimSuffix(file.Source, "\n"), "\n")
+	lines := make([]htmlLine, len(source))
+	for index, text := range source {
+		line := htmlLine{Number: index + 1, Text: text}
+		counts := statements[index+1]
+		if len(counts) > 0 {
+			var hits int64
+			ran := 0
+			for _, count := range counts {
+				if count > hits {
+					hits = count
+				}
+				if count > 0 {
+					ran++
+				}
+			}
+			line.Hits = strconv.FormatInt(hits, 10)
+			switch ran {
+			case 0:
+				line.Class = "uncovered"
+			case len(counts):
+				line.Class = "covered"
+			default:
+				line.Class = "partial"
+			}
+		}
+		var titles []string
+		for _, branch := range branches[index+1] {
+			titles = append(titles, branch.Kind+": "+outcomes(branch))
+			if line.Class == "covered" && (branch.Counts[0] == 0 || branch.Counts[1] == 0) {
+				line.Class = "partial"
+			}
+		}
+		line.Title = strings.Join(titles, "; ")
+		lines[index] = line
+	}
+	return lines
+}
+
+// outcomes describes the counts of a branch, e.g. "then 3, else 0".
+func outcomes(branch Branch) string {
+	names := [2]string{"true", "false"}
+	switch branch.Kind {
+	case "if", "ternary":
+		names = [2]string{"then", "else"}
+	case "while":
+		names = [2]string{"looped", "exited"}
+	case "and", "or":
+		names = [2]string{"short-circuited", "evaluated right"}
+	}
+	return names[0] + " " + strconv.FormatInt(branch.Counts[0], 10) + ", " + names[1] + " " + strconv.FormatInt(branch.Counts[1], 10)
+}
@@ -0,0 +1,106 @@
+package coverage
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// WriteText writes the statement and branch coverage of every file and of the whole profile.
+func (p *Profile) WriteText(w io.Writer) error {
+	files := p.Files()
+	width := len("total")
+	for _, file := range files {
+		if len(file.Path) > width {
+			width = len(file.Path)
+		}
+	}
+	row := func(name string, summary Summary) error {
+		_, err := fmt.Fprintf(w, "%-*s  %-20s  %s\n", width, name,
+			ratio(summary.StatementPercent(), summary.CoveredStatements, summary.Statements),
+			ratio(summary.BranchPercent(), summary.CoveredBranches, summary.Branches))
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%-*s  %-20s  %s\n", width, "file", "statements", "branches"); err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := row(file.Path, file.Summary()); err != nil {
+			return err
+		}
+	}
+	return row("total", p.Summary())
+}
+
+func ratio(percent float64, covered int, total int) string {
+	return fmt.Sprintf("%.1f%% (%d/%d)", percent, covered, total)
+}
+
+// WriteLCOV writes the profile in the LCOV tracefile format read by genhtml and most CI coverage services. The hit
+// count of a line is the highest of the counts of its statements.
+func (p *Profile) WriteLCOV(w io.Writer) error {
+	for _, file := range p.Files() {
+		if err := writeLCOVFile(w, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeLCOVFile(w io.Writer, file *File) error {
+	record := []string{"TN:", "SF:" + file.Path}
+	hits := lineHits(file.Statements())
+	covered := 0
+	for _, line := range sortedLines(hits) {
+		record = append(record, "DA:"+strconv.Itoa(line)+","+strconv.FormatInt(hits[line], 10))
+		if hits[line] > 0 {
+			covered++
+		}
+	}
+	branches, taken := 0, 0
+	for block, branch := range file.Branches() {
+		for index, count := range branch.Counts {
+			// "-" tells a branch whose condition never ran from one which ran without going this way
+			outcome := "-"
+			if branch.Counts[0]+branch.Counts[1] > 0 {
+				outcome = strconv.FormatInt(count, 10)
+			}
+			record = append(record, "BRDA:"+strconv.Itoa(branch.Line)+","+strconv.Itoa(block)+","+strconv.Itoa(index)+","+outcome)
+			branches++
+			if count > 0 {
+				taken++
+			}
+		}
+	}
+	record = append(record,
+		"BRF:"+strconv.Itoa(branches), "BRH:"+strconv.Itoa(taken),
+		"LF:"+strconv.Itoa(len(hits)), "LH:"+strconv.Itoa(covered),
+		"end_of_record")
+	for _, line := range record {
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineHits maps every line holding a statement to the highest count of its statements.
+func lineHits(statements []Statement) map[int]int64 {
+	hits := make(map[int]int64)
+	for _, statement := range statements {
+		if count, ok := hits[statement.Line]; !ok || statement.Count > count {
+			hits[statement.Line] = statement.Count
+		}
+	}
+	return hits
+}
+
+func sortedLines(hits map[int]int64) []int {
+	lines := make([]int, 0, len(hits))
+	for line := range hits {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
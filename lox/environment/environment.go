@@ -1,12 +1,21 @@
 package environment
 
 import (
+	"golox/lox/catalog"
 	"golox/lox/common"
 	"golox/lox/lexer"
+	"golox/lox/suggest"
+	"golox/utils"
+	"sync"
 )
 
+// Environment holds the bindings of one scope. Tasks started with `spawn` share the global environment and the
+// environments captured by closures, so every access takes the environment's lock; a single read or write is
+// atomic, but sequences of them (like `x = x + 1`) are not.
 type Environment struct {
+	mutex     sync.RWMutex
 	values    map[string]interface{}
+	constants map[string]bool // names defined with DefineConstant, allocated on first use
 	enclosing *Environment
 }
 
@@ -19,38 +28,98 @@ func GetEnclosingEnvironment(nested *Environment) *Environment {
 }
 
 func (e *Environment) Get(name lexer.Token) (interface{}, error) {
-	val, ok := e.values[name.Lexeme]
-	if ok {
-		return val, nil
+	for scope := e; scope != nil; scope = scope.enclosing {
+		scope.mutex.RLock()
+		val, ok := scope.values[name.Lexeme]
+		scope.mutex.RUnlock()
+		if ok {
+			return val, nil
+		}
 	}
-	if e.enclosing != nil {
-		return e.enclosing.Get(name)
-	}
-	return nil, common.RuntimeError{HasError: true, Token: name, Reason: "Undefined variable '" + name.Lexeme + "'"}
+	return nil, e.Undefined(name, true)
 }
 
 func (e *Environment) GetAt(distance int, name string) interface{} {
-	return e.ancestor(distance).values[name]
+	ancestor := e.ancestor(distance)
+	ancestor.mutex.RLock()
+	defer ancestor.mutex.RUnlock()
+	return ancestor.values[name]
 }
 
 func (e *Environment) AssignAt(distance int, name lexer.Token, value interface{}) {
-	e.ancestor(distance).values[name.Lexeme] = value
+	ancestor := e.ancestor(distance)
+	ancestor.mutex.Lock()
+	defer ancestor.mutex.Unlock()
+	ancestor.values[name.Lexeme] = value
 }
 
 func (e *Environment) Define(name string, value interface{}) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.values[name] = value
+	delete(e.constants, name)
+}
+
+// DefineConstant defines a binding which Assign refuses to overwrite. The resolver rejects assignments to local
+// constants before the program runs, so this guard matters for globals, which the resolver leaves to runtime.
+func (e *Environment) DefineConstant(name string, value interface{}) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 	e.values[name] = value
+	if e.constants == nil {
+		e.constants = make(map[string]bool, 0)
+	}
+	e.constants[name] = true
 }
 
 func (e *Environment) Assign(name lexer.Token, value interface{}) error {
-	_, ok := e.values[name.Lexeme]
-	if ok {
-		e.values[name.Lexeme] = value
-		return nil
+	for scope := e; scope != nil; scope = scope.enclosing {
+		if found, err := scope.assign(name, value); found {
+			return err
+		}
+	}
+	return e.Undefined(name, false)
+}
+
+// assign assigns name in this scope only, found telling whether it is bound here.
+func (e *Environment) assign(name lexer.Token, value interface{}) (found bool, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if _, ok := e.values[name.Lexeme]; !ok {
+		return false, nil
+	}
+	if e.constants[name.Lexeme] {
+		return true, common.RuntimeError{HasError: true, Token: name, Code: catalog.AssignConstant, Reason: "Cannot assign to constant '" + name.Lexeme + "'"}
+	}
+	e.values[name.Lexeme] = value
+	return true, nil
+}
+
+// Undefined returns the error for name not being bound, whose fix suggests the closest name bound in this scope or
+// an enclosing one, or the closest keyword starting an expression when keywords is set.
+func (e *Environment) Undefined(name lexer.Token, keywords bool) common.RuntimeError {
+	err := common.RuntimeError{HasError: true, Token: name, Code: catalog.UndefinedVariable, Reason: "Undefined variable '" + name.Lexeme + "'."}
+	candidates := e.Names()
+	if keywords {
+		candidates = append(candidates, suggest.ExpressionKeywords()...)
 	}
-	if e.enclosing != nil {
-		return e.enclosing.Assign(name, value)
+	if suggestion := suggest.Closest(name.Lexeme, candidates); suggestion != "" {
+		err.Fix = &utils.Fix{Line: name.Line, Column: name.Column, Old: name.Lexeme, New: suggestion}
 	}
-	return common.RuntimeError{HasError: true, Token: name, Reason: "Undefined variable '" + name.Lexeme + "'"}
+	return err
+}
+
+// Names returns the names bound in this scope and the enclosing ones.
+func (e *Environment) Names() []string {
+	var names []string
+	for scope := e; scope != nil; scope = scope.enclosing {
+		scope.mutex.RLock()
+		for name := range scope.values {
+			names = append(names, name)
+		}
+		scope.mutex.RUnlock()
+	}
+	return names
 }
 
 func (e *Environment) ancestor(distance int) *Environment {
@@ -60,3 +129,37 @@ func (e *Environment) ancestor(distance int) *Environment {
 	}
 	return current
 }
+
+// Bindings returns a copy of the bindings of this scope, those of the enclosing scopes left out.
+func (e *Environment) Bindings() map[string]interface{} {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	bindings := make(map[string]interface{}, len(e.values))
+	for name, value := range e.values {
+		bindings[name] = value
+	}
+	return bindings
+}
+
+// Copy returns a scope with the same bindings as this one and the same enclosing scope. The values themselves are
+// not copied: an instance or a list bound in both scopes is the same object.
+func (e *Environment) Copy() *Environment {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	copied := &Environment{values: make(map[string]interface{}, len(e.values)), enclosing: e.enclosing}
+	for name, value := range e.values {
+		copied.values[name] = value
+	}
+	for name := range e.constants {
+		if copied.constants == nil {
+			copied.constants = make(map[string]bool, len(e.constants))
+		}
+		copied.constants[name] = true
+	}
+	return copied
+}
+
+// Enclosing returns the scope enclosing this one, nil for the global scope.
+func (e *Environment) Enclosing() *Environment {
+	return e.enclosing
+}
@@ -0,0 +1,81 @@
+package interpreter
+
+import (
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/common"
+	"golox/lox/environment"
+	"time"
+)
+
+// TestResult is the outcome of one test block. Err is the runtime error which failed it, nil if it passed.
+type TestResult struct {
+	Name     string
+	Line     int
+	Err      error
+	Duration time.Duration
+}
+
+func (i *Interpreter) VisitAssertStmt(stmt *ast.Assert) (interface{}, error) {
+	var condition interface{}
+	operands := ""
+	// For a comparison the operands are evaluated here, so that a failure can show them.
+	if binary, ok := stmt.Condition.(*ast.Binary); ok {
+		left, err := i.evaluate(binary.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := i.evaluate(binary.Right)
+		if err != nil {
+			return nil, err
+		}
+		condition, err = i.binary(binary.Operator, left, right)
+		if err != nil {
+			return nil, err
+		}
+		operands = " (left: " + repr(left) + ", right: " + repr(right) + ")"
+	} else {
+		var err error
+		condition, err = i.evaluate(stmt.Condition)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if i.isTruthy(condition) {
+		return nil, nil
+	}
+
+	reason := "Assertion failed: " + stmt.Source + operands
+	if stmt.Message != nil {
+		message, err := i.evaluate(stmt.Message)
+		if err != nil {
+			return nil, err
+		}
+		reason += ": " + stringify(message)
+	}
+	return nil, common.RuntimeError{HasError: true, Token: stmt.Keyword, Code: catalog.AssertionFailed, Reason: reason}
+}
+
+func (i *Interpreter) VisitTestStmt(stmt *ast.Test) (interface{}, error) {
+	i.tests = append(i.tests, stmt)
+	return nil, nil
+}
+
+// RunTests runs the test blocks collected by Interpret in declaration order. Each one runs in a scope of its own
+// below a copy of the globals as the script left them, so the assignments of a test don't leak into the tests which
+// follow; objects reachable from the globals are still shared. A failing test doesn't stop the others.
+func (i *Interpreter) RunTests() []TestResult {
+	results := make([]TestResult, 0, len(i.tests))
+	snapshot := i.global.Copy()
+	for _, test := range i.tests {
+		start := time.Now()
+		child := i.fork(Fork{Kind: TestFork, Line: test.Keyword.Line})
+		child.global = snapshot.Copy()
+		child.environment = child.global
+		_, err := child.executeBlock(test.Body, environment.GetEnclosingEnvironment(child.global))
+		child.onError(err)
+		child.onSuspend()
+		results = append(results, TestResult{Name: test.Name, Line: test.Keyword.Line, Err: err, Duration: time.Since(start)})
+	}
+	return results
+}
@@ -2,15 +2,49 @@ package interpreter
 
 import "time"
 
+// natives are the native functions every program starts with in its globals. They hold no state, so all the
+// interpreters share them.
+var natives = []struct {
+	name     string
+	function LoxCallable
+}{
+	{"clock", &Clock{}},
+	{"range", &Range{}},
+	{"wait", &Wait{}},
+	{"channel", &Channel{}},
+	{"send", &Send{}},
+	{"receive", &Receive{}},
+	{"close", &Close{}},
+	{"select", &Select{}},
+	{"readLine", &ReadLine{}},
+	{"type", &Type{}},
+	{"arity", &Arity{}},
+	{"name", &Name{}},
+	{"isCallable", &IsCallable{}},
+	{"globals", &Globals{}},
+	{"locals", &Locals{}},
+	{"source", &Source{}},
+}
+
+// nativeName returns the name of the global a native function is defined as, "" for other callables.
+func nativeName(callable LoxCallable) string {
+	for _, native := range natives {
+		if native.function == callable {
+			return native.name
+		}
+	}
+	return ""
+}
+
 type Clock struct {
 }
 
-func (t *Clock) Arity() int {
-	return 0
+func (t *Clock) Arity() (int, int) {
+	return 0, 0
 }
 
 func (t *Clock) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
-	return time.Now().UnixMicro(), nil
+	return float64(time.Now().UnixMicro()), nil
 }
 
 func (t *Clock) String() string {
@@ -1,6 +1,27 @@
 package interpreter
 
+// VariadicArity is the maximum arity reported by callables that accept any number of trailing arguments.
+const VariadicArity = -1
+
 type LoxCallable interface {
-	Arity() int
+	// Arity returns the minimum and maximum number of positional arguments accepted by the callable. The maximum
+	// is VariadicArity when there is no upper bound.
+	Arity() (int, int)
 	Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error)
 }
+
+// LoxNamedCallable is implemented by callables which also accept `name: value` arguments.
+type LoxNamedCallable interface {
+	LoxCallable
+	CallNamed(interpreter *Interpreter, arguments []interface{}, named map[string]interface{}) (interface{}, error)
+}
+
+// ArgumentError reports arguments that cannot be bound to a callable's parameters, or any other failure of a call
+// which has no token of its own. VisitCallExpr turns it into a runtime error located at the call site, VisitForInStmt
+// into one located at the loop.
+type ArgumentError struct {
+	Reason string
+	Code   string
+}
+
+func (e *ArgumentError) Error() string { return e.Reason }
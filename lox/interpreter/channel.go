@@ -0,0 +1,235 @@
+package interpreter
+
+import (
+	"golox/lox/catalog"
+	"strconv"
+)
+
+// LoxChannel is a channel created by the `channel` native. It carries values of any type. Its state is guarded by
+// the mutex of the scheduler, which its operations wait with.
+type LoxChannel struct {
+	scheduler *scheduler
+	buffer    []interface{} // values sent and not received yet, at most capacity unless the channel is unbuffered
+	capacity  int
+	closed    bool
+	sent      int // values sent so far, a send on an unbuffered channel waits until its value is received
+	received  int
+}
+
+func (t *LoxChannel) String() string {
+	return "<channel>"
+}
+
+func (t *LoxChannel) Iterator() LoxIterator {
+	return t
+}
+
+// Next receives the next value, a for-in loop over a channel ends when the channel is closed and drained.
+func (t *LoxChannel) Next() (interface{}, bool, error) {
+	t.scheduler.mutex.Lock()
+	defer t.scheduler.mutex.Unlock()
+	return t.receive()
+}
+
+// ready tells whether a receive can go on.
+func (t *LoxChannel) ready() bool {
+	return len(t.buffer) > 0 || t.closed
+}
+
+// receive waits for a value, ok is false once the channel is closed and drained. It is called with the mutex of the
+// scheduler held.
+func (t *LoxChannel) receive() (value interface{}, ok bool, err error) {
+	if err := t.scheduler.block(t.ready); err != nil {
+		return nil, false, err
+	}
+	if len(t.buffer) == 0 {
+		return nil, false, nil
+	}
+	value, t.buffer = t.buffer[0], t.buffer[1:]
+	t.received++
+	t.scheduler.wake()
+	return value, true, nil
+}
+
+func channelArgument(name string, argument interface{}) (*LoxChannel, error) {
+	channel, ok := argument.(*LoxChannel)
+	if !ok {
+		return nil, &ArgumentError{Code: catalog.InvalidNativeArgument, Reason: name + "() expects a channel"}
+	}
+	return channel, nil
+}
+
+// Channel is the `channel()` / `channel(capacity)` native; without capacity the channel is unbuffered.
+type Channel struct {
+}
+
+func (t *Channel) Arity() (int, int) {
+	return 0, 1
+}
+
+func (t *Channel) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	capacity := 0.0
+	if len(arguments) == 1 {
+		v, ok := arguments[0].(float64)
+		if !ok || v < 0 || v != float64(int(v)) {
+			return nil, &ArgumentError{Code: catalog.InvalidNativeArgument, Reason: "channel() capacity must be a non-negative integer"}
+		}
+		capacity = v
+	}
+	return &LoxChannel{scheduler: interpreter.scheduler, capacity: int(capacity)}, nil
+}
+
+func (t *Channel) String() string {
+	return "<native fn>"
+}
+
+// Send is the `send(channel, value)` native, it blocks until the value is received or buffered.
+type Send struct {
+}
+
+func (t *Send) Arity() (int, int) {
+	return 2, 2
+}
+
+func (t *Send) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	channel, err := channelArgument("send", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	channel.scheduler.mutex.Lock()
+	defer channel.scheduler.mutex.Unlock()
+	closed := &ArgumentError{Code: catalog.ClosedChannel, Reason: "send() on a closed channel"}
+	if channel.capacity > 0 {
+		err = channel.scheduler.block(func() bool {
+			return len(channel.buffer) < channel.capacity || channel.closed
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if channel.closed {
+		return nil, closed
+	}
+	channel.buffer = append(channel.buffer, arguments[1])
+	channel.sent++
+	channel.scheduler.wake()
+	if channel.capacity > 0 {
+		return nil, nil
+	}
+	sent := channel.sent
+	err = channel.scheduler.block(func() bool {
+		return channel.received >= sent || channel.closed
+	})
+	if err != nil {
+		return nil, err
+	}
+	if channel.received < sent {
+		return nil, closed
+	}
+	return nil, nil
+}
+
+func (t *Send) String() string {
+	return "<native fn>"
+}
+
+// Receive is the `receive(channel)` native. It blocks until a value is available and returns nil once the
+// channel is closed and drained.
+type Receive struct {
+}
+
+func (t *Receive) Arity() (int, int) {
+	return 1, 1
+}
+
+func (t *Receive) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	channel, err := channelArgument("receive", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	channel.scheduler.mutex.Lock()
+	defer channel.scheduler.mutex.Unlock()
+	value, _, err := channel.receive()
+	return value, err
+}
+
+func (t *Receive) String() string {
+	return "<native fn>"
+}
+
+// Close is the `close(channel)` native.
+type Close struct {
+}
+
+func (t *Close) Arity() (int, int) {
+	return 1, 1
+}
+
+func (t *Close) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	channel, err := channelArgument("close", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	channel.scheduler.mutex.Lock()
+	defer channel.scheduler.mutex.Unlock()
+	if channel.closed {
+		return nil, &ArgumentError{Code: catalog.ClosedChannel, Reason: "close() of a closed channel"}
+	}
+	channel.closed = true
+	if channel.capacity == 0 {
+		// the values still waiting to be received fail their send
+		channel.buffer = nil
+	}
+	channel.scheduler.wake()
+	return nil, nil
+}
+
+func (t *Close) String() string {
+	return "<native fn>"
+}
+
+// Select is the `select(channel, ...)` native. It waits until one of the channels can be received from, the first
+// in the order of the arguments when several can, and returns the list [channel, value]; value is nil when that
+// channel was closed.
+type Select struct {
+}
+
+func (t *Select) Arity() (int, int) {
+	return 1, VariadicArity
+}
+
+func (t *Select) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	channels := make([]*LoxChannel, 0, len(arguments))
+	for index, argument := range arguments {
+		channel, ok := argument.(*LoxChannel)
+		if !ok {
+			return nil, &ArgumentError{Code: catalog.InvalidNativeArgument, Reason: "select() argument " + strconv.Itoa(index+1) + " must be a channel"}
+		}
+		channels = append(channels, channel)
+	}
+	scheduler := interpreter.scheduler
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	var chosen *LoxChannel
+	err := scheduler.block(func() bool {
+		for _, channel := range channels {
+			if channel.ready() {
+				chosen = channel
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := chosen.receive()
+	if err != nil {
+		return nil, err
+	}
+	return NewLoxList([]interface{}{chosen, value}), nil
+}
+
+func (t *Select) String() string {
+	return "<native fn>"
+}
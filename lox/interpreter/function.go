@@ -2,7 +2,9 @@ package interpreter
 
 import (
 	"golox/lox/ast"
+	"golox/lox/catalog"
 	"golox/lox/environment"
+	"strconv"
 )
 
 type LoxFunction struct {
@@ -16,16 +18,124 @@ func NewLoxFunction(declaration *ast.FunctionExpr, closure *environment.Environm
 }
 
 func (t *LoxFunction) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return t.CallNamed(interpreter, arguments, nil)
+}
+
+func (t *LoxFunction) CallNamed(interpreter *Interpreter, arguments []interface{}, named map[string]interface{}) (value interface{}, err error) {
+	if interpreter.depth >= maxCallDepth {
+		return nil, &ArgumentError{Code: catalog.StackOverflow, Reason: "Stack overflow."}
+	}
+	if interpreter.hooks != nil {
+		interpreter.hooks.OnCall(interpreter, t, arguments, named)
+		defer func() {
+			interpreter.hooks.OnReturn(interpreter, t, value, err)
+		}()
+	}
+	interpreter.depth++
+	defer func() {
+		interpreter.depth--
+	}()
 	localEnvironment := environment.GetEnclosingEnvironment(t.Closure)
-	for index, argument := range t.Declaration.Params {
-		localEnvironment.Define(argument.Lexeme, arguments[index])
+	err = t.bind(interpreter, localEnvironment, arguments, named)
+	if err != nil {
+		return nil, err
+	}
+	if t.Declaration.Generator {
+		return newLoxGenerator(interpreter, t, localEnvironment), nil
+	}
+	_, err = interpreter.executeBlock(t.Declaration.Body, localEnvironment)
+	if v, ok := err.(*FuncReturn); ok {
+		return v.Value, nil
+	}
+	return nil, err
+}
+
+// bind defines every parameter in localEnvironment. Positional arguments are taken first, then named ones; the
+// parameters still unbound fall back to their default values, which are evaluated at call time inside
+// localEnvironment so they can refer to the parameters before them. Annotated parameters are checked here rather
+// than at the call site, since untyped code may call the function with anything.
+func (t *LoxFunction) bind(interpreter *Interpreter, localEnvironment *environment.Environment, arguments []interface{}, named map[string]interface{}) error {
+	params := t.Declaration.Params
+	minArity, maxArity := t.Arity()
+	if len(arguments) > maxArity && maxArity != VariadicArity {
+		return &ArgumentError{Code: catalog.ArgumentCount, Reason: "Expected " + arityString(minArity, maxArity) + " arguments but got " + strconv.Itoa(len(arguments)) + "."}
+	}
+	for name := range named {
+		if !t.hasNamedParam(name) {
+			return &ArgumentError{Code: catalog.NamedArgument, Reason: "Unexpected named argument '" + name + "'"}
+		}
+	}
+	for index, param := range params {
+		name := param.Name.Lexeme
+		if param.Rest {
+			rest := make([]interface{}, 0)
+			if index < len(arguments) {
+				rest = append(rest, arguments[index:]...)
+			}
+			for _, value := range rest {
+				if err := checkArgument(param, value); err != nil {
+					return err
+				}
+			}
+			list := NewLoxList(rest)
+			localEnvironment.Define(name, list)
+			interpreter.onDefine(name, list)
+			continue
+		}
+		value, isNamed := named[name]
+		if index < len(arguments) {
+			if isNamed {
+				return &ArgumentError{Code: catalog.NamedArgument, Reason: "Argument '" + name + "' given both by position and by name"}
+			}
+			value = arguments[index]
+		} else if !isNamed {
+			if param.Default == nil {
+				return &ArgumentError{Code: catalog.ArgumentCount, Reason: "Missing argument '" + name + "': expected " + arityString(minArity, maxArity) + " arguments but got " + strconv.Itoa(len(arguments)+len(named))}
+			}
+			var err error
+			value, err = interpreter.evaluateIn(param.Default, localEnvironment)
+			if err != nil {
+				return err
+			}
+		}
+		if err := checkArgument(param, value); err != nil {
+			return err
+		}
+		localEnvironment.Define(name, value)
+		interpreter.onDefine(name, value)
+	}
+	return nil
+}
+
+// checkArgument fails unless value has the type param is annotated with. The annotation of a rest parameter applies
+// to each of the arguments it collects.
+func checkArgument(param ast.Param, value interface{}) error {
+	if param.Type == nil || hasType(value, param.Type) {
+		return nil
 	}
-	val, err := interpreter.executeBlock(t.Declaration.Body, localEnvironment)
-	return val, err
+	return &ArgumentError{Code: catalog.TypeMismatch, Reason: "Expected " + param.Type.String() + " for argument '" + param.Name.Lexeme + "' but got " + TypeName(value) + "."}
 }
 
-func (t *LoxFunction) Arity() int {
-	return len(t.Declaration.Params)
+func (t *LoxFunction) hasNamedParam(name string) bool {
+	for _, param := range t.Declaration.Params {
+		if param.Name.Lexeme == name && !param.Rest {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *LoxFunction) Arity() (int, int) {
+	minArity := 0
+	for _, param := range t.Declaration.Params {
+		if param.Rest {
+			return minArity, VariadicArity
+		}
+		if param.Default == nil {
+			minArity++
+		}
+	}
+	return minArity, len(t.Declaration.Params)
 }
 
 func (t *LoxFunction) String() string {
@@ -35,8 +145,27 @@ func (t *LoxFunction) String() string {
 	return "<fn " + t.Name + ">"
 }
 
+// Label names the function in the output of tools: by its name, or by the line it starts on if it is anonymous.
+func (t *LoxFunction) Label() string {
+	if t.Name == "" {
+		return "anonymous@" + strconv.Itoa(ast.Line(t.Declaration))
+	}
+	return t.Name
+}
+
 type FuncReturn struct {
 	Value interface{}
 }
 
 func (r FuncReturn) Error() string { return "" }
+
+// arityString describes an arity range for error messages, e.g. "2", "1 to 3" or "at least 1".
+func arityString(minArity int, maxArity int) string {
+	if maxArity == VariadicArity {
+		return "at least " + strconv.Itoa(minArity)
+	}
+	if minArity == maxArity {
+		return strconv.Itoa(minArity)
+	}
+	return strconv.Itoa(minArity) + " to " + strconv.Itoa(maxArity)
+}
@@ -0,0 +1,156 @@
+package interpreter
+
+import (
+	"errors"
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/common"
+	"golox/lox/environment"
+	"runtime"
+)
+
+// errGeneratorAbandoned unwinds the body of a generator nobody holds a reference to anymore.
+var errGeneratorAbandoned = errors.New("generator abandoned")
+
+// LoxGenerator is the iterator returned by calling a generator function.
+//
+// The body runs on a goroutine of its own with a forked interpreter; while the generator is suspended, that
+// goroutine's stack keeps the current environment and statement position. Control is handed back and forth over
+// unbuffered channels so the caller and the body never run at the same time. Tasks sharing a generator take turns:
+// one asking for a value while another is waiting for one waits its turn.
+type LoxGenerator struct {
+	*generatorState
+}
+
+type generatorState struct {
+	name      string
+	resume    chan struct{}
+	yields    chan generatorResult
+	done      chan struct{}
+	start     func()
+	scheduler *scheduler
+	// guarded by the mutex of the scheduler
+	started  bool
+	running  bool
+	finished bool
+	caller   *generatorState // the generator whose body asked for the value being computed, nil for a task
+}
+
+type generatorResult struct {
+	value interface{}
+	ok    bool
+	err   error
+}
+
+func newLoxGenerator(interpreter *Interpreter, function *LoxFunction, localEnvironment *environment.Environment) *LoxGenerator {
+	state := &generatorState{
+		name:      function.Name,
+		resume:    make(chan struct{}),
+		yields:    make(chan generatorResult),
+		done:      make(chan struct{}),
+		scheduler: interpreter.scheduler,
+	}
+	// the body runs at the depth of the call that created it, or generators creating each other would never stop
+	depth := interpreter.depth
+	state.start = func() {
+		go func() {
+			child := interpreter.fork(Fork{Kind: GeneratorFork, Function: function})
+			child.generator = state
+			child.depth = depth
+			defer child.onSuspend()
+			_, err := child.executeBlock(function.Declaration.Body, localEnvironment)
+			if err == errGeneratorAbandoned {
+				return
+			}
+			if _, ok := err.(*FuncReturn); ok {
+				err = nil
+			}
+			state.yields <- generatorResult{ok: false, err: err}
+		}()
+	}
+	generator := &LoxGenerator{generatorState: state}
+	// The goroutine only references the state, so the handle becomes unreachable once the program drops it; the
+	// finalizer then releases a body suspended in the middle of a yield.
+	runtime.SetFinalizer(generator, func(g *LoxGenerator) {
+		close(g.done)
+	})
+	return generator
+}
+
+func (t *LoxGenerator) Iterator() LoxIterator {
+	return t
+}
+
+func (t *LoxGenerator) Next() (interface{}, bool, error) {
+	return t.next(nil)
+}
+
+// next resumes the body until it yields the next value. caller is the generator whose body asks for the value,
+// nil for the code of a task.
+func (t *LoxGenerator) next(caller *generatorState) (interface{}, bool, error) {
+	scheduler := t.scheduler
+	scheduler.mutex.Lock()
+	// the body asking for its own next value, directly or through other generators, would wait on itself forever
+	for outer := caller; outer != nil; outer = outer.caller {
+		if outer == t.generatorState {
+			scheduler.mutex.Unlock()
+			return nil, false, &ArgumentError{Code: catalog.GeneratorMisuse, Reason: "Generator is already running"}
+		}
+	}
+	err := scheduler.block(func() bool {
+		return !t.running
+	})
+	if err != nil || t.finished {
+		scheduler.mutex.Unlock()
+		return nil, false, err
+	}
+	t.running, t.caller = true, caller
+	started := t.started
+	t.started = true
+	scheduler.mutex.Unlock()
+
+	if !started {
+		t.start()
+	} else {
+		t.resume <- struct{}{}
+	}
+	result := <-t.yields
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	t.running, t.caller = false, nil
+	if !result.ok {
+		t.finished = true
+	}
+	scheduler.wake()
+	return result.value, result.ok, result.err
+}
+
+func (t *LoxGenerator) String() string {
+	if t.name == "" {
+		return "<generator anonymous>"
+	}
+	return "<generator " + t.name + ">"
+}
+
+func (i *Interpreter) VisitYieldStmt(stmt *ast.Yield) (interface{}, error) {
+	if i.generator == nil {
+		return nil, common.RuntimeError{HasError: true, Token: stmt.Keyword, Code: catalog.GeneratorMisuse, Reason: "Can't yield outside of a generator"}
+	}
+	var value interface{}
+	var err error
+	if stmt.Value != nil {
+		value, err = i.evaluate(stmt.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	i.onSuspend()
+	i.generator.yields <- generatorResult{value: value, ok: true}
+	select {
+	case <-i.generator.resume:
+		return nil, nil
+	case <-i.generator.done:
+		return nil, errGeneratorAbandoned
+	}
+}
@@ -0,0 +1,193 @@
+package interpreter
+
+import (
+	"golox/lox/ast"
+	"golox/lox/common"
+)
+
+// Hooks observe a running program, for tools like profilers, coverage and tracers. Every method receives the
+// interpreter of the task the event happens on, which the hooks may query with Depth and Bindings but must not
+// otherwise use. Hooks are called synchronously and slow the program down by what they cost; an interpreter
+// without hooks only pays for a nil check per event. Embed NoHooks to implement only some of the methods.
+type Hooks interface {
+	// OnStatement is called before stmt executes and OnStatementEnd after it, whether it failed or not.
+	OnStatement(interpreter *Interpreter, stmt ast.Stmt)
+	OnStatementEnd(interpreter *Interpreter, stmt ast.Stmt)
+	// OnExpression is called before expr is evaluated.
+	OnExpression(interpreter *Interpreter, expr ast.Expr)
+	// OnBranch is called when an If, While or Ternary node went the way branch, 0 for a true condition and 1 for
+	// a false one, or when a Logical node short-circuited, 0, or evaluated its right operand, 1.
+	OnBranch(interpreter *Interpreter, node ast.Node, branch int)
+	// OnCall is called when a Lox function is called, before its parameters are bound, and OnReturn once it
+	// returned value or failed with err. The interpreter's depth includes the call during neither.
+	OnCall(interpreter *Interpreter, function *LoxFunction, arguments []interface{}, named map[string]interface{})
+	OnReturn(interpreter *Interpreter, function *LoxFunction, value interface{}, err error)
+	// OnError is called with a runtime error which ends the program, a spawned task or a test.
+	OnError(interpreter *Interpreter, err common.RuntimeError)
+	// OnDefine is called when a declaration, a parameter, a loop variable or a pattern binds name to value, and
+	// OnAssign when an assignment stores value into name.
+	OnDefine(interpreter *Interpreter, name string, value interface{})
+	OnAssign(interpreter *Interpreter, name string, value interface{})
+	// OnSuspend is called when the task stops running Lox code: it waits for a generator to produce a value, its
+	// generator body yields, or it is done. The next event of the task resumes it.
+	OnSuspend(interpreter *Interpreter)
+	// OnFork is called when the task starts another one on child, described by fork, and returns the hooks of the
+	// child. Hooks keeping per-task state return fresh hooks, the others can return themselves.
+	OnFork(interpreter *Interpreter, child *Interpreter, fork Fork) Hooks
+}
+
+// ForkKind tells what a forked interpreter runs.
+type ForkKind int
+
+const (
+	SpawnFork     ForkKind = iota // a task started by `spawn`
+	GeneratorFork                 // the body of a generator
+	TestFork                      // a `test` block run by RunTests
+)
+
+// Fork describes a task started on a forked interpreter.
+type Fork struct {
+	Kind     ForkKind
+	Function *LoxFunction // the generator function of a GeneratorFork
+	Line     int          // the line of the spawn or of the test
+}
+
+// NoHooks implements every method of Hooks by doing nothing, and OnFork by returning the receiver.
+type NoHooks struct{}
+
+func (NoHooks) OnStatement(*Interpreter, ast.Stmt)                                       {}
+func (NoHooks) OnStatementEnd(*Interpreter, ast.Stmt)                                    {}
+func (NoHooks) OnExpression(*Interpreter, ast.Expr)                                      {}
+func (NoHooks) OnBranch(*Interpreter, ast.Node, int)                                     {}
+func (NoHooks) OnCall(*Interpreter, *LoxFunction, []interface{}, map[string]interface{}) {}
+func (NoHooks) OnReturn(*Interpreter, *LoxFunction, interface{}, error)                  {}
+func (NoHooks) OnError(*Interpreter, common.RuntimeError)                                {}
+func (NoHooks) OnDefine(*Interpreter, string, interface{})                               {}
+func (NoHooks) OnAssign(*Interpreter, string, interface{})                               {}
+func (NoHooks) OnSuspend(*Interpreter)                                                   {}
+func (h NoHooks) OnFork(*Interpreter, *Interpreter, Fork) Hooks                          { return h }
+
+// AddHooks attaches hooks to the interpreter, after the ones attached already. Attach them before the program
+// runs: tasks started before get none.
+func (i *Interpreter) AddHooks(hooks Hooks) {
+	switch current := i.hooks.(type) {
+	case nil:
+		i.hooks = hooks
+	case hookList:
+		i.hooks = append(current, hooks)
+	default:
+		i.hooks = hookList{current, hooks}
+	}
+}
+
+// Depth returns the number of Lox function calls in progress on the task.
+func (i *Interpreter) Depth() int {
+	return i.depth
+}
+
+// Bindings returns a copy of the bindings of the innermost scope of the task.
+func (i *Interpreter) Bindings() map[string]interface{} {
+	return i.environment.Bindings()
+}
+
+// Repr renders a value for tools, like stringify but with strings quoted.
+func Repr(value interface{}) string {
+	return repr(value)
+}
+
+// hookList calls several hooks in the order they were attached.
+type hookList []Hooks
+
+func (l hookList) OnStatement(interpreter *Interpreter, stmt ast.Stmt) {
+	for _, hooks := range l {
+		hooks.OnStatement(interpreter, stmt)
+	}
+}
+
+func (l hookList) OnStatementEnd(interpreter *Interpreter, stmt ast.Stmt) {
+	for _, hooks := range l {
+		hooks.OnStatementEnd(interpreter, stmt)
+	}
+}
+
+func (l hookList) OnExpression(interpreter *Interpreter, expr ast.Expr) {
+	for _, hooks := range l {
+		hooks.OnExpression(interpreter, expr)
+	}
+}
+
+func (l hookList) OnBranch(interpreter *Interpreter, node ast.Node, branch int) {
+	for _, hooks := range l {
+		hooks.OnBranch(interpreter, node, branch)
+	}
+}
+
+func (l hookList) OnCall(interpreter *Interpreter, function *LoxFunction, arguments []interface{}, named map[string]interface{}) {
+	for _, hooks := range l {
+		hooks.OnCall(interpreter, function, arguments, named)
+	}
+}
+
+func (l hookList) OnReturn(interpreter *Interpreter, function *LoxFunction, value interface{}, err error) {
+	for _, hooks := range l {
+		hooks.OnReturn(interpreter, function, value, err)
+	}
+}
+
+func (l hookList) OnError(interpreter *Interpreter, err common.RuntimeError) {
+	for _, hooks := range l {
+		hooks.OnError(interpreter, err)
+	}
+}
+
+func (l hookList) OnDefine(interpreter *Interpreter, name string, value interface{}) {
+	for _, hooks := range l {
+		hooks.OnDefine(interpreter, name, value)
+	}
+}
+
+func (l hookList) OnAssign(interpreter *Interpreter, name string, value interface{}) {
+	for _, hooks := range l {
+		hooks.OnAssign(interpreter, name, value)
+	}
+}
+
+func (l hookList) OnSuspend(interpreter *Interpreter) {
+	for _, hooks := range l {
+		hooks.OnSuspend(interpreter)
+	}
+}
+
+func (l hookList) OnFork(interpreter *Interpreter, child *Interpreter, fork Fork) Hooks {
+	forked := make(hookList, len(l))
+	for index, hooks := range l {
+		forked[index] = hooks.OnFork(interpreter, child, fork)
+	}
+	return forked
+}
+
+// The methods below report events to the hooks of the interpreter, if it has any.
+
+func (i *Interpreter) onDefine(name string, value interface{}) {
+	if i.hooks != nil {
+		i.hooks.OnDefine(i, name, value)
+	}
+}
+
+func (i *Interpreter) onBranch(node ast.Node, branch int) {
+	if i.hooks != nil {
+		i.hooks.OnBranch(i, node, branch)
+	}
+}
+
+func (i *Interpreter) onSuspend() {
+	if i.hooks != nil {
+		i.hooks.OnSuspend(i)
+	}
+}
+
+func (i *Interpreter) onError(err error) {
+	if runtimeError, ok := err.(common.RuntimeError); ok && i.hooks != nil {
+		i.hooks.OnError(i, runtimeError)
+	}
+}
@@ -3,42 +3,104 @@ package interpreter
 import (
 	"fmt"
 	"golox/lox/ast"
+	"golox/lox/catalog"
 	"golox/lox/common"
 	"golox/lox/environment"
 	"golox/lox/lexer"
 	"golox/utils"
+	"math"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
+// compoundOperators maps a compound assignment operator to the binary operator it applies.
+var compoundOperators = map[lexer.TokenType]lexer.TokenType{
+	lexer.PLUS_EQUAL:    lexer.PLUS,
+	lexer.MINUS_EQUAL:   lexer.MINUS,
+	lexer.STAR_EQUAL:    lexer.STAR,
+	lexer.SLASH_EQUAL:   lexer.SLASH,
+	lexer.PERCENT_EQUAL: lexer.PERCENT,
+}
+
 type Interpreter struct {
 	global        *environment.Environment
 	environment   *environment.Environment
 	locals        map[ast.Expr]int
-	loopCnt       int
 	breakState    bool
 	continueState bool
+
+	constantFunctions bool
+	generator         *generatorState // set on the forked interpreter running a generator body
+	streams           *streams
+	budget            *stepBudget // shared with forked interpreters
+	scheduler         *scheduler  // shared with forked interpreters
+	depth             int         // number of Lox function calls in progress, see maxCallDepth
+	tests             []*ast.Test // test blocks collected by Interpret, see RunTests
+	hooks             Hooks       // nil when there are none, see AddHooks
+}
+
+// maxCallDepth bounds the nesting of Lox function calls, so runaway recursion ends in a runtime error instead of
+// overflowing the Go stack.
+const maxCallDepth = 10_000
+
+// stepBudget bounds the number of statements a program may execute, 0 meaning no bound.
+type stepBudget struct {
+	limit int64
+	used  int64
 }
 
 func NewInterpreter() *Interpreter {
-	interpreter := &Interpreter{global: environment.GetEnvironment()}
+	interpreter := &Interpreter{global: environment.GetEnvironment(), streams: newStreams(), budget: &stepBudget{}, scheduler: newScheduler()}
 	interpreter.environment = interpreter.global
 	interpreter.locals = make(map[ast.Expr]int, 0)
 
-	interpreter.global.Define("clock", &Clock{})
+	for _, native := range natives {
+		interpreter.global.Define(native.name, native.function)
+	}
 	return interpreter
 }
 
+// SetConstantFunctions makes function declarations immutable bindings, see Resolver.SetConstantFunctions.
+func (i *Interpreter) SetConstantFunctions(constant bool) {
+	i.constantFunctions = constant
+}
+
+// SetStepLimit aborts the program with a runtime error once it has executed more than limit statements, across
+// all its tasks and generators. A limit of 0 disables the check.
+func (i *Interpreter) SetStepLimit(limit int64) {
+	i.budget.limit = limit
+}
+
+// fork returns an interpreter sharing the globals and resolved locals of i, with its own current environment and
+// control-flow state, for code that is suspended or runs independently of i as described by fork.
+func (i *Interpreter) fork(fork Fork) *Interpreter {
+	child := &Interpreter{global: i.global, environment: i.global, locals: i.locals, constantFunctions: i.constantFunctions, streams: i.streams, budget: i.budget, scheduler: i.scheduler}
+	if i.hooks != nil {
+		child.hooks = i.hooks.OnFork(i, child, fork)
+	}
+	return child
+}
+
 func (i *Interpreter) Resolve(expr ast.Expr, depth int) {
 	i.locals[expr] = depth
 }
 
+// GlobalNames returns the names of the global variables defined so far, natives included.
+func (i *Interpreter) GlobalNames() []string {
+	return i.global.Names()
+}
+
 func (i *Interpreter) Interpret(statements []ast.Stmt) common.RuntimeError {
+	if i.hooks != nil {
+		defer i.hooks.OnSuspend(i)
+	}
 	for _, statement := range statements {
 		_, err := i.execute(statement)
 		if err != nil {
 			if runtimeError, ok := err.(common.RuntimeError); ok {
-				utils.RaiseError(runtimeError.Token.Line, runtimeError.Reason)
+				i.onError(runtimeError)
+				i.streams.raiseError(runtimeError)
 				return runtimeError
 			}
 
@@ -50,7 +112,7 @@ func (i *Interpreter) Interpret(statements []ast.Stmt) common.RuntimeError {
 	//	utils.RaiseError(common.runtimeError.Token.Line, common.runtimeError.Reason)
 	//	return common.runtimeError
 	//}
-	//fmt.Println(i.stringify(value))
+	//fmt.Println(stringify(value))
 	return common.RuntimeError{HasError: false}
 }
 
@@ -60,7 +122,11 @@ func (i *Interpreter) lookUpVariable(name lexer.Token, expr ast.Expr) (interface
 		return val, nil
 	} else {
 		val, err := i.global.Get(name)
-		return val, err
+		if err != nil {
+			// suggest the locals in scope too, the global environment only knows the globals
+			return nil, i.environment.Undefined(name, true)
+		}
+		return val, nil
 	}
 }
 
@@ -104,21 +170,8 @@ func (i *Interpreter) VisitUnaryExpr(expr *ast.Unary) (interface{}, error) {
 			return nil, err
 		}
 		return -rightVal.Value.(float64), nil
-
-	case lexer.INCREMENT:
-		err := i.checkNumberOperand(expr.Operator, rightVal)
-		if err != nil {
-			return nil, err
-		}
-		return rightVal.Value.(float64) + 1, nil
-	case lexer.DECREMENT:
-		err := i.checkNumberOperand(expr.Operator, rightVal)
-		if err != nil {
-			return nil, err
-		}
-		return rightVal.Value.(float64) - 1, nil
 	}
-	return nil, common.RuntimeError{HasError: true, Token: expr.Operator, Reason: "Unexpected error: VisitUnaryExpr unreachable"}
+	return nil, common.RuntimeError{HasError: true, Token: expr.Operator, Code: catalog.Internal, Reason: "Unexpected error: VisitUnaryExpr unreachable"}
 }
 
 func (i *Interpreter) VisitVariableExpr(expr *ast.Variable) (interface{}, error) {
@@ -127,11 +180,19 @@ func (i *Interpreter) VisitVariableExpr(expr *ast.Variable) (interface{}, error)
 
 func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) (interface{}, error) {
 	left, err := i.evaluate(expr.Left)
+	if err != nil {
+		return nil, err
+	}
 	right, err := i.evaluate(expr.Right)
-
 	if err != nil {
 		return nil, err
 	}
+	return i.binary(expr.Operator, left, right)
+}
+
+// binary applies a binary operator to two already evaluated operands. It is shared by binary expressions,
+// compound assignments and increments so that all of them follow the same arithmetic rules.
+func (i *Interpreter) binary(operator lexer.Token, left interface{}, right interface{}) (interface{}, error) {
 	leftVal := ast.Literal{Value: left}
 	rightVal := ast.Literal{Value: right}
 
@@ -169,27 +230,27 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) (interface{}, error) {
 		}
 	}
 
-	switch expr.Operator.Type0 {
+	switch operator.Type0 {
 	case lexer.GREATER:
-		err := i.checkNumberOperands(expr.Operator, leftVal, rightVal)
+		err := i.checkNumberOperands(operator, leftVal, rightVal)
 		if err != nil {
 			return nil, err
 		}
 		return leftVal.Value.(float64) > rightVal.Value.(float64), nil
 	case lexer.GREATER_EQUAL:
-		err := i.checkNumberOperands(expr.Operator, leftVal, rightVal)
+		err := i.checkNumberOperands(operator, leftVal, rightVal)
 		if err != nil {
 			return nil, err
 		}
 		return leftVal.Value.(float64) >= rightVal.Value.(float64), nil
 	case lexer.LESS:
-		err := i.checkNumberOperands(expr.Operator, leftVal, rightVal)
+		err := i.checkNumberOperands(operator, leftVal, rightVal)
 		if err != nil {
 			return nil, err
 		}
 		return leftVal.Value.(float64) < rightVal.Value.(float64), nil
 	case lexer.LESS_EQUAL:
-		err := i.checkNumberOperands(expr.Operator, leftVal, rightVal)
+		err := i.checkNumberOperands(operator, leftVal, rightVal)
 		if err != nil {
 			return nil, err
 		}
@@ -199,7 +260,7 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) (interface{}, error) {
 	case lexer.EQUAL_EQUAL:
 		return i.isEqual(leftVal, rightVal), nil
 	case lexer.MINUS:
-		err := i.checkNumberOperands(expr.Operator, leftVal, rightVal)
+		err := i.checkNumberOperands(operator, leftVal, rightVal)
 		if err != nil {
 			return nil, err
 		}
@@ -207,11 +268,11 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) (interface{}, error) {
 		var ok bool
 		if leftFloat, ok = utils.InterfaceToFloat64(leftVal.Value); ok {
 		} else {
-			return nil, common.RuntimeError{HasError: true, Reason: "cannot convert to float"}
+			return nil, common.RuntimeError{HasError: true, Code: catalog.Internal, Reason: "cannot convert to float"}
 		}
 		if rightFloat, ok = utils.InterfaceToFloat64(rightVal.Value); ok {
 		} else {
-			return nil, common.RuntimeError{HasError: true, Reason: "cannot convert to float"}
+			return nil, common.RuntimeError{HasError: true, Code: catalog.Internal, Reason: "cannot convert to float"}
 		}
 		return leftFloat - rightFloat, nil
 	case lexer.PLUS:
@@ -232,31 +293,37 @@ func (i *Interpreter) VisitBinaryExpr(expr *ast.Binary) (interface{}, error) {
 			var ok bool
 			if leftFloat, ok = utils.InterfaceToFloat64(leftVal.Value); ok {
 			} else {
-				return nil, common.RuntimeError{HasError: true, Reason: "cannot convert to float"}
+				return nil, common.RuntimeError{HasError: true, Code: catalog.Internal, Reason: "cannot convert to float"}
 			}
 			if rightFloat, ok = utils.InterfaceToFloat64(rightVal.Value); ok {
 			} else {
-				return nil, common.RuntimeError{HasError: true, Reason: "cannot convert to float"}
+				return nil, common.RuntimeError{HasError: true, Code: catalog.Internal, Reason: "cannot convert to float"}
 			}
 			return leftFloat + rightFloat, nil
 		}
 
-		return nil, common.RuntimeError{HasError: true, Token: expr.Operator, Reason: "operands must be numbers or strings"}
+		return nil, common.RuntimeError{HasError: true, Token: operator, Code: catalog.OperandType, Reason: "Operands must be two numbers or two strings."}
 	case lexer.SLASH:
-		err := i.checkNumberOperands(expr.Operator, leftVal, rightVal)
+		err := i.checkNumberOperands(operator, leftVal, rightVal)
 		if err != nil {
 			return nil, err
 		}
 		return leftVal.Value.(float64) / rightVal.Value.(float64), nil
 	case lexer.STAR:
-		err := i.checkNumberOperands(expr.Operator, leftVal, rightVal)
+		err := i.checkNumberOperands(operator, leftVal, rightVal)
 		if err != nil {
 			return nil, err
 		}
 		return leftVal.Value.(float64) * rightVal.Value.(float64), nil
+	case lexer.PERCENT:
+		err := i.checkNumberOperands(operator, leftVal, rightVal)
+		if err != nil {
+			return nil, err
+		}
+		return math.Mod(leftVal.Value.(float64), rightVal.Value.(float64)), nil
 	}
 
-	return nil, common.RuntimeError{HasError: true, Token: expr.Operator, Reason: "Unexpected error: VisitBinaryExpr unreachable"}
+	return nil, common.RuntimeError{HasError: true, Token: operator, Code: catalog.Internal, Reason: "Unexpected error: binary operator unreachable"}
 }
 
 func (i *Interpreter) VisitCallExpr(expr *ast.Call) (interface{}, error) {
@@ -264,39 +331,95 @@ func (i *Interpreter) VisitCallExpr(expr *ast.Call) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	arguments, named, err := i.evaluateArguments(expr.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	return i.call(callee, arguments, named, expr.Paren)
+}
+
+// evaluateArguments evaluates call arguments left to right, expanding spreads and collecting named arguments.
+func (i *Interpreter) evaluateArguments(expressions []ast.Expr) ([]interface{}, map[string]interface{}, error) {
 	arguments := make([]interface{}, 0)
-	for _, argument := range expr.Arguments {
-		v, err := i.evaluate(argument)
-		if err != nil {
-			return nil, err
+	var named map[string]interface{}
+	for _, argument := range expressions {
+		switch argument := argument.(type) {
+		case *ast.Spread:
+			v, err := i.evaluate(argument.Expression)
+			if err != nil {
+				return nil, nil, err
+			}
+			list, ok := v.(*LoxList)
+			if !ok {
+				return nil, nil, common.RuntimeError{HasError: true, Token: argument.Operator, Code: catalog.MisplacedSpread, Reason: "Can only spread lists"}
+			}
+			arguments = append(arguments, list.Elements...)
+		case *ast.NamedArg:
+			v, err := i.evaluate(argument.Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			if named == nil {
+				named = make(map[string]interface{}, 0)
+			}
+			named[argument.Name.Lexeme] = v
+		default:
+			v, err := i.evaluate(argument)
+			if err != nil {
+				return nil, nil, err
+			}
+			arguments = append(arguments, v)
 		}
-		arguments = append(arguments, v)
 	}
+	return arguments, named, nil
+}
+
+// call invokes callee with evaluated arguments, checking its arity. paren locates errors at the call site.
+func (i *Interpreter) call(callee interface{}, arguments []interface{}, named map[string]interface{}, paren lexer.Token) (interface{}, error) {
 	if _, ok := callee.(LoxCallable); !ok {
-		return nil, common.RuntimeError{HasError: true, Token: expr.Paren, Reason: "Can only call functions and classes"}
+		return nil, common.RuntimeError{HasError: true, Token: paren, Code: catalog.NotCallable, Reason: "Can only call functions and classes."}
 	}
 	function := callee.(LoxCallable)
-	if function.Arity() != len(arguments) {
-		return nil, common.RuntimeError{HasError: true, Token: expr.Paren, Reason: "Expected " + strconv.Itoa(function.Arity()) + " arguments but got " + strconv.Itoa(len(arguments))}
+	var value interface{}
+	var err error
+	if named != nil {
+		namedFunction, ok := function.(LoxNamedCallable)
+		if !ok {
+			return nil, common.RuntimeError{HasError: true, Token: paren, Code: catalog.NamedArgument, Reason: "Function doesn't accept named arguments"}
+		}
+		value, err = namedFunction.CallNamed(i, arguments, named)
+	} else {
+		minArity, maxArity := function.Arity()
+		if len(arguments) < minArity || (maxArity != VariadicArity && len(arguments) > maxArity) {
+			return nil, common.RuntimeError{HasError: true, Token: paren, Code: catalog.ArgumentCount, Reason: "Expected " + arityString(minArity, maxArity) + " arguments but got " + strconv.Itoa(len(arguments)) + "."}
+		}
+		value, err = function.Call(i, arguments)
+	}
+	if v, ok := err.(*ArgumentError); ok {
+		return nil, common.RuntimeError{HasError: true, Token: paren, Code: v.Code, Reason: v.Reason}
 	}
-	return function.Call(i, arguments)
+	return value, err
+}
+
+func (i *Interpreter) VisitSpreadExpr(expr *ast.Spread) (interface{}, error) {
+	return nil, common.RuntimeError{HasError: true, Token: expr.Operator, Code: catalog.MisplacedSpread, Reason: "Spread is only allowed in call arguments"}
+}
+
+func (i *Interpreter) VisitNamedArgExpr(expr *ast.NamedArg) (interface{}, error) {
+	return nil, common.RuntimeError{HasError: true, Token: expr.Name, Code: catalog.MisplacedSpread, Reason: "Named argument is only allowed in call arguments"}
 }
 
 func (i *Interpreter) VisitTernaryExpr(expr *ast.Ternary) (interface{}, error) {
-	var res interface{}
-	var err error
-	res, err = i.evaluate(expr.ConditionalExpr)
+	condition, err := i.evaluate(expr.ConditionalExpr)
 	if err != nil {
 		return nil, err
 	}
-	if i.isTruthy(res) {
-		_, err = i.evaluate(expr.ThenExpr)
-	} else {
-		if !i.isTruthy(res) {
-			_, err = i.evaluate(expr.ElseExpr)
-		}
+	if i.isTruthy(condition) {
+		i.onBranch(expr, 0)
+		return i.evaluate(expr.ThenExpr)
 	}
-	return nil, err
+	i.onBranch(expr, 1)
+	return i.evaluate(expr.ElseExpr)
 }
 
 func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) (interface{}, error) {
@@ -305,21 +428,46 @@ func (i *Interpreter) VisitLogicalExpr(expr *ast.Logical) (interface{}, error) {
 	left, err = i.evaluate(expr.Left)
 	if expr.Operator.Type0 == lexer.OR {
 		if i.isTruthy(left) {
+			i.onBranch(expr, 0)
 			return left, err
 		}
 	} else {
 		if !i.isTruthy(left) {
+			i.onBranch(expr, 0)
 			return left, err
 		}
 	}
+	i.onBranch(expr, 1)
 	return i.evaluate(expr.Right)
 }
 
 func (i *Interpreter) evaluate(expr ast.Expr) (interface{}, error) {
+	if i.hooks != nil {
+		i.hooks.OnExpression(i, expr)
+	}
 	return expr.Accept(i)
 }
 
+// evaluateIn evaluates expr with environment as the current scope, restoring the previous scope afterwards.
+func (i *Interpreter) evaluateIn(expr ast.Expr, environment *environment.Environment) (interface{}, error) {
+	previousEnviron := i.environment
+	defer func() {
+		i.environment = previousEnviron
+	}()
+	i.environment = environment
+	return i.evaluate(expr)
+}
+
 func (i *Interpreter) execute(stmt ast.Stmt) (interface{}, error) {
+	if i.budget.limit > 0 && atomic.AddInt64(&i.budget.used, 1) > i.budget.limit {
+		return nil, common.RuntimeError{HasError: true, Code: catalog.StepLimit, Reason: "Step limit of " + strconv.FormatInt(i.budget.limit, 10) + " statements exceeded"}
+	}
+	if i.hooks != nil {
+		i.hooks.OnStatement(i, stmt)
+		value, err := stmt.Accept(i)
+		i.hooks.OnStatementEnd(i, stmt)
+		return value, err
+	}
 	return stmt.Accept(i)
 }
 
@@ -333,13 +481,10 @@ func (i *Interpreter) executeBlock(statements []ast.Stmt, environment *environme
 		_, err := i.execute(statement)
 
 		if err != nil {
-			if v, ok := err.(*FuncReturn); ok {
-				return v.Value, nil
-			}
 			return nil, err
 		}
 
-		if i.breakState {
+		if i.breakState || i.continueState {
 			return nil, nil
 		}
 
@@ -355,21 +500,14 @@ func (i *Interpreter) VisitBlockStmt(stmt *ast.Block) (interface{}, error) {
 	return nil, nil
 }
 
+// VisitBreakStmt and VisitContinueStmt rely on the resolver having rejected them outside of loops.
 func (i *Interpreter) VisitBreakStmt(_ *ast.Break) (interface{}, error) {
-	if i.loopCnt > 0 {
-		i.breakState = true
-	} else {
-		return nil, common.RuntimeError{HasError: true, Token: lexer.Token{Type0: lexer.BREAK}, Reason: "'break' outside loop"}
-	}
+	i.breakState = true
 	return nil, nil
 }
 
 func (i *Interpreter) VisitContinueStmt(_ *ast.Continue) (interface{}, error) {
-	if i.loopCnt > 0 {
-		i.continueState = true
-	} else {
-		return nil, common.RuntimeError{HasError: true, Token: lexer.Token{Type0: lexer.CONTINUE}, Reason: "'continue' outside loop"}
-	}
+	i.continueState = true
 	return nil, nil
 }
 
@@ -382,8 +520,13 @@ func (i *Interpreter) VisitExpressionStmt(stmt *ast.Expression) (interface{}, er
 }
 
 func (i *Interpreter) VisitFunctionStmt(stmt *ast.Function) (interface{}, error) {
-	function := NewLoxFunction(&ast.FunctionExpr{Params: stmt.Params, Body: stmt.Body}, i.environment, stmt.Name.Lexeme)
-	i.environment.Define(stmt.Name.Lexeme, function)
+	function := NewLoxFunction(&ast.FunctionExpr{Params: stmt.Params, Body: stmt.Body, Generator: stmt.Generator, ReturnType: stmt.ReturnType, Source: stmt.Source}, i.environment, stmt.Name.Lexeme)
+	if i.constantFunctions {
+		i.environment.DefineConstant(stmt.Name.Lexeme, function)
+	} else {
+		i.environment.Define(stmt.Name.Lexeme, function)
+	}
+	i.onDefine(stmt.Name.Lexeme, function)
 	return nil, nil
 }
 
@@ -396,14 +539,18 @@ func (i *Interpreter) VisitIfStmt(stmt *ast.If) (interface{}, error) {
 	var res interface{}
 	res, err = i.evaluate(stmt.Condition)
 	if i.isTruthy(res) {
+		i.onBranch(stmt, 0)
 		_, err = i.execute(stmt.ThenBranch)
 		if err != nil {
 			return nil, err
 		}
-	} else if stmt.ElseBranch != nil { // Fix: elseBranch is optional
-		_, err = i.execute(stmt.ElseBranch)
-		if err != nil {
-			return nil, err
+	} else {
+		i.onBranch(stmt, 1)
+		if stmt.ElseBranch != nil { // Fix: elseBranch is optional
+			_, err = i.execute(stmt.ElseBranch)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 	return nil, nil
@@ -412,7 +559,7 @@ func (i *Interpreter) VisitIfStmt(stmt *ast.If) (interface{}, error) {
 func (i *Interpreter) VisitPrintStmt(stmt *ast.Print) (interface{}, error) {
 	value, err := i.evaluate(stmt.Expression)
 	if err == nil {
-		fmt.Println(i.stringify(value))
+		i.streams.println(stringify(value))
 	}
 	return nil, err
 }
@@ -438,22 +585,24 @@ func (i *Interpreter) VisitVarStmt(stmt *ast.Var) (interface{}, error) {
 			return nil, err
 		}
 	}
-	i.environment.Define(stmt.Name.Lexeme, value)
+	if stmt.Constant {
+		i.environment.DefineConstant(stmt.Name.Lexeme, value)
+	} else {
+		i.environment.Define(stmt.Name.Lexeme, value)
+	}
+	i.onDefine(stmt.Name.Lexeme, value)
 	return nil, nil
 }
 
 func (i *Interpreter) VisitWhileStmt(stmt *ast.While) (interface{}, error) {
 	var result interface{}
 	var err error
-	i.loopCnt++
-	defer func() {
-		i.loopCnt--
-	}()
 	result, err = i.evaluate(stmt.Condition)
 	if err != nil {
 		return nil, err
 	}
 	for i.isTruthy(result) {
+		i.onBranch(stmt, 0)
 		_, err = i.execute(stmt.Body)
 
 		if err != nil {
@@ -478,26 +627,100 @@ func (i *Interpreter) VisitWhileStmt(stmt *ast.While) (interface{}, error) {
 			return nil, err
 		}
 	}
+	i.onBranch(stmt, 1)
 	return nil, nil
 }
 
 func (i *Interpreter) VisitAssignExpr(expr *ast.Assign) (interface{}, error) {
+	var current interface{}
+	var err error
+	compound := expr.Operator.Type0 != lexer.EQUAL
+	if compound {
+		// the target is read before the right-hand side is evaluated, the same order `a = a + b` would use
+		current, err = i.lookUpVariable(expr.Name, expr)
+		if err != nil {
+			return nil, err
+		}
+	}
 	value, err := i.evaluate(expr.Value)
 	if err != nil {
 		return nil, err
 	}
-	if distance, ok := i.locals[expr]; ok {
-		i.environment.AssignAt(distance, expr.Name, value)
-	} else {
-		err := i.global.Assign(expr.Name, value)
+	if compound {
+		operator := expr.Operator
+		operator.Type0 = compoundOperators[expr.Operator.Type0]
+		operator.Lexeme = strings.TrimSuffix(expr.Operator.Lexeme, "=")
+		value, err = i.binary(operator, current, value)
 		if err != nil {
 			return nil, err
 		}
 	}
+	err = i.assignVariable(expr.Name, expr, value)
+	if err != nil {
+		return nil, err
+	}
 	return value, nil
 
 }
 
+func (i *Interpreter) VisitUpdateExpr(expr *ast.Update) (interface{}, error) {
+	target, ok := expr.Target.(*ast.Variable)
+	if !ok {
+		return nil, common.RuntimeError{HasError: true, Token: expr.Operator, Code: catalog.InvalidTarget, Reason: "Invalid increment or decrement target"}
+	}
+	old, err := i.lookUpVariable(target.Name, target)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := old.(float64); !ok {
+		return nil, common.RuntimeError{HasError: true, Token: expr.Operator, Code: catalog.OperandType, Reason: "Operand must be a number."}
+	}
+	operator := expr.Operator
+	if expr.Operator.Type0 == lexer.INCREMENT {
+		operator.Type0, operator.Lexeme = lexer.PLUS, "+"
+	} else {
+		operator.Type0, operator.Lexeme = lexer.MINUS, "-"
+	}
+	value, err := i.binary(operator, old, 1.0)
+	if err != nil {
+		return nil, err
+	}
+	err = i.assignVariable(target.Name, target, value)
+	if err != nil {
+		return nil, err
+	}
+	if expr.Prefix {
+		return value, nil
+	}
+	return old, nil
+}
+
+func (i *Interpreter) assignVariable(name lexer.Token, expr ast.Expr, value interface{}) error {
+	if distance, ok := i.locals[expr]; ok {
+		i.environment.AssignAt(distance, name, value)
+	} else if err := i.global.Assign(name, value); err != nil {
+		if _, undefined := i.global.Get(name); undefined != nil {
+			return i.environment.Undefined(name, false)
+		}
+		return err
+	}
+	if i.hooks != nil {
+		i.hooks.OnAssign(i, name.Lexeme, value)
+	}
+	return nil
+}
+
+// EvaluateConstant evaluates an expression built from literals only, e.g. to fold it before running a program. It
+// fails exactly like evaluating the expression at runtime would; expr must not refer to variables or call anything.
+func EvaluateConstant(expr ast.Expr) (interface{}, error) {
+	return (&Interpreter{}).evaluate(expr)
+}
+
+// IsTruthy tells whether a condition with this value holds.
+func IsTruthy(value interface{}) bool {
+	return (&Interpreter{}).isTruthy(value)
+}
+
 func (i *Interpreter) isTruthy(object interface{}) bool {
 	/*
 		isTruthy follow ruby rule of judging true and false
@@ -526,17 +749,17 @@ func (i *Interpreter) checkNumberOperand(operator lexer.Token, operand ast.Liter
 	if operand.Type == lexer.NUMBER {
 		return nil
 	}
-	return common.RuntimeError{HasError: true, Token: operator, Reason: "operand must be a number"}
+	return common.RuntimeError{HasError: true, Token: operator, Code: catalog.OperandType, Reason: "Operand must be a number."}
 }
 
 func (i *Interpreter) checkNumberOperands(operator lexer.Token, operandLeft ast.Literal, operandRight ast.Literal) error {
 	if operandLeft.Type == lexer.NUMBER && operandRight.Type == lexer.NUMBER {
 		return nil
 	}
-	return common.RuntimeError{HasError: true, Token: operator, Reason: "operand must be a number"}
+	return common.RuntimeError{HasError: true, Token: operator, Code: catalog.OperandType, Reason: "Operands must be numbers."}
 }
 
-func (i *Interpreter) stringify(object interface{}) interface{} {
+func stringify(object interface{}) string {
 	if object == nil {
 		return "nil"
 	}
@@ -559,6 +782,9 @@ func (i *Interpreter) stringify(object interface{}) interface{} {
 		text := strconv.Itoa(v)
 		return text
 	}
+	if v, ok := object.(fmt.Stringer); ok {
+		return v.String()
+	}
 
-	return object
+	return fmt.Sprint(object)
 }
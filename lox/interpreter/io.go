@@ -0,0 +1,89 @@
+package interpreter
+
+import (
+	"bufio"
+	"fmt"
+	"golox/lox/common"
+	"golox/utils"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// streams are the standard streams of an interpreter, shared with the interpreters it forks. Writes are
+// serialized so that lines printed by concurrent tasks don't interleave, reads have their own mutex so that a task
+// waiting for input doesn't hold up the output of the others.
+type streams struct {
+	mutex    sync.Mutex
+	input    sync.Mutex
+	stdin    *bufio.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	reporter *utils.Reporter
+}
+
+func newStreams() *streams {
+	return &streams{stdin: bufio.NewReader(os.Stdin), stdout: os.Stdout, stderr: os.Stderr, reporter: utils.DefaultReporter}
+}
+
+// SetStdin sets the stream read by the `readLine` native. A *bufio.Reader is read as is, so that a caller reading
+// the same stream through it doesn't lose what the interpreter buffered, or the other way round.
+func (i *Interpreter) SetStdin(stdin io.Reader) {
+	if reader, ok := stdin.(*bufio.Reader); ok {
+		i.streams.stdin = reader
+		return
+	}
+	i.streams.stdin = bufio.NewReader(stdin)
+}
+
+// SetStdout sets the stream `print` writes to.
+func (i *Interpreter) SetStdout(stdout io.Writer) {
+	i.streams.stdout = stdout
+}
+
+// SetStderr sets the stream runtime errors are reported to.
+func (i *Interpreter) SetStderr(stderr io.Writer) {
+	i.streams.stderr = stderr
+	i.streams.reporter = utils.NewReporter(stderr)
+}
+
+// SetReporter sets where runtime errors are reported, in place of the stream set by SetStderr.
+func (i *Interpreter) SetReporter(reporter *utils.Reporter) {
+	i.streams.reporter = reporter
+}
+
+func (t *streams) println(text string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, _ = fmt.Fprintln(t.stdout, text)
+}
+
+func (t *streams) raiseError(err common.RuntimeError) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.reporter.Diagnose(utils.Diagnostic{Line: err.Token.Line, Code: err.Code, Message: err.Reason, Fix: err.Fix})
+}
+
+// ReadLine is the `readLine()` native, it returns the next line of the interpreter's input without its line
+// terminator, or nil at the end of the input.
+type ReadLine struct {
+}
+
+func (t *ReadLine) Arity() (int, int) {
+	return 0, 0
+}
+
+func (t *ReadLine) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	interpreter.streams.input.Lock()
+	defer interpreter.streams.input.Unlock()
+	line, err := interpreter.streams.stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return nil, nil
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (t *ReadLine) String() string {
+	return "<native fn>"
+}
@@ -0,0 +1,203 @@
+package interpreter
+
+import (
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/common"
+	"golox/lox/environment"
+	"golox/lox/lexer"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// LoxIterator yields the elements of a sequence one at a time. Next reports false once the sequence is exhausted.
+type LoxIterator interface {
+	Next() (interface{}, bool, error)
+}
+
+// LoxIterable is implemented by the native values a for-in loop can walk over.
+type LoxIterable interface {
+	Iterator() LoxIterator
+}
+
+// iterator returns the iterator for a for-in loop over value. Besides native iterables, strings are iterated
+// rune by rune and a user function taking no argument is an iterator itself: it is called once per iteration
+// until it returns nil.
+func (i *Interpreter) iterator(value interface{}, token lexer.Token) (LoxIterator, error) {
+	switch v := value.(type) {
+	case LoxIterable:
+		return v.Iterator(), nil
+	case string:
+		return &stringIterator{value: v}, nil
+	case *LoxFunction:
+		if v.Declaration.Generator {
+			return nil, common.RuntimeError{HasError: true, Token: token, Code: catalog.NotIterable, Reason: "Can't iterate over a generator function, call it to create a generator"}
+		}
+		if minArity, _ := v.Arity(); minArity == 0 {
+			return &callableIterator{interpreter: i, callable: v}, nil
+		}
+	case LoxCallable:
+		if minArity, _ := v.Arity(); minArity == 0 {
+			return &callableIterator{interpreter: i, callable: v}, nil
+		}
+	}
+	return nil, common.RuntimeError{HasError: true, Token: token, Code: catalog.NotIterable, Reason: "Can only iterate over strings, lists, maps, ranges and iterator functions"}
+}
+
+func (i *Interpreter) VisitForInStmt(stmt *ast.ForIn) (interface{}, error) {
+	iterable, err := i.evaluate(stmt.Iterable)
+	if err != nil {
+		return nil, err
+	}
+	iterator, err := i.iterator(iterable, stmt.Keyword)
+	if err != nil {
+		return nil, err
+	}
+	// the loop waits while a generator body runs on its own task
+	generator, isGenerator := iterator.(*LoxGenerator)
+	for {
+		var value interface{}
+		var ok bool
+		var err error
+		if isGenerator {
+			i.onSuspend()
+			value, ok, err = generator.next(i.generator)
+		} else {
+			value, ok, err = iterator.Next()
+		}
+		if v, isArgumentError := err.(*ArgumentError); isArgumentError {
+			return nil, common.RuntimeError{HasError: true, Token: stmt.Keyword, Code: v.Code, Reason: v.Reason}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+		// a fresh environment per iteration so closures capture the value of that iteration
+		loopEnvironment := environment.GetEnclosingEnvironment(i.environment)
+		loopEnvironment.Define(stmt.Name.Lexeme, value)
+		i.onDefine(stmt.Name.Lexeme, value)
+		_, err = i.executeBlock([]ast.Stmt{stmt.Body}, loopEnvironment)
+		if err != nil {
+			return nil, err
+		}
+		if i.continueState {
+			i.continueState = false
+		} else if i.breakState {
+			i.breakState = false
+			return nil, nil
+		}
+	}
+}
+
+type stringIterator struct {
+	value  string
+	offset int
+}
+
+func (t *stringIterator) Next() (interface{}, bool, error) {
+	if t.offset >= len(t.value) {
+		return nil, false, nil
+	}
+	_, width := utf8.DecodeRuneInString(t.value[t.offset:])
+	character := t.value[t.offset : t.offset+width]
+	t.offset += width
+	return character, true, nil
+}
+
+type callableIterator struct {
+	interpreter *Interpreter
+	callable    LoxCallable
+}
+
+func (t *callableIterator) Next() (interface{}, bool, error) {
+	value, err := t.callable.Call(t.interpreter, []interface{}{})
+	if err != nil || value == nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (t *LoxList) Iterator() LoxIterator {
+	return &listIterator{list: t}
+}
+
+type listIterator struct {
+	list  *LoxList
+	index int
+}
+
+func (t *listIterator) Next() (interface{}, bool, error) {
+	if t.index >= len(t.list.Elements) {
+		return nil, false, nil
+	}
+	t.index++
+	return t.list.Elements[t.index-1], true, nil
+}
+
+// LoxRange is the lazy arithmetic sequence returned by the `range` native.
+type LoxRange struct {
+	Start float64
+	Stop  float64
+	Step  float64
+}
+
+func (t *LoxRange) Iterator() LoxIterator {
+	return &rangeIterator{r: t, next: t.Start}
+}
+
+func (t *LoxRange) String() string {
+	return "range(" + stringify(t.Start) + ", " + stringify(t.Stop) + ", " + stringify(t.Step) + ")"
+}
+
+type rangeIterator struct {
+	r    *LoxRange
+	next float64
+}
+
+func (t *rangeIterator) Next() (interface{}, bool, error) {
+	if (t.r.Step > 0 && t.next >= t.r.Stop) || (t.r.Step < 0 && t.next <= t.r.Stop) {
+		return nil, false, nil
+	}
+	value := t.next
+	t.next += t.r.Step
+	return value, true, nil
+}
+
+// Range is the `range(stop)`, `range(start, stop)` or `range(start, stop, step)` native.
+type Range struct {
+}
+
+func (t *Range) Arity() (int, int) {
+	return 1, 3
+}
+
+func (t *Range) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	bounds := make([]float64, 0, len(arguments))
+	for index, argument := range arguments {
+		v, ok := argument.(float64)
+		if !ok || math.IsNaN(v) {
+			return nil, &ArgumentError{Code: catalog.InvalidNativeArgument, Reason: "range() argument " + strconv.Itoa(index+1) + " must be a number"}
+		}
+		bounds = append(bounds, v)
+	}
+	r := &LoxRange{Start: 0, Step: 1}
+	switch len(bounds) {
+	case 1:
+		r.Stop = bounds[0]
+	case 2:
+		r.Start, r.Stop = bounds[0], bounds[1]
+	case 3:
+		r.Start, r.Stop, r.Step = bounds[0], bounds[1], bounds[2]
+	}
+	if r.Step == 0 {
+		return nil, &ArgumentError{Code: catalog.InvalidNativeArgument, Reason: "range() step can't be zero"}
+	}
+	return r, nil
+}
+
+func (t *Range) String() string {
+	return "<native fn>"
+}
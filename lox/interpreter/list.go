@@ -0,0 +1,31 @@
+package interpreter
+
+import (
+	"strconv"
+	"strings"
+)
+
+// LoxList is the runtime value of a list, created for instance by a rest parameter.
+type LoxList struct {
+	Elements []interface{}
+}
+
+func NewLoxList(elements []interface{}) *LoxList {
+	return &LoxList{Elements: elements}
+}
+
+func (t *LoxList) String() string {
+	elements := make([]string, 0, len(t.Elements))
+	for _, element := range t.Elements {
+		elements = append(elements, repr(element))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// repr is stringify with strings quoted, so that "1" and 1 can be told apart.
+func repr(object interface{}) string {
+	if v, ok := object.(string); ok {
+		return strconv.Quote(v)
+	}
+	return stringify(object)
+}
@@ -0,0 +1,99 @@
+package interpreter
+
+import (
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/common"
+	"strings"
+)
+
+// LoxMap is the value of a map literal. Keys are nil, booleans, numbers or strings, and keep the order they were
+// first written in.
+type LoxMap struct {
+	Keys   []interface{}
+	Values map[interface{}]interface{}
+}
+
+func NewLoxMap() *LoxMap {
+	return &LoxMap{Keys: make([]interface{}, 0), Values: make(map[interface{}]interface{})}
+}
+
+// Set binds key to value, a key set again keeps its place.
+func (t *LoxMap) Set(key interface{}, value interface{}) {
+	key = mapKey(key)
+	if _, ok := t.Values[key]; !ok {
+		t.Keys = append(t.Keys, key)
+	}
+	t.Values[key] = value
+}
+
+// Get returns the value bound to key, and whether there is one.
+func (t *LoxMap) Get(key interface{}) (interface{}, bool) {
+	value, ok := t.Values[mapKey(key)]
+	return value, ok
+}
+
+func (t *LoxMap) String() string {
+	entries := make([]string, 0, len(t.Keys))
+	for _, key := range t.Keys {
+		entries = append(entries, repr(key)+": "+repr(t.Values[key]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// mapKey converts the numbers which aren't float64 so that equal numbers are the same key.
+func mapKey(key interface{}) interface{} {
+	switch v := key.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return key
+}
+
+func isMapKey(key interface{}) bool {
+	switch key.(type) {
+	case nil, bool, float64, int, int64, string:
+		return true
+	}
+	return false
+}
+
+func (i *Interpreter) VisitMapExpr(expr *ast.Map) (interface{}, error) {
+	result := NewLoxMap()
+	for index, keyExpr := range expr.Keys {
+		key, err := i.evaluate(keyExpr)
+		if err != nil {
+			return nil, err
+		}
+		if !isMapKey(key) {
+			return nil, common.RuntimeError{HasError: true, Token: expr.Brace, Code: catalog.InvalidMapKey, Reason: "Map keys must be nil, booleans, numbers or strings, got " + TypeName(key) + "."}
+		}
+		value, err := i.evaluate(expr.Values[index])
+		if err != nil {
+			return nil, err
+		}
+		result.Set(key, value)
+	}
+	return result, nil
+}
+
+// Iterator yields the entries of the map as [key, value] lists.
+func (t *LoxMap) Iterator() LoxIterator {
+	return &mapIterator{m: t}
+}
+
+type mapIterator struct {
+	m     *LoxMap
+	index int
+}
+
+func (t *mapIterator) Next() (interface{}, bool, error) {
+	if t.index >= len(t.m.Keys) {
+		return nil, false, nil
+	}
+	key := t.m.Keys[t.index]
+	t.index++
+	return NewLoxList([]interface{}{key, t.m.Values[key]}), true, nil
+}
@@ -0,0 +1,109 @@
+package interpreter
+
+import (
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/common"
+	"golox/lox/environment"
+	"golox/lox/lexer"
+)
+
+func (i *Interpreter) VisitMatchStmt(stmt *ast.Match) (interface{}, error) {
+	subject, err := i.evaluate(stmt.Subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, matchCase := range stmt.Cases {
+		for _, pattern := range matchCase.Patterns {
+			m := &matcher{interpreter: i, bindings: make(map[string]interface{}, 0)}
+			if !m.match(pattern, subject) {
+				continue
+			}
+			caseEnvironment := environment.GetEnclosingEnvironment(i.environment)
+			for name, value := range m.bindings {
+				caseEnvironment.Define(name, value)
+				i.onDefine(name, value)
+			}
+			if matchCase.Guard != nil {
+				guard, err := i.evaluateIn(matchCase.Guard, caseEnvironment)
+				if err != nil {
+					return nil, err
+				}
+				if !i.isTruthy(guard) {
+					continue
+				}
+			}
+			return i.executeBlock([]ast.Stmt{matchCase.Body}, caseEnvironment)
+		}
+	}
+	return nil, common.RuntimeError{HasError: true, Token: stmt.Keyword, Code: catalog.NonExhaustiveMatch, Reason: "Non-exhaustive match: no case matches " + stringify(subject)}
+}
+
+// matcher tests a value against a pattern and collects the variables bound along the way.
+type matcher struct {
+	interpreter *Interpreter
+	value       interface{}
+	bindings    map[string]interface{}
+}
+
+func (m *matcher) match(pattern ast.Pattern, value interface{}) bool {
+	previous := m.value
+	defer func() {
+		m.value = previous
+	}()
+	m.value = value
+	matched, _ := pattern.Accept(m)
+	return matched.(bool)
+}
+
+func (m *matcher) VisitLiteralPattern(pattern *ast.LiteralPattern) (interface{}, error) {
+	equal, err := m.interpreter.binary(lexer.Token{Type0: lexer.EQUAL_EQUAL, Lexeme: "==", Line: pattern.Token.Line}, pattern.Value, m.value)
+	if err != nil {
+		return false, nil
+	}
+	return equal, nil
+}
+
+func (m *matcher) VisitBindingPattern(pattern *ast.BindingPattern) (interface{}, error) {
+	m.bindings[pattern.Name.Lexeme] = m.value
+	return true, nil
+}
+
+func (m *matcher) VisitWildcardPattern(_ *ast.WildcardPattern) (interface{}, error) {
+	return true, nil
+}
+
+func (m *matcher) VisitListPattern(pattern *ast.ListPattern) (interface{}, error) {
+	list, ok := m.value.(*LoxList)
+	if !ok {
+		return false, nil
+	}
+	if len(list.Elements) < len(pattern.Elements) || (pattern.Rest == nil && len(list.Elements) != len(pattern.Elements)) {
+		return false, nil
+	}
+	for index, element := range pattern.Elements {
+		if !m.match(element, list.Elements[index]) {
+			return false, nil
+		}
+	}
+	if pattern.Rest != nil {
+		rest := make([]interface{}, len(list.Elements)-len(pattern.Elements))
+		copy(rest, list.Elements[len(pattern.Elements):])
+		m.bindings[pattern.Rest.Lexeme] = NewLoxList(rest)
+	}
+	return true, nil
+}
+
+func (m *matcher) VisitMapPattern(pattern *ast.MapPattern) (interface{}, error) {
+	loxMap, ok := m.value.(*LoxMap)
+	if !ok {
+		return false, nil
+	}
+	for index, key := range pattern.Keys {
+		value, ok := loxMap.Get(key.(*ast.LiteralPattern).Value)
+		if !ok || !m.match(pattern.Values[index], value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,196 @@
+package interpreter
+
+import (
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/lexer"
+	"sort"
+	"strings"
+)
+
+// callableArgument checks that the argument of the native name is a function.
+func callableArgument(name string, argument interface{}) (LoxCallable, error) {
+	callable, ok := argument.(LoxCallable)
+	if !ok {
+		return nil, &ArgumentError{Code: catalog.InvalidNativeArgument, Reason: name + "() expects a function"}
+	}
+	return callable, nil
+}
+
+// Type is the `type(value)` native, it returns the name of the type of value as written in type annotations.
+type Type struct {
+}
+
+func (t *Type) Arity() (int, int) {
+	return 1, 1
+}
+
+func (t *Type) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return TypeName(arguments[0]), nil
+}
+
+func (t *Type) String() string {
+	return "<native fn>"
+}
+
+// Arity is the `arity(fn)` native, it returns the number of positional arguments fn requires, leaving out the
+// parameters with a default value and the rest parameter.
+type Arity struct {
+}
+
+func (t *Arity) Arity() (int, int) {
+	return 1, 1
+}
+
+func (t *Arity) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	callable, err := callableArgument("arity", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	minArity, _ := callable.Arity()
+	return float64(minArity), nil
+}
+
+func (t *Arity) String() string {
+	return "<native fn>"
+}
+
+// Name is the `name(fn)` native, it returns the name fn was declared with, or defined as for a native function,
+// and nil for an anonymous function.
+type Name struct {
+}
+
+func (t *Name) Arity() (int, int) {
+	return 1, 1
+}
+
+func (t *Name) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	callable, err := callableArgument("name", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	name := nativeName(callable)
+	if function, ok := callable.(*LoxFunction); ok {
+		name = function.Name
+	}
+	if name == "" {
+		return nil, nil
+	}
+	return name, nil
+}
+
+func (t *Name) String() string {
+	return "<native fn>"
+}
+
+// IsCallable is the `isCallable(value)` native, it tells whether value can be called.
+type IsCallable struct {
+}
+
+func (t *IsCallable) Arity() (int, int) {
+	return 1, 1
+}
+
+func (t *IsCallable) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	_, ok := arguments[0].(LoxCallable)
+	return ok, nil
+}
+
+func (t *IsCallable) String() string {
+	return "<native fn>"
+}
+
+// Globals is the `globals()` native, it returns the global variables, natives included, as a list of
+// `[name, value]` pairs ordered by name.
+type Globals struct {
+}
+
+func (t *Globals) Arity() (int, int) {
+	return 0, 0
+}
+
+func (t *Globals) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	return bindingList(interpreter.global.Bindings()), nil
+}
+
+func (t *Globals) String() string {
+	return "<native fn>"
+}
+
+// Locals is the `locals()` native, it returns the variables of the scopes enclosing the call, the global one left
+// out, as a list of `[name, value]` pairs ordered by name. A variable shadowed by an inner one is left out too.
+type Locals struct {
+}
+
+func (t *Locals) Arity() (int, int) {
+	return 0, 0
+}
+
+func (t *Locals) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	bindings := make(map[string]interface{})
+	for scope := interpreter.environment; scope != nil && scope != interpreter.global; scope = scope.Enclosing() {
+		for name, value := range scope.Bindings() {
+			if _, shadowed := bindings[name]; !shadowed {
+				bindings[name] = value
+			}
+		}
+	}
+	return bindingList(bindings), nil
+}
+
+func (t *Locals) String() string {
+	return "<native fn>"
+}
+
+// bindingList turns bindings into a list of `[name, value]` pairs ordered by name.
+func bindingList(bindings map[string]interface{}) *LoxList {
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]interface{}, len(names))
+	for index, name := range names {
+		pairs[index] = NewLoxList([]interface{}{name, bindings[name]})
+	}
+	return NewLoxList(pairs)
+}
+
+// Source is the `source(fn)` native, it returns the declaration of fn as written, and nil for a native function.
+// A function whose tree wasn't parsed from source, like one built by a tool, gets its declaration as printed by
+// ast.Format.
+type Source struct {
+}
+
+func (t *Source) Arity() (int, int) {
+	return 1, 1
+}
+
+func (t *Source) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	callable, err := callableArgument("source", arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	function, ok := callable.(*LoxFunction)
+	if !ok {
+		return nil, nil
+	}
+	return function.source(), nil
+}
+
+func (t *Source) String() string {
+	return "<native fn>"
+}
+
+func (t *LoxFunction) source() string {
+	declaration := t.Declaration
+	if declaration.Source != "" {
+		return declaration.Source
+	}
+	if t.Name == "" {
+		return ast.FormatExpr(declaration)
+	}
+	name := lexer.Token{Type0: lexer.IDENTIFIER, Lexeme: t.Name, Line: ast.Line(declaration)}
+	statement := &ast.Function{Name: name, Params: declaration.Params, Body: declaration.Body, Generator: declaration.Generator, ReturnType: declaration.ReturnType}
+	return strings.TrimSuffix(ast.Format([]ast.Stmt{statement}), "\n")
+}
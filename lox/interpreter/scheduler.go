@@ -0,0 +1,66 @@
+package interpreter
+
+import (
+	"golox/lox/catalog"
+	"sync"
+)
+
+// scheduler lets the tasks of a program wait for channels and for each other, and turns a deadlock, all the tasks
+// waiting, into a runtime error where Go would end the whole process. The waiting operations of all the tasks hold
+// the same mutex and sleep on the same condition, so the last task to fall asleep knows that nobody is left to wake
+// it up.
+//
+// A generator body runs in place of the task consuming it, so it counts as that task. A task blocked on something
+// outside the program, like readLine() waiting for input, counts as running.
+type scheduler struct {
+	mutex    sync.Mutex
+	changed  *sync.Cond
+	running  int // tasks which aren't asleep, the main script included
+	asleep   int
+	deadlock bool
+}
+
+func newScheduler() *scheduler {
+	s := &scheduler{running: 1}
+	s.changed = sync.NewCond(&s.mutex)
+	return s
+}
+
+// block waits until ready returns true. It is called with the mutex held, like ready.
+func (s *scheduler) block(ready func() bool) error {
+	for !ready() {
+		if s.deadlock || s.running == 1 {
+			s.deadlock = true
+			s.wake()
+			return &ArgumentError{Code: catalog.Deadlock, Reason: "Deadlock: all tasks are waiting"}
+		}
+		s.running--
+		s.asleep++
+		s.changed.Wait()
+	}
+	return nil
+}
+
+// wake lets the tasks asleep check whether they can go on. It is called with the mutex held, after a change which
+// may let them.
+func (s *scheduler) wake() {
+	s.running += s.asleep
+	s.asleep = 0
+	s.changed.Broadcast()
+}
+
+// start counts a new task as running.
+func (s *scheduler) start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.running++
+}
+
+// finish marks task as finished, waking the tasks waiting for it.
+func (s *scheduler) finish(task *LoxTask) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	task.finished = true
+	s.running--
+	s.wake()
+}
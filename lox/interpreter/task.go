@@ -0,0 +1,105 @@
+package interpreter
+
+import (
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"strconv"
+)
+
+// Tasks and the memory model
+//
+// `spawn f(x)` evaluates f and its arguments on the spawning task, then runs the call on a new goroutine with a
+// forked interpreter: each task has its own current environment and loop state, and shares the globals and the
+// environments captured by closures with the other tasks.
+//
+// Reading or writing a single variable is atomic, but nothing orders the accesses of different tasks except
+// channel operations and wait:
+//   - a send on a channel happens before the matching receive completes,
+//   - closing a channel happens before a receive that observes the close,
+//   - everything a task does happens before wait() on its handle returns,
+//   - a generator yielding a value happens before the task which asked for it gets it.
+// Tasks may share a generator: each value goes to one of them, and a task asking for a value while another one is
+// waiting for its own waits its turn.
+// Compound updates of shared variables (`counter++`, `total += x`) are not atomic; hand values over channels
+// instead. The program ends when the main script does, whether or not spawned tasks are finished; a task's error
+// is only reported by wait(). When all the tasks wait for a channel or for another task, none of them can ever go
+// on: the operations which would wait fail with a deadlock error instead.
+
+// LoxTask is the handle returned by `spawn`. Finished is guarded by the mutex of the scheduler.
+type LoxTask struct {
+	name     string
+	finished bool
+	value    interface{}
+	err      error
+}
+
+func (i *Interpreter) VisitSpawnExpr(expr *ast.Spawn) (interface{}, error) {
+	callee, err := i.evaluate(expr.Call.Callee)
+	if err != nil {
+		return nil, err
+	}
+	arguments, named, err := i.evaluateArguments(expr.Call.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	task := &LoxTask{}
+	if v, ok := callee.(*LoxFunction); ok {
+		task.name = v.Name
+	}
+	child := i.fork(Fork{Kind: SpawnFork, Line: expr.Keyword.Line})
+	i.scheduler.start()
+	go func() {
+		defer i.scheduler.finish(task)
+		task.value, task.err = child.call(callee, arguments, named, expr.Call.Paren)
+		child.onError(task.err)
+		child.onSuspend()
+	}()
+	return task, nil
+}
+
+func (t *LoxTask) String() string {
+	if t.name == "" {
+		return "<task>"
+	}
+	return "<task " + t.name + ">"
+}
+
+// Wait is the `wait(task, ...)` native. It blocks until the tasks finish and returns the result of a single task,
+// or the list of results when given several. An error raised by a task is raised again by wait.
+type Wait struct {
+}
+
+func (t *Wait) Arity() (int, int) {
+	return 1, VariadicArity
+}
+
+func (t *Wait) Call(interpreter *Interpreter, arguments []interface{}) (interface{}, error) {
+	results := make([]interface{}, 0, len(arguments))
+	scheduler := interpreter.scheduler
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+	for index, argument := range arguments {
+		task, ok := argument.(*LoxTask)
+		if !ok {
+			return nil, &ArgumentError{Code: catalog.InvalidNativeArgument, Reason: "wait() argument " + strconv.Itoa(index+1) + " must be a task"}
+		}
+		err := scheduler.block(func() bool {
+			return task.finished
+		})
+		if err != nil {
+			return nil, err
+		}
+		if task.err != nil {
+			return nil, task.err
+		}
+		results = append(results, task.value)
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	return NewLoxList(results), nil
+}
+
+func (t *Wait) String() string {
+	return "<native fn>"
+}
@@ -0,0 +1,66 @@
+package interpreter
+
+import (
+	"golox/lox/ast"
+	"golox/lox/catalog"
+	"golox/lox/common"
+	"golox/lox/lexer"
+)
+
+// TypeNames are the names type annotations may use besides `any`, which stands for all of them.
+var TypeNames = []string{"number", "string", "bool", "nil", "list", "map", "function", "range", "generator", "task", "channel"}
+
+// TypeName returns the name of the type of a runtime value, as written in type annotations.
+func TypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case float64, int, int64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case *LoxList:
+		return "list"
+	case *LoxMap:
+		return "map"
+	case *LoxRange:
+		return "range"
+	case *LoxGenerator:
+		return "generator"
+	case *LoxTask:
+		return "task"
+	case *LoxChannel:
+		return "channel"
+	case LoxCallable:
+		return "function"
+	}
+	return "any"
+}
+
+// hasType reports whether value is one of the types named by annotation.
+func hasType(value interface{}, annotation *ast.TypeAnnotation) bool {
+	name := TypeName(value)
+	for _, token := range annotation.Names {
+		if token.Lexeme == "any" || token.Lexeme == name || (token.Type0 == lexer.NIL && value == nil) {
+			return true
+		}
+	}
+	return false
+}
+
+func typeMismatch(annotation *ast.TypeAnnotation, value interface{}) string {
+	return "Expected " + annotation.String() + " but got " + TypeName(value) + "."
+}
+
+func (i *Interpreter) VisitTypeCheckExpr(expr *ast.TypeCheck) (interface{}, error) {
+	value, err := i.evaluate(expr.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if !hasType(value, expr.Type) {
+		return nil, common.RuntimeError{HasError: true, Token: expr.Token, Code: catalog.TypeMismatch, Reason: typeMismatch(expr.Type, value)}
+	}
+	return value, nil
+}
@@ -22,4 +22,12 @@ func init() {
 	KeyWords["while"] = WHILE
 	KeyWords["break"] = BREAK
 	KeyWords["continue"] = CONTINUE
+	KeyWords["const"] = CONST
+	KeyWords["let"] = LET
+	KeyWords["match"] = MATCH
+	KeyWords["case"] = CASE
+	KeyWords["in"] = IN
+	KeyWords["yield"] = YIELD
+	KeyWords["spawn"] = SPAWN
+	KeyWords["assert"] = ASSERT
 }
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"golox/lox/catalog"
 	"golox/utils"
 	"strconv"
 )
@@ -11,24 +12,34 @@ type Lexer struct {
 	start   int
 	current int
 	line    int
+	column  int // column of the lexeme being scanned, see Token.Column
+	lineAt  int // offset of the start of the current line
 
-	error LexerError
+	error    LexerError // the first error met, scanning goes on after it
+	reporter *utils.Reporter
 }
 
 func NewLexer(source string) *Lexer {
-	return &Lexer{source: source, start: 0, current: 0, line: 1}
+	return &Lexer{source: source, start: 0, current: 0, line: 1, reporter: utils.DefaultReporter}
 }
 
+// SetReporter sets where lexing errors are reported.
+func (t *Lexer) SetReporter(reporter *utils.Reporter) {
+	t.reporter = reporter
+}
+
+// ScanTokens scans the whole source, reporting every error it meets, and returns the tokens it recognised along
+// with the first error.
 func (t *Lexer) ScanTokens() ([]Token, LexerError) {
 	for !t.isAtEnd() {
 		// We are at the beginning of the next lexeme
 		t.start = t.current
+		t.column = t.start - t.lineAt + 1
 		t.scanToken()
-		if t.error.HasError {
-			return t.tokens, t.error
-		}
 	}
-	t.tokens = append(t.tokens, *NewToken(EOF, "", "", t.line))
+	eof := NewToken(EOF, "", "", t.line)
+	eof.Column, eof.Offset = t.current-t.lineAt+1, t.current
+	t.tokens = append(t.tokens, *eof)
 	return t.tokens, t.error
 }
 
@@ -47,15 +58,29 @@ func (t *Lexer) scanToken() {
 	case "}":
 		t.addToken(RIGHT_BRACE)
 		break
+	case "[":
+		t.addToken(LEFT_BRACKET)
+		break
+	case "]":
+		t.addToken(RIGHT_BRACKET)
+		break
 	case ",":
 		t.addToken(COMMA)
 		break
 	case ".":
-		t.addToken(DOT)
+		if t.peek() == "." && t.peekNext() == "." {
+			t.advance()
+			t.advance()
+			t.addToken(ELLIPSIS)
+		} else {
+			t.addToken(DOT)
+		}
 		break
 	case "-":
 		if t.match("-") {
 			t.addToken(DECREMENT)
+		} else if t.match("=") {
+			t.addToken(MINUS_EQUAL)
 		} else {
 			t.addToken(MINUS)
 		}
@@ -63,6 +88,8 @@ func (t *Lexer) scanToken() {
 	case "+":
 		if t.match("+") {
 			t.addToken(INCREMENT)
+		} else if t.match("=") {
+			t.addToken(PLUS_EQUAL)
 		} else {
 			t.addToken(PLUS)
 		}
@@ -71,7 +98,18 @@ func (t *Lexer) scanToken() {
 		t.addToken(SEMICOLON)
 		break
 	case "*":
-		t.addToken(STAR)
+		if t.match("=") {
+			t.addToken(STAR_EQUAL)
+		} else {
+			t.addToken(STAR)
+		}
+		break
+	case "%":
+		if t.match("=") {
+			t.addToken(PERCENT_EQUAL)
+		} else {
+			t.addToken(PERCENT)
+		}
 		break
 
 	case "!":
@@ -84,6 +122,8 @@ func (t *Lexer) scanToken() {
 	case "=":
 		if t.match("=") {
 			t.addToken(EQUAL_EQUAL)
+		} else if t.match(">") {
+			t.addToken(ARROW)
 		} else {
 			t.addToken(EQUAL)
 		}
@@ -107,6 +147,8 @@ func (t *Lexer) scanToken() {
 		t.addToken(QUESTION)
 	case ":":
 		t.addToken(COLON)
+	case "|":
+		t.addToken(PIPE)
 	case "/":
 		if t.match("/") {
 			// A comment goes until the end of the line
@@ -121,15 +163,17 @@ func (t *Lexer) scanToken() {
 						break
 					}
 					if t.match("\n") {
-						t.line++ // fix line count after supporting nested comment
+						t.newline(t.current - 1) // fix line count after supporting nested comment
 					}
 				} else {
 					if t.peek() == "\n" {
-						t.line++ // fix line count after supporting nested comment
+						t.newline(t.current) // fix line count after supporting nested comment
 					}
 					t.advance()
 				}
 			}
+		} else if t.match("=") {
+			t.addToken(SLASH_EQUAL)
 		} else {
 			t.addToken(SLASH)
 		}
@@ -143,7 +187,7 @@ func (t *Lexer) scanToken() {
 		// Ignore whitespace
 		break
 	case "\n":
-		t.line++
+		t.newline(t.current - 1)
 		break
 
 	case `"`:
@@ -156,7 +200,7 @@ func (t *Lexer) scanToken() {
 		} else if isAlpha(c) {
 			t.identifier()
 		} else {
-			t.error = LexerError{true, t.line, "Unexpected character."}
+			t.raiseError(catalog.UnexpectedCharacter, "Unexpected character.")
 			return
 		}
 		break
@@ -169,13 +213,21 @@ func (t *Lexer) advance() string {
 	return string(t.source[t.current-1])
 }
 
+// newline counts the line break at offset.
+func (t *Lexer) newline(offset int) {
+	t.line++
+	t.lineAt = offset + 1
+}
+
 func (t *Lexer) addToken(type0 TokenType) {
 	t.addTokenWithLiteral(type0, "")
 }
 
 func (t *Lexer) addTokenWithLiteral(type0 TokenType, literal interface{}) {
 	text := t.source[t.start:t.current]
-	t.tokens = append(t.tokens, *NewToken(type0, text, literal, t.line))
+	token := NewToken(type0, text, literal, t.line)
+	token.Column, token.Offset = t.column, t.start
+	t.tokens = append(t.tokens, *token)
 }
 
 func (t *Lexer) isAtEnd() bool {
@@ -203,13 +255,13 @@ func (t *Lexer) peek() string {
 func (t *Lexer) string() {
 	for t.peek() != `"` && !t.isAtEnd() {
 		if t.peek() == "\n" {
-			t.line++
+			t.newline(t.current)
 		}
 		t.advance()
 	}
 
 	if t.isAtEnd() {
-		t.error = LexerError{true, t.line, "Unterminated string"}
+		t.raiseError(catalog.UnterminatedString, "Unterminated string.")
 		return
 	}
 
@@ -236,12 +288,7 @@ func (t *Lexer) number() {
 	// When building interpreter in Chapter7 I know I must save the value correspond to its type if value is float then save float value as its literal
 	float, err := strconv.ParseFloat(t.source[t.start:t.current], 64)
 	if err != nil {
-		utils.RaiseError(t.line, "lexing error failed to parse float literal")
-		t.error = LexerError{
-			HasError: true,
-			Line:     t.line,
-			Reason:   "lexing error failed to parse float literal",
-		}
+		t.raiseError(catalog.InvalidNumber, "Invalid number literal.")
 	}
 
 	t.addTokenWithLiteral(NUMBER, float)
@@ -280,3 +327,10 @@ func isAlpha(c string) bool {
 func isAlphaNumeric(c string) bool {
 	return isAlpha(c) || isDigit(c)
 }
+
+func (t *Lexer) raiseError(code string, reason string) {
+	t.reporter.Diagnose(utils.Diagnostic{Line: t.line, Code: code, Message: reason})
+	if !t.error.HasError {
+		t.error = LexerError{HasError: true, Line: t.line, Reason: reason}
+	}
+}
@@ -7,6 +7,8 @@ type Token struct {
 	Lexeme  string
 	Literal interface{}
 	Line    int
+	Column  int // of the start of the lexeme on its line, counted in bytes from 1
+	Offset  int // byte offset of the lexeme in the source
 }
 
 func NewToken(type0 TokenType, lexeme string, literal interface{}, line int) *Token {
@@ -8,8 +8,11 @@ const (
 	RIGHT_PAREN
 	LEFT_BRACE
 	RIGHT_BRACE
+	LEFT_BRACKET
+	RIGHT_BRACKET
 	COMMA
 	DOT
+	ELLIPSIS
 	MINUS
 	PLUS
 	INCREMENT
@@ -17,6 +20,8 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
+	PERCENT
+	PIPE
 
 	// One or two character tokens.
 	BANG
@@ -28,6 +33,14 @@ const (
 	LESS
 	LESS_EQUAL
 	COLON
+	ARROW
+
+	// Compound assignment.
+	PLUS_EQUAL
+	MINUS_EQUAL
+	STAR_EQUAL
+	SLASH_EQUAL
+	PERCENT_EQUAL
 
 	//ternary
 	QUESTION
@@ -56,6 +69,14 @@ const (
 	WHILE
 	BREAK
 	CONTINUE
+	CONST
+	LET
+	MATCH
+	CASE
+	IN
+	YIELD
+	SPAWN
+	ASSERT
 	EOF
 )
 
@@ -65,4 +86,19 @@ var TokenTypeMapper = map[int]string{LEFT_PAREN: "LEFT_PAREN", RIGHT_PAREN: "RIG
 	GREATER: "GREATER", GREATER_EQUAL: "GREATER_EQUAL", LESS: "LESS", LESS_EQUAL: "LESS_EQUAL", IDENTIFIER: "IDENTIFIER",
 	STRING: "STRING", NUMBER: "NUMBER", AND: "AND", CLASS: "CLASS", ELSE: "ELSE", FALSE: "FALSE", FUN: "FUN", FOR: "FOR",
 	IF: "IF", NIL: "NIL", OR: "OR", PRINT: "PRINT", RETURN: "RETURN", SUPER: "SUPER", THIS: "THIS", TRUE: "TRUE", VAR: "VAR",
-	WHILE: "WHILE", EOF: "EOF"}
+	WHILE: "WHILE", EOF: "EOF", INCREMENT: "INCREMENT", DECREMENT: "DECREMENT", PERCENT: "PERCENT", PLUS_EQUAL: "PLUS_EQUAL",
+	MINUS_EQUAL: "MINUS_EQUAL", STAR_EQUAL: "STAR_EQUAL", SLASH_EQUAL: "SLASH_EQUAL", PERCENT_EQUAL: "PERCENT_EQUAL",
+	ELLIPSIS: "ELLIPSIS", CONST: "CONST", LET: "LET", LEFT_BRACKET: "LEFT_BRACKET", RIGHT_BRACKET: "RIGHT_BRACKET",
+	ARROW: "ARROW", MATCH: "MATCH", CASE: "CASE", IN: "IN", YIELD: "YIELD", SPAWN: "SPAWN",
+	ASSERT: "ASSERT", COLON: "COLON", QUESTION: "QUESTION", BREAK: "BREAK", CONTINUE: "CONTINUE",
+	PIPE: "PIPE"}
+
+// TokenTypeByName is the inverse of TokenTypeMapper.
+func TokenTypeByName(name string) (TokenType, bool) {
+	for tokenType, tokenName := range TokenTypeMapper {
+		if tokenName == name {
+			return TokenType(tokenType), true
+		}
+	}
+	return 0, false
+}
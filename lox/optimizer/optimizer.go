@@ -0,0 +1,137 @@
+// Package optimizer simplifies a resolved program before it is interpreted: it folds constant expressions, drops
+// the branches and loops whose conditions are constant, and removes statements which can never run.
+package optimizer
+
+import (
+	"golox/lox/ast"
+	"golox/lox/interpreter"
+	"golox/lox/lexer"
+)
+
+type Optimizer struct {
+	// keep holds the binary conditions of asserts: folding them would lose the operands the failure reports.
+	keep map[ast.Expr]bool
+}
+
+// Optimize rewrites statements in place and returns the optimized program. It runs after the resolver: every
+// variable it keeps is one the resolver has seen, and the folding never creates new ones.
+func Optimize(statements []ast.Stmt) []ast.Stmt {
+	o := &Optimizer{keep: make(map[ast.Expr]bool)}
+	for _, statement := range statements {
+		ast.Inspect(statement, func(node ast.Node) bool {
+			if assert, ok := node.(*ast.Assert); ok {
+				if _, isBinary := assert.Condition.(*ast.Binary); isBinary {
+					o.keep[assert.Condition] = true
+				}
+			}
+			return true
+		})
+	}
+	optimized := make([]ast.Stmt, 0, len(statements))
+	for _, statement := range statements {
+		if result := ast.Transform(statement, o.rewrite); result != nil {
+			optimized = append(optimized, result.(ast.Stmt))
+		}
+	}
+	return prune(optimized)
+}
+
+// rewrite is called by ast.Transform on every node, after its children have been rewritten.
+func (o *Optimizer) rewrite(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.Grouping:
+		if literal, ok := n.Expression.(*ast.Literal); ok {
+			return literal
+		}
+	case *ast.Unary:
+		if isLiteral(n.Right) {
+			return fold(n)
+		}
+	case *ast.Binary:
+		if isLiteral(n.Left) && isLiteral(n.Right) && !o.keep[n] {
+			return fold(n)
+		}
+	case *ast.Logical:
+		if left, ok := n.Left.(*ast.Literal); ok {
+			// `and` yields its left operand when that is falsy, `or` when it is truthy
+			if interpreter.IsTruthy(left.Value) == (n.Operator.Type0 == lexer.OR) {
+				return n.Left
+			}
+			return n.Right
+		}
+	case *ast.Ternary:
+		if condition, ok := n.ConditionalExpr.(*ast.Literal); ok {
+			if interpreter.IsTruthy(condition.Value) {
+				return n.ThenExpr
+			}
+			return n.ElseExpr
+		}
+	case *ast.If:
+		if condition, ok := n.Condition.(*ast.Literal); ok {
+			if interpreter.IsTruthy(condition.Value) {
+				return n.ThenBranch
+			}
+			if n.ElseBranch != nil {
+				return n.ElseBranch
+			}
+			return &ast.Block{}
+		}
+	case *ast.While:
+		if condition, ok := n.Condition.(*ast.Literal); ok && !interpreter.IsTruthy(condition.Value) {
+			return &ast.Block{}
+		}
+	case *ast.Block:
+		n.Statements = prune(n.Statements)
+	case *ast.Function:
+		n.Body = prune(n.Body)
+	case *ast.FunctionExpr:
+		n.Body = prune(n.Body)
+	case *ast.Test:
+		n.Body = prune(n.Body)
+	}
+	return node
+}
+
+func isLiteral(expr ast.Expr) bool {
+	_, ok := expr.(*ast.Literal)
+	return ok
+}
+
+// fold evaluates an expression whose operands are literals and returns its value as a literal. An expression
+// failing at runtime is kept, so that the error is still raised when it is reached.
+func fold(expr ast.Expr) ast.Expr {
+	value, err := interpreter.EvaluateConstant(expr)
+	if err != nil {
+		return expr
+	}
+	switch v := value.(type) {
+	case nil:
+		return &ast.Literal{Type: lexer.NIL, Value: nil}
+	case bool:
+		if v {
+			return &ast.Literal{Type: lexer.TRUE, Value: true}
+		}
+		return &ast.Literal{Type: lexer.FALSE, Value: false}
+	case float64:
+		return &ast.Literal{Type: lexer.NUMBER, Value: v}
+	case string:
+		return &ast.Literal{Type: lexer.STRING, Value: v}
+	}
+	return expr
+}
+
+// prune drops the empty blocks left by removed branches and the statements following a return, break or continue.
+func prune(statements []ast.Stmt) []ast.Stmt {
+	result := statements[:0]
+	for _, statement := range statements {
+		if block, ok := statement.(*ast.Block); ok && len(block.Statements) == 0 {
+			continue
+		}
+		result = append(result, statement)
+		switch statement.(type) {
+		case *ast.Return, *ast.Break, *ast.Continue:
+			return result
+		}
+	}
+	return result
+}
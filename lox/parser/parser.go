@@ -3,56 +3,85 @@ package parser
 import (
 	"errors"
 	"golox/lox/ast"
+	"golox/lox/catalog"
 	"golox/lox/lexer"
+	"golox/lox/suggest"
 	"golox/utils"
+	"strings"
 )
 
 type Parser struct {
-	tokens  []lexer.Token
-	current int
+	tokens   []lexer.Token
+	current  int
+	sawYield bool   // a yield was parsed in the body of the innermost function
+	source   string // the source the tokens were scanned from, see SetSource
+	hadError bool
+	reporter *utils.Reporter
 }
 
+// NewParser returns a parser for tokens. Tokens not ending with EOF, as the lexer produces them, get one appended so
+// that peeking past the last token is never out of range.
 func NewParser(tokens []lexer.Token) *Parser {
-	return &Parser{tokens: tokens, current: 0}
+	if len(tokens) == 0 || tokens[len(tokens)-1].Type0 != lexer.EOF {
+		line := 1
+		if len(tokens) > 0 {
+			line = tokens[len(tokens)-1].Line
+		}
+		tokens = append(tokens[:len(tokens):len(tokens)], lexer.Token{Type0: lexer.EOF, Line: line})
+	}
+	return &Parser{tokens: tokens, current: 0, reporter: utils.DefaultReporter}
+}
+
+// SetSource sets the source the tokens were scanned from, so that function declarations keep their text as written.
+// Without it their Source is empty.
+func (p *Parser) SetSource(source string) {
+	p.source = source
+}
+
+// SetReporter sets where parse errors are reported.
+func (p *Parser) SetReporter(reporter *utils.Reporter) {
+	p.reporter = reporter
 }
 
 func (p *Parser) Parse() ([]ast.Stmt, ParseError) {
 	statements := make([]ast.Stmt, 0)
 	for !p.isAtEnd() {
-		statement, err := p.declaration()
-		if err != nil {
-			return statements, ParseError{HasError: true}
+		statement, _ := p.declaration()
+		if statement != nil {
+			statements = append(statements, statement)
 		}
-		statements = append(statements, statement)
 	}
-	return statements, ParseError{HasError: false}
-	//expr, err := p.expression()
-	//if err != nil {
-	//	return nil, ParseError{HasError: true}
-	//} else {
-	//	return expr, ParseError{HasError: false}
-	//}
+	return statements, ParseError{HasError: p.hadError}
 }
 
+// declaration parses a declaration or a statement. After a syntax error it reports nothing more, skips to the
+// next statement boundary and returns a nil statement, so parsing goes on and finds the following errors too.
 func (p *Parser) declaration() (ast.Stmt, error) {
+	stmt, err := p.declarationOrStatement()
+	if err != nil {
+		p.synchronize()
+		return nil, nil
+	}
+	return stmt, nil
+}
+
+func (p *Parser) declarationOrStatement() (ast.Stmt, error) {
 	if p.check(lexer.FUN) && p.checkNext(lexer.IDENTIFIER) {
 		_, _ = p.Consume(lexer.FUN, "")
 		return p.function("function")
 	}
-	if p.match(lexer.VAR) {
-		stmt, err := p.varDeclaration()
-		if err != nil {
-			p.synchronize()
-			return nil, nil
-		}
-		return stmt, nil
+	if p.check(lexer.FUN) && p.checkNext(lexer.STAR) && p.checkAt(2, lexer.IDENTIFIER) {
+		_, _ = p.Consume(lexer.FUN, "")
+		return p.function("generator")
 	}
-	stmt, err := p.statement()
-	if err != nil {
-		p.synchronize()
-		return nil, nil
+	if p.match(lexer.VAR, lexer.CONST, lexer.LET) {
+		return p.varDeclaration()
 	}
-	return stmt, nil
+	// `test` is only a keyword in front of the test name, so it stays usable as an identifier.
+	if p.check(lexer.IDENTIFIER) && p.peek().Lexeme == "test" && p.checkNext(lexer.STRING) {
+		return p.testDeclaration()
+	}
+	return p.statement()
 }
 
 func (p *Parser) statement() (ast.Stmt, error) {
@@ -77,6 +106,15 @@ func (p *Parser) statement() (ast.Stmt, error) {
 	if p.match(lexer.CONTINUE) {
 		return p.continueStatement()
 	}
+	if p.match(lexer.MATCH) {
+		return p.matchStatement()
+	}
+	if p.match(lexer.YIELD) {
+		return p.yieldStatement()
+	}
+	if p.match(lexer.ASSERT) {
+		return p.assertStatement()
+	}
 	if p.match(lexer.LEFT_BRACE) {
 		statements, err := p.block()
 		if err != nil {
@@ -90,10 +128,17 @@ func (p *Parser) statement() (ast.Stmt, error) {
 func (p *Parser) forStatement() (ast.Stmt, error) {
 	var initializer ast.Stmt
 	var err error
-	_, err = p.Consume(lexer.LEFT_PAREN, "Expect '(' after 'for'")
+	keyword := p.previous()
+	_, err = p.Consume(lexer.LEFT_PAREN, "Expect '(' after 'for'.")
+	if p.check(lexer.IDENTIFIER) && p.checkNext(lexer.IN) {
+		return p.forInStatement(keyword)
+	}
 	if p.match(lexer.SEMICOLON) {
 		initializer = nil
 	} else if p.match(lexer.VAR) {
+		if p.check(lexer.IDENTIFIER) && p.checkNext(lexer.IN) {
+			return p.forInStatement(keyword)
+		}
 		initializer, err = p.varDeclaration()
 		if err != nil {
 			return nil, err
@@ -113,7 +158,7 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 			return nil, err
 		}
 	}
-	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after loop condition")
+	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after loop condition.")
 
 	var incremental ast.Expr
 	if !p.check(lexer.RIGHT_PAREN) {
@@ -122,7 +167,7 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 			return nil, err
 		}
 	}
-	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after for clauses")
+	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after for clauses.")
 
 	body, err := p.statement()
 	if err != nil {
@@ -132,7 +177,7 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 	if condition == nil {
 		condition = &ast.Literal{Type: lexer.TRUE, Value: true}
 	}
-	body = &ast.While{Condition: condition, Body: body, OptionalMutate: incremental}
+	body = &ast.While{Keyword: keyword, Condition: condition, Body: body, OptionalMutate: incremental}
 	if initializer != nil {
 		body = &ast.Block{Statements: []ast.Stmt{initializer, body}}
 	}
@@ -140,24 +185,77 @@ func (p *Parser) forStatement() (ast.Stmt, error) {
 
 }
 
+func (p *Parser) forInStatement(keyword lexer.Token) (ast.Stmt, error) {
+	name := p.advance()
+	p.advance()
+	iterable, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after for-in clause")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.statement()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ForIn{Keyword: keyword, Name: name, Iterable: iterable, Body: body}, nil
+}
+
 func (p *Parser) ifStatement() (ast.Stmt, error) {
 	var err error
-	_, err = p.Consume(lexer.LEFT_PAREN, "Expect '(' after 'if'")
+	keyword := p.previous()
+	_, err = p.Consume(lexer.LEFT_PAREN, "Expect '(' after 'if'.")
 	condition, err := p.expression()
-	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after 'if'")
+	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after if condition.")
 
 	thenBranch, err := p.statement()
 	var elseBranch ast.Stmt
 	if p.match(lexer.ELSE) {
 		elseBranch, err = p.statement()
 	}
-	return &ast.If{Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, err
+	return &ast.If{Keyword: keyword, Condition: condition, ThenBranch: thenBranch, ElseBranch: elseBranch}, err
 }
 
 func (p *Parser) printStatement() (ast.Stmt, error) {
+	keyword := p.previous()
 	value, err := p.expression()
-	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after value")
-	return &ast.Print{Expression: value}, err
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after value.")
+	return &ast.Print{Keyword: keyword, Expression: value}, err
+}
+
+func (p *Parser) assertStatement() (ast.Stmt, error) {
+	keyword := p.previous()
+	start := p.current
+	condition, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	source := sourceText(p.tokens[start:p.current])
+	var message ast.Expr
+	if p.match(lexer.COMMA) {
+		message, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after assertion.")
+	return &ast.Assert{Keyword: keyword, Condition: condition, Message: message, Source: source}, err
+}
+
+func (p *Parser) testDeclaration() (ast.Stmt, error) {
+	keyword := p.advance()
+	name := p.advance()
+	_, err := p.Consume(lexer.LEFT_BRACE, "Expect '{' before test body.")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.block()
+	return &ast.Test{Keyword: keyword, Name: name.Literal.(string), Body: body}, err
 }
 
 func (p *Parser) returnStatement() (ast.Stmt, error) {
@@ -170,52 +268,266 @@ func (p *Parser) returnStatement() (ast.Stmt, error) {
 			return nil, err
 		}
 	}
-	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after return value")
+	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after return value.")
 	if err != nil {
 		return nil, err
 	}
 	return &ast.Return{KeyWord: keyword, Value: value}, nil
 }
 
+func (p *Parser) yieldStatement() (ast.Stmt, error) {
+	keyword := p.previous()
+	p.sawYield = true
+	var value ast.Expr
+	var err error
+	if !p.check(lexer.SEMICOLON) {
+		value, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after yield value")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.Yield{Keyword: keyword, Value: value}, nil
+}
+
 func (p *Parser) varDeclaration() (ast.Stmt, error) {
-	name, err := p.Consume(lexer.IDENTIFIER, "Expect variable name")
+	constant := p.previous().Type0 != lexer.VAR
+	name, err := p.Consume(lexer.IDENTIFIER, "Expect variable name.")
 	if err != nil {
 		return nil, err
 	}
+	var annotation *ast.TypeAnnotation
+	if p.match(lexer.COLON) {
+		annotation, err = p.typeAnnotation()
+		if err != nil {
+			return nil, err
+		}
+	}
 	var initializer ast.Expr
 	if p.match(lexer.EQUAL) {
 		initializer, err = p.expression()
+	} else if constant {
+		return nil, p.raiseError(name, catalog.ConstantInitializer, "Expect initializer for constant")
 	}
-	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after variable declaration")
-	return &ast.Var{Name: name, Initializer: initializer}, nil
+	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after variable declaration.")
+	return &ast.Var{Name: name, Initializer: initializer, Constant: constant, Type: annotation}, nil
 }
 
 func (p *Parser) whileStatement() (ast.Stmt, error) {
-	_, err := p.Consume(lexer.LEFT_PAREN, "Expect '(' after 'while')")
+	keyword := p.previous()
+	_, err := p.Consume(lexer.LEFT_PAREN, "Expect '(' after 'while'.")
 	condition, err := p.expression()
-	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after condition")
+	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after condition.")
 	body, err := p.statement()
-	return &ast.While{Condition: condition, Body: body}, err
+	return &ast.While{Keyword: keyword, Condition: condition, Body: body}, err
 }
 
 func (p *Parser) breakStatement() (ast.Stmt, error) {
+	keyword := p.previous()
 	_, err := p.Consume(lexer.SEMICOLON, "Expect ';' after statement")
-	return &ast.Break{}, err
+	return &ast.Break{Keyword: keyword}, err
 }
 
 func (p *Parser) continueStatement() (ast.Stmt, error) {
+	keyword := p.previous()
 	_, err := p.Consume(lexer.SEMICOLON, "Expect ';' after statement")
-	return &ast.Continue{}, err
+	return &ast.Continue{Keyword: keyword}, err
+}
+
+func (p *Parser) matchStatement() (ast.Stmt, error) {
+	keyword := p.previous()
+	_, err := p.Consume(lexer.LEFT_PAREN, "Expect '(' after 'match'")
+	if err != nil {
+		return nil, err
+	}
+	subject, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after match subject")
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.Consume(lexer.LEFT_BRACE, "Expect '{' before match cases")
+	if err != nil {
+		return nil, err
+	}
+	cases := make([]ast.MatchCase, 0)
+	for !p.check(lexer.RIGHT_BRACE) && !p.isAtEnd() {
+		matchCase, err := p.matchCase()
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, matchCase)
+	}
+	_, err = p.Consume(lexer.RIGHT_BRACE, "Expect '}' after match cases")
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, p.raiseError(keyword, catalog.MatchSyntax, "Expect at least one case in match")
+	}
+	return &ast.Match{Keyword: keyword, Subject: subject, Cases: cases}, nil
+}
+
+func (p *Parser) matchCase() (ast.MatchCase, error) {
+	_, err := p.Consume(lexer.CASE, "Expect 'case'")
+	if err != nil {
+		return ast.MatchCase{}, err
+	}
+	patterns := make([]ast.Pattern, 0)
+	for {
+		pattern, err := p.pattern()
+		if err != nil {
+			return ast.MatchCase{}, err
+		}
+		patterns = append(patterns, pattern)
+		if !p.match(lexer.COMMA) {
+			break
+		}
+	}
+	var guard ast.Expr
+	if p.match(lexer.IF) {
+		guard, err = p.expression()
+		if err != nil {
+			return ast.MatchCase{}, err
+		}
+	}
+	_, err = p.Consume(lexer.ARROW, "Expect '=>' after case pattern")
+	if err != nil {
+		return ast.MatchCase{}, err
+	}
+	body, err := p.statement()
+	if err != nil {
+		return ast.MatchCase{}, err
+	}
+	return ast.MatchCase{Patterns: patterns, Guard: guard, Body: body}, nil
+}
+
+func (p *Parser) pattern() (ast.Pattern, error) {
+	if p.match(lexer.NUMBER, lexer.STRING) {
+		return &ast.LiteralPattern{Token: p.previous(), Value: p.previous().Literal}, nil
+	}
+	if p.match(lexer.MINUS) {
+		number, err := p.Consume(lexer.NUMBER, "Expect number after '-' in pattern")
+		if err != nil {
+			return nil, err
+		}
+		return &ast.LiteralPattern{Token: number, Value: -number.Literal.(float64)}, nil
+	}
+	if p.match(lexer.TRUE) {
+		return &ast.LiteralPattern{Token: p.previous(), Value: true}, nil
+	}
+	if p.match(lexer.FALSE) {
+		return &ast.LiteralPattern{Token: p.previous(), Value: false}, nil
+	}
+	if p.match(lexer.NIL) {
+		return &ast.LiteralPattern{Token: p.previous(), Value: nil}, nil
+	}
+	if p.match(lexer.IDENTIFIER) {
+		if p.previous().Lexeme == "_" {
+			return &ast.WildcardPattern{Token: p.previous()}, nil
+		}
+		return &ast.BindingPattern{Name: p.previous()}, nil
+	}
+	if p.match(lexer.LEFT_BRACKET) {
+		return p.listPattern()
+	}
+	if p.match(lexer.LEFT_BRACE) {
+		return p.mapPattern()
+	}
+	return nil, p.raiseError(p.peek(), catalog.MatchSyntax, "Expect pattern")
+}
+
+func (p *Parser) listPattern() (ast.Pattern, error) {
+	bracket := p.previous()
+	elements := make([]ast.Pattern, 0)
+	var rest *lexer.Token
+	if !p.check(lexer.RIGHT_BRACKET) {
+		for {
+			if p.match(lexer.ELLIPSIS) {
+				name, err := p.Consume(lexer.IDENTIFIER, "Expect name after '...' in list pattern")
+				if err != nil {
+					return nil, err
+				}
+				rest = &name
+				break
+			}
+			element, err := p.pattern()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+			if !p.match(lexer.COMMA) {
+				break
+			}
+		}
+	}
+	_, err := p.Consume(lexer.RIGHT_BRACKET, "Expect ']' after list pattern")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.ListPattern{Bracket: bracket, Elements: elements, Rest: rest}, nil
+}
+
+func (p *Parser) mapPattern() (ast.Pattern, error) {
+	brace := p.previous()
+	keys := make([]ast.Pattern, 0)
+	values := make([]ast.Pattern, 0)
+	if !p.check(lexer.RIGHT_BRACE) {
+		for {
+			start := p.peek()
+			key, err := p.pattern()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := key.(*ast.LiteralPattern); !ok {
+				return nil, p.raiseError(start, catalog.MatchSyntax, "Expect literal key in map pattern")
+			}
+			if !p.match(lexer.COLON) {
+				return nil, p.raiseError(p.peek(), catalog.MatchSyntax, "Expect ':' after map pattern key")
+			}
+			value, err := p.pattern()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+			values = append(values, value)
+			if !p.match(lexer.COMMA) {
+				break
+			}
+		}
+	}
+	_, err := p.Consume(lexer.RIGHT_BRACE, "Expect '}' after map pattern")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.MapPattern{Brace: brace, Keys: keys, Values: values}, nil
 }
 
 func (p *Parser) expressionStatement() (ast.Stmt, error) {
 	expr, err := p.expression()
-	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after expression")
+	if err != nil {
+		return nil, err
+	}
+	if variable, ok := expr.(*ast.Variable); ok && !p.check(lexer.SEMICOLON) {
+		// a misspelled keyword starting a statement, like `pritn x;`, reads as a variable followed by garbage
+		if keyword := suggest.Closest(variable.Name.Lexeme, suggest.Keywords()); keyword != "" {
+			fix := &utils.Fix{Line: variable.Name.Line, Column: variable.Name.Column, Old: variable.Name.Lexeme, New: keyword}
+			return nil, p.raiseErrorFix(p.peek(), catalog.MissingSemicolon, "Expect ';' after expression.", fix)
+		}
+	}
+	_, err = p.Consume(lexer.SEMICOLON, "Expect ';' after expression.")
 	return &ast.Expression{Expression: expr}, err
 }
 
 func (p *Parser) function(kind string) (ast.Stmt, error) {
-	funcName, err := p.Consume(lexer.IDENTIFIER, "Expect "+kind+" name")
+	start := p.current - 1
+	generator := p.match(lexer.STAR)
+	funcName, err := p.Consume(lexer.IDENTIFIER, "Expect "+kind+" name.")
 	if err != nil {
 		return nil, err
 	}
@@ -223,36 +535,52 @@ func (p *Parser) function(kind string) (ast.Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-
-	return &ast.Function{Name: funcName, Params: body.(*ast.FunctionExpr).Params, Body: body.(*ast.FunctionExpr).Body}, nil
+	function := body.(*ast.FunctionExpr)
+	return &ast.Function{Name: funcName, Params: function.Params, Body: function.Body, Generator: generator || function.Generator,
+		ReturnType: function.ReturnType, Source: p.sourceSince(start)}, nil
 }
 
+// functionBody parses the parameters and body of a function. The function is a generator when it is declared
+// with `fun*` (handled by the callers) or when its own body, not counting nested functions, contains a yield.
 func (p *Parser) functionBody(kind string) (ast.Expr, error) {
 	var err error
-	_, err = p.Consume(lexer.LEFT_PAREN, "Expect '(' before parameter(s)")
-	parameters := make([]lexer.Token, 0)
+	enclosingSawYield := p.sawYield
+	p.sawYield = false
+	defer func() {
+		p.sawYield = enclosingSawYield
+	}()
+	_, err = p.Consume(lexer.LEFT_PAREN, "Expect '(' after "+kind+" name.")
+	if err != nil {
+		return nil, err
+	}
+	parameters := make([]ast.Param, 0)
 	if !p.check(lexer.RIGHT_PAREN) {
-		paramNameTmp, err := p.Consume(lexer.IDENTIFIER, "Expect parameter name")
-		if err != nil {
-			return nil, err
-		}
-		parameters = append(parameters, paramNameTmp)
-		for p.match(lexer.COMMA) {
-			paramName, err := p.Consume(lexer.IDENTIFIER, "Expect parameter name")
+		for {
+			if len(parameters) >= 255 {
+				_ = p.raiseError(p.peek(), catalog.TooManyArguments, "Can't have more than 255 parameters.")
+			}
+			param, err := p.parameter(parameters)
 			if err != nil {
 				return nil, err
 			}
-			if len(parameters) >= 255 {
-				return nil, p.raiseError(paramName, "Can't have more than 255 parameters")
+			parameters = append(parameters, param)
+			if !p.match(lexer.COMMA) {
+				break
 			}
-			parameters = append(parameters, paramName)
 		}
 	}
-	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after parameter(s)")
+	_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after parameters.")
 	if err != nil {
 		return nil, err
 	}
-	_, err = p.Consume(lexer.LEFT_BRACE, "Expect '{' before "+kind+" body")
+	var returnType *ast.TypeAnnotation
+	if p.match(lexer.COLON) {
+		returnType, err = p.typeAnnotation()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.Consume(lexer.LEFT_BRACE, "Expect '{' before "+kind+" body.")
 	if err != nil {
 		return nil, err
 	}
@@ -260,17 +588,72 @@ func (p *Parser) functionBody(kind string) (ast.Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ast.FunctionExpr{Body: body, Params: parameters}, nil
+	return &ast.FunctionExpr{Body: body, Params: parameters, Generator: p.sawYield, ReturnType: returnType}, nil
+}
+
+// parameter parses `name`, `name = default` or `...name`, each optionally annotated with `: type` after the name. previous holds the parameters already parsed so the
+// ordering rules can be checked: required parameters come first, and a rest parameter must be the last one.
+func (p *Parser) parameter(previous []ast.Param) (ast.Param, error) {
+	if len(previous) > 0 && previous[len(previous)-1].Rest {
+		return ast.Param{}, p.raiseError(p.peek(), catalog.ParameterOrder, "Rest parameter must be the last parameter")
+	}
+	rest := p.match(lexer.ELLIPSIS)
+	name, err := p.Consume(lexer.IDENTIFIER, "Expect parameter name.")
+	if err != nil {
+		return ast.Param{}, err
+	}
+	var annotation *ast.TypeAnnotation
+	if p.match(lexer.COLON) {
+		annotation, err = p.typeAnnotation()
+		if err != nil {
+			return ast.Param{}, err
+		}
+	}
+	var defaultValue ast.Expr
+	if p.match(lexer.EQUAL) {
+		if rest {
+			return ast.Param{}, p.raiseError(p.previous(), catalog.ParameterOrder, "Rest parameter can't have a default value")
+		}
+		defaultValue, err = p.expression()
+		if err != nil {
+			return ast.Param{}, err
+		}
+	} else if !rest && len(previous) > 0 && previous[len(previous)-1].Default != nil {
+		return ast.Param{}, p.raiseError(name, catalog.ParameterOrder, "Parameter without default value can't follow one with a default value")
+	}
+	return ast.Param{Name: name, Default: defaultValue, Rest: rest, Type: annotation}, nil
+}
+
+// typeAnnotation parses the type following a ':', a union of type names such as `number | string`. A name followed
+// by '?' is nullable, which adds nil to the union.
+func (p *Parser) typeAnnotation() (*ast.TypeAnnotation, error) {
+	annotation := &ast.TypeAnnotation{Names: make([]lexer.Token, 0, 1)}
+	for {
+		if p.match(lexer.NIL) {
+			annotation.Names = append(annotation.Names, p.previous())
+		} else {
+			name, err := p.Consume(lexer.IDENTIFIER, "Expect type name.")
+			if err != nil {
+				return nil, err
+			}
+			annotation.Names = append(annotation.Names, name)
+		}
+		if p.match(lexer.QUESTION) {
+			annotation.Names = append(annotation.Names, lexer.Token{Type0: lexer.NIL, Lexeme: "nil", Line: p.previous().Line})
+		}
+		if !p.match(lexer.PIPE) {
+			return annotation, nil
+		}
+	}
 }
 
 func (p *Parser) block() ([]ast.Stmt, error) {
 	statements := make([]ast.Stmt, 0)
 	for !p.check(lexer.RIGHT_BRACE) && !p.isAtEnd() {
-		statement, err := p.declaration()
-		if err != nil {
-			return statements, err
+		statement, _ := p.declaration()
+		if statement != nil {
+			statements = append(statements, statement)
 		}
-		statements = append(statements, statement)
 	}
 	_, err := p.Consume(lexer.RIGHT_BRACE, "Expect '}' after block.")
 	return statements, err
@@ -282,56 +665,54 @@ func (p *Parser) expression() (ast.Expr, error) {
 
 func (p *Parser) assignment() (ast.Expr, error) {
 	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
 
-	if p.match(lexer.EQUAL) {
-		equals := p.previous()
+	if p.match(lexer.EQUAL, lexer.PLUS_EQUAL, lexer.MINUS_EQUAL, lexer.STAR_EQUAL, lexer.SLASH_EQUAL, lexer.PERCENT_EQUAL) {
+		operator := p.previous()
 		value, err := p.assignment()
-		if v, ok := expr.(*ast.Variable); ok {
-			name := v.Name
-			return &ast.Assign{Name: name, Value: value}, err
+		if err != nil {
+			return nil, err
 		}
-		utils.Report(equals.Line, " Parser ", "Invalid assignment target.")
-	}
-	if p.match(lexer.INCREMENT) {
 		if v, ok := expr.(*ast.Variable); ok {
-			name := v.Name
-			return &ast.Assign{Name: name, Value: &ast.Binary{Left: &ast.Variable{Name: name}, Operator: lexer.Token{Type0: lexer.PLUS}, Right: &ast.Literal{Type: lexer.NUMBER, Value: 1.0}}}, err
+			return &ast.Assign{Name: v.Name, Operator: operator, Value: value}, nil
 		}
-		utils.Report(p.previous().Line, " Parser ", "Invalid assignment target.")
+		return nil, p.raiseError(operator, catalog.InvalidTarget, "Invalid assignment target.")
 	}
-	if p.match(lexer.DECREMENT) {
-		if v, ok := expr.(*ast.Variable); ok {
-			name := v.Name
-			return &ast.Assign{Name: name, Value: &ast.Binary{Left: &ast.Variable{Name: name}, Operator: lexer.Token{Type0: lexer.MINUS}, Right: &ast.Literal{Type: lexer.NUMBER, Value: 1.0}}}, err
-		}
-		utils.Report(p.previous().Line, " Parser ", "Invalid assignment target.")
-	}
-	return expr, err
+	return expr, nil
 }
 
 func (p *Parser) or() (ast.Expr, error) {
-	var err error
-	var right ast.Expr
 	expr, err := p.and()
-
+	if err != nil {
+		return nil, err
+	}
 	for p.match(lexer.OR) {
 		operator := p.previous()
-		right, err = p.and()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
 		expr = &ast.Logical{Left: expr, Operator: operator, Right: right}
 	}
-	return expr, err
+	return expr, nil
 }
 
 func (p *Parser) and() (ast.Expr, error) {
-	var err error
-	var right ast.Expr
 	expr, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
 	for p.match(lexer.AND) {
 		operator := p.previous()
-		right, err = p.conditional()
+		right, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
 		expr = &ast.Logical{Left: expr, Operator: operator, Right: right}
 	}
-	return expr, err
+	return expr, nil
 }
 
 func (p *Parser) conditional() (ast.Expr, error) {
@@ -339,77 +720,139 @@ func (p *Parser) conditional() (ast.Expr, error) {
 	var err error
 	expr, err = p.equality()
 
+	if err != nil {
+		return nil, err
+	}
 	if p.match(lexer.QUESTION) {
 		thenBranch, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
 		_, err = p.Consume(lexer.COLON, "Expect ':' after then branch of conditional expression.")
+		if err != nil {
+			return nil, err
+		}
 		elseBranch, err = p.conditional()
+		if err != nil {
+			return nil, err
+		}
 		expr = &ast.Ternary{ConditionalExpr: expr, ThenExpr: thenBranch, ElseExpr: elseBranch}
 	}
-	return expr, err
+	return expr, nil
 }
 
 func (p *Parser) equality() (ast.Expr, error) {
-	var err error
-	var right, expr ast.Expr
-
-	expr, err = p.comparison()
-
+	expr, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
 	for p.match(lexer.BANG_EQUAL, lexer.EQUAL_EQUAL) {
 		operator := p.previous()
-		right, err = p.comparison()
+		right, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
 		expr = &ast.Binary{Left: expr, Operator: operator, Right: right}
 	}
-	return expr, err
+	return expr, nil
 }
 
 func (p *Parser) comparison() (ast.Expr, error) {
-	var err error
-	var right, expr ast.Expr
-
-	expr, err = p.term()
+	expr, err := p.term()
+	if err != nil {
+		return nil, err
+	}
 	for p.match(lexer.GREATER, lexer.GREATER_EQUAL, lexer.LESS, lexer.LESS_EQUAL) {
 		operator := p.previous()
-		right, err = p.term()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
 		expr = &ast.Binary{Left: expr, Operator: operator, Right: right}
 	}
-	return expr, err
+	return expr, nil
 }
 
 func (p *Parser) term() (ast.Expr, error) {
-	var err error
-	var right, expr ast.Expr
-
-	expr, err = p.factor()
-
+	expr, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
 	for p.match(lexer.MINUS, lexer.PLUS) {
 		operator := p.previous()
-		right, err = p.factor()
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
 		expr = &ast.Binary{Left: expr, Operator: operator, Right: right}
 	}
-	return expr, err
+	return expr, nil
 }
 
 func (p *Parser) factor() (ast.Expr, error) {
-	var err error
-	var right, expr ast.Expr
-
-	expr, err = p.unary()
-
-	for p.match(lexer.SLASH, lexer.STAR) {
+	expr, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.match(lexer.SLASH, lexer.STAR, lexer.PERCENT) {
 		operator := p.previous()
-		right, err = p.unary()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
 		expr = &ast.Binary{Left: expr, Operator: operator, Right: right}
 	}
-	return expr, err
+	return expr, nil
 }
 
 func (p *Parser) unary() (ast.Expr, error) {
 	if p.match(lexer.BANG, lexer.MINUS) {
 		operator := p.previous()
 		right, err := p.unary()
-		return &ast.Unary{Operator: operator, Right: right}, err
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Unary{Operator: operator, Right: right}, nil
+	}
+	if p.match(lexer.SPAWN) {
+		keyword := p.previous()
+		expr, err := p.call()
+		if err != nil {
+			return nil, err
+		}
+		call, ok := expr.(*ast.Call)
+		if !ok {
+			return nil, p.raiseError(keyword, catalog.SpawnWithoutCall, "Expect function call after 'spawn'")
+		}
+		return &ast.Spawn{Keyword: keyword, Call: call}, nil
+	}
+	if p.match(lexer.INCREMENT, lexer.DECREMENT) {
+		operator := p.previous()
+		target, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return p.update(operator, target, true)
 	}
-	return p.call()
+	return p.postfix()
+}
+
+func (p *Parser) postfix() (ast.Expr, error) {
+	expr, err := p.call()
+	if err != nil {
+		return nil, err
+	}
+	if p.match(lexer.INCREMENT, lexer.DECREMENT) {
+		return p.update(p.previous(), expr, false)
+	}
+	return expr, nil
+}
+
+func (p *Parser) update(operator lexer.Token, target ast.Expr, prefix bool) (ast.Expr, error) {
+	if _, ok := target.(*ast.Variable); !ok {
+		return nil, p.raiseError(operator, catalog.InvalidTarget, "Invalid increment or decrement target.")
+	}
+	return &ast.Update{Operator: operator, Target: target, Prefix: prefix}, nil
 }
 
 func (p *Parser) call() (ast.Expr, error) {
@@ -434,31 +877,59 @@ func (p *Parser) call() (ast.Expr, error) {
 
 func (p *Parser) finishCall(callee ast.Expr) (ast.Expr, error) {
 	arguments := make([]ast.Expr, 0)
+	named := make(map[string]bool, 0)
 	if !p.check(lexer.RIGHT_PAREN) {
-		expr, err := p.expression()
-		if err != nil {
-			return nil, err
-		}
-		arguments = append(arguments, expr)
-
-		for p.match(lexer.COMMA) {
-			expr, err := p.expression()
+		for {
+			if len(arguments) >= 255 {
+				_ = p.raiseError(p.peek(), catalog.TooManyArguments, "Can't have more than 255 arguments.")
+			}
+			expr, err := p.argument()
 			if err != nil {
 				return nil, err
 			}
-			if len(arguments) >= 255 {
-				return nil, p.raiseError(p.peek(), "Can't have more than 255 arguments")
+			if v, ok := expr.(*ast.NamedArg); ok {
+				if named[v.Name.Lexeme] {
+					return nil, p.raiseError(v.Name, catalog.ArgumentOrder, "Duplicate named argument")
+				}
+				named[v.Name.Lexeme] = true
+			} else if len(named) > 0 {
+				return nil, p.raiseError(p.previous(), catalog.ArgumentOrder, "Positional argument can't follow named arguments")
 			}
 			arguments = append(arguments, expr)
+			if !p.match(lexer.COMMA) {
+				break
+			}
 		}
 	}
-	paren, err := p.Consume(lexer.RIGHT_PAREN, "Expect ')' after arguments")
+	paren, err := p.Consume(lexer.RIGHT_PAREN, "Expect ')' after arguments.")
 	if err != nil {
 		return nil, err
 	}
 	return &ast.Call{Callee: callee, Paren: paren, Arguments: arguments}, nil
 }
 
+// argument parses a single call argument: a plain expression, a `...list` spread or a `name: value` pair.
+func (p *Parser) argument() (ast.Expr, error) {
+	if p.match(lexer.ELLIPSIS) {
+		operator := p.previous()
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.Spread{Operator: operator, Expression: expr}, nil
+	}
+	if p.check(lexer.IDENTIFIER) && p.checkNext(lexer.COLON) {
+		name := p.advance()
+		p.advance()
+		value, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		return &ast.NamedArg{Name: name, Value: value}, nil
+	}
+	return p.expression()
+}
+
 func (p *Parser) primary() (ast.Expr, error) {
 	if p.match(lexer.FALSE) {
 		return &ast.Literal{Type: lexer.FALSE, Value: false}, nil
@@ -477,28 +948,87 @@ func (p *Parser) primary() (ast.Expr, error) {
 	}
 	if p.match(lexer.LEFT_PAREN) {
 		expr, err := p.expression()
-		_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after expression")
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.Consume(lexer.RIGHT_PAREN, "Expect ')' after expression.")
 		return &ast.Grouping{Expression: expr}, err
 	}
-	if p.match(lexer.BANG_EQUAL, lexer.EQUAL_EQUAL, lexer.GREATER_EQUAL, lexer.GREATER, lexer.LESS, lexer.LESS_EQUAL, lexer.PLUS, lexer.SLASH, lexer.STAR) {
-		return nil, p.raiseError(p.previous(), "Missing Left Hand Operand")
+	if p.match(lexer.LEFT_BRACE) {
+		return p.mapLiteral()
+	}
+	if p.match(lexer.BANG_EQUAL, lexer.EQUAL_EQUAL, lexer.GREATER_EQUAL, lexer.GREATER, lexer.LESS, lexer.LESS_EQUAL, lexer.PLUS, lexer.SLASH, lexer.STAR, lexer.PERCENT) {
+		return nil, p.raiseError(p.previous(), catalog.ExpectExpression, "Missing Left Hand Operand")
 	}
-	if p.match(lexer.FUN) {
+	// a named function is a declaration, which can't appear where an expression is expected
+	if p.check(lexer.FUN) && !p.checkNext(lexer.IDENTIFIER) {
+		start := p.current
+		p.advance()
+		generator := p.match(lexer.STAR)
 		body, err := p.functionBody("function")
 		if err != nil {
 			return nil, err
 		}
-		return body.(*ast.FunctionExpr), nil
+		function := body.(*ast.FunctionExpr)
+		function.Generator = function.Generator || generator
+		function.Source = p.sourceSince(start)
+		return function, nil
 	}
 
-	return nil, p.raiseError(p.peek(), "Expect expression.")
+	return nil, p.raiseError(p.peek(), catalog.ExpectExpression, "Expect expression.")
+}
+
+// mapLiteral parses the entries of a `{key: value, ...}` map after its opening brace.
+func (p *Parser) mapLiteral() (ast.Expr, error) {
+	expr := &ast.Map{Brace: p.previous(), Keys: make([]ast.Expr, 0), Values: make([]ast.Expr, 0)}
+	if !p.check(lexer.RIGHT_BRACE) {
+		for {
+			key, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			if !p.match(lexer.COLON) {
+				return nil, p.raiseError(p.peek(), catalog.ExpectExpression, "Expect ':' after map key.")
+			}
+			value, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			expr.Keys = append(expr.Keys, key)
+			expr.Values = append(expr.Values, value)
+			if !p.match(lexer.COMMA) {
+				break
+			}
+		}
+	}
+	_, err := p.Consume(lexer.RIGHT_BRACE, "Expect '}' after map entries.")
+	return expr, err
 }
 
 func (p *Parser) Consume(type0 lexer.TokenType, message string) (lexer.Token, error) {
 	if p.check(type0) {
 		return p.advance(), nil
 	}
-	return lexer.Token{}, p.raiseError(p.peek(), message)
+	return lexer.Token{}, p.raiseError(p.peek(), consumeCode(type0), message)
+}
+
+// consumeCode is the code of the error of a missing token of type type0.
+func consumeCode(type0 lexer.TokenType) string {
+	switch type0 {
+	case lexer.SEMICOLON:
+		return catalog.MissingSemicolon
+	case lexer.RIGHT_PAREN, lexer.RIGHT_BRACE, lexer.RIGHT_BRACKET:
+		return catalog.MissingClosing
+	case lexer.LEFT_PAREN, lexer.LEFT_BRACE:
+		return catalog.MissingOpening
+	case lexer.IDENTIFIER:
+		return catalog.ExpectName
+	case lexer.COLON:
+		return catalog.TernaryColon
+	case lexer.CASE, lexer.ARROW, lexer.NUMBER:
+		return catalog.MatchSyntax
+	}
+	return catalog.ExpectExpression
 }
 
 func (p *Parser) synchronize() {
@@ -509,27 +1039,26 @@ func (p *Parser) synchronize() {
 			return
 		}
 		switch p.peek().Type0 {
-		case lexer.CLASS:
-		case lexer.FUN:
-		case lexer.VAR:
-		case lexer.FOR:
-		case lexer.IF:
-		case lexer.WHILE:
-		case lexer.PRINT:
-		case lexer.RETURN:
+		case lexer.CLASS, lexer.FUN, lexer.VAR, lexer.CONST, lexer.LET, lexer.FOR, lexer.IF, lexer.WHILE,
+			lexer.PRINT, lexer.RETURN, lexer.MATCH, lexer.YIELD, lexer.ASSERT:
 			return
-
 		}
 		p.advance()
 	}
 }
 
-func (p *Parser) raiseError(token lexer.Token, message string) error {
+func (p *Parser) raiseError(token lexer.Token, code string, message string) error {
+	return p.raiseErrorFix(token, code, message, nil)
+}
+
+// raiseErrorFix is raiseError for an error which fix corrects.
+func (p *Parser) raiseErrorFix(token lexer.Token, code string, message string, fix *utils.Fix) error {
+	p.hadError = true
+	where := "at '" + token.Lexeme + "'"
 	if token.Type0 == lexer.EOF {
-		utils.Report(token.Line, " at end", message)
-	} else {
-		utils.Report(token.Line, " at '"+token.Lexeme+"'", message)
+		where = "at end"
 	}
+	p.reporter.Diagnose(utils.Diagnostic{Line: token.Line, Where: where, Code: code, Message: message, Fix: fix})
 	return errors.New("parse error")
 }
 
@@ -569,12 +1098,54 @@ func (p *Parser) previous() lexer.Token {
 	return p.tokens[p.current-1]
 }
 
+func (p *Parser) checkAt(distance int, tokenType lexer.TokenType) bool {
+	if p.current+distance >= len(p.tokens) {
+		return false
+	}
+	return p.tokens[p.current+distance].Type0 == tokenType
+}
+
 func (p *Parser) checkNext(tokenType lexer.TokenType) bool {
 	if p.isAtEnd() {
 		return false
 	}
-	if p.tokens[p.current+1].Type0 == lexer.EOF {
+	return p.checkAt(1, tokenType)
+}
+
+// sourceText rebuilds the source of an expression from its tokens, with the spacing normalised.
+// sourceSince returns the source of the tokens from index start to the last one consumed, "" when the source isn't
+// known.
+func (p *Parser) sourceSince(start int) string {
+	if p.source == "" {
+		return ""
+	}
+	first, last := p.tokens[start], p.tokens[p.current-1]
+	return p.source[first.Offset : last.Offset+len(last.Lexeme)]
+}
+
+func sourceText(tokens []lexer.Token) string {
+	var builder strings.Builder
+	for index, token := range tokens {
+		if index > 0 && spaceBetween(tokens[index-1], token) {
+			builder.WriteString(" ")
+		}
+		builder.WriteString(token.Lexeme)
+	}
+	return builder.String()
+}
+
+func spaceBetween(previous lexer.Token, next lexer.Token) bool {
+	switch previous.Type0 {
+	case lexer.LEFT_PAREN, lexer.LEFT_BRACKET, lexer.BANG, lexer.ELLIPSIS, lexer.INCREMENT, lexer.DECREMENT:
+		return false
+	}
+	switch next.Type0 {
+	case lexer.RIGHT_PAREN, lexer.RIGHT_BRACKET, lexer.COMMA, lexer.DOT:
 		return false
+	case lexer.LEFT_PAREN:
+		return previous.Type0 != lexer.IDENTIFIER && previous.Type0 != lexer.RIGHT_PAREN
+	case lexer.INCREMENT, lexer.DECREMENT:
+		return previous.Type0 != lexer.IDENTIFIER
 	}
-	return p.tokens[p.current+1].Type0 == tokenType
+	return previous.Type0 != lexer.DOT
 }
@@ -0,0 +1,41 @@
+package profiler
+
+import (
+	"golox/lox/ast"
+	"golox/lox/interpreter"
+)
+
+// Hooks returns the interpreter hooks profiling a program, see Interpreter.AddHooks.
+func (p *Profiler) Hooks() interpreter.Hooks {
+	return p.newStack("(script)")
+}
+
+func (s *stack) OnStatement(_ *interpreter.Interpreter, stmt ast.Stmt) {
+	s.statement(ast.Line(stmt))
+}
+
+func (s *stack) OnStatementEnd(*interpreter.Interpreter, ast.Stmt) {
+	s.statementEnd()
+}
+
+func (s *stack) OnCall(_ *interpreter.Interpreter, function *interpreter.LoxFunction, _ []interface{}, _ map[string]interface{}) {
+	s.enter(function.Label(), ast.Line(function.Declaration))
+}
+
+func (s *stack) OnReturn(*interpreter.Interpreter, *interpreter.LoxFunction, interface{}, error) {
+	s.exit()
+}
+
+// OnSuspend pauses the stack: a generator body runs on a stack of its own, and the time a generator is suspended
+// isn't spent by anyone.
+func (s *stack) OnSuspend(*interpreter.Interpreter) {
+	s.pause()
+}
+
+// OnFork charges the body of a generator to the generator function and the other tasks to a (task) root.
+func (s *stack) OnFork(_ *interpreter.Interpreter, _ *interpreter.Interpreter, fork interpreter.Fork) interpreter.Hooks {
+	if fork.Kind == interpreter.GeneratorFork {
+		return s.profiler.newStack(fork.Function.Label())
+	}
+	return s.profiler.newStack("(task)")
+}
@@ -0,0 +1,196 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"time"
+)
+
+// The field numbers of profile.proto, see https://github.com/google/pprof/blob/main/proto/profile.proto.
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// encoder writes protocol buffers, the few wire types pprof needs of them.
+type encoder struct {
+	buffer []byte
+}
+
+func (e *encoder) varint(value uint64) {
+	for value >= 0x80 {
+		e.buffer = append(e.buffer, byte(value)|0x80)
+		value >>= 7
+	}
+	e.buffer = append(e.buffer, byte(value))
+}
+
+func (e *encoder) tag(field int, wireType int) {
+	e.varint(uint64(field<<3 | wireType))
+}
+
+// integer writes a varint field, omitting it when it is 0 like proto3 does.
+func (e *encoder) integer(field int, value int64) {
+	if value == 0 {
+		return
+	}
+	e.tag(field, 0)
+	e.varint(uint64(value))
+}
+
+func (e *encoder) bytes(field int, value []byte) {
+	e.tag(field, 2)
+	e.varint(uint64(len(value)))
+	e.buffer = append(e.buffer, value...)
+}
+
+// message writes the message the function encodes as the field.
+func (e *encoder) message(field int, encode func(e *encoder)) {
+	var nested encoder
+	encode(&nested)
+	e.bytes(field, nested.buffer)
+}
+
+// packed writes a repeated varint field.
+func (e *encoder) packed(field int, values []int64) {
+	var nested encoder
+	for _, value := range values {
+		nested.varint(uint64(value))
+	}
+	e.bytes(field, nested.buffer)
+}
+
+// stringTable interns the strings of a profile, the empty string being 0 as pprof requires.
+type stringTable struct {
+	indexes map[string]int64
+	values  []string
+}
+
+func (s *stringTable) index(value string) int64 {
+	if index, ok := s.indexes[value]; ok {
+		return index
+	}
+	index := int64(len(s.values))
+	s.indexes[value] = index
+	s.values = append(s.values, value)
+	return index
+}
+
+// WriteProfile writes the gzipped pprof profile of everything profiled so far. Every sample has two values: the
+// number of times time was charged to its stack and the time charged in nanoseconds.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	table := &stringTable{indexes: map[string]int64{"": 0}, values: []string{""}}
+	var profile encoder
+	profile.message(profileSampleType, func(e *encoder) {
+		e.integer(valueTypeType, table.index("samples"))
+		e.integer(valueTypeUnit, table.index("count"))
+	})
+	profile.message(profileSampleType, func(e *encoder) {
+		e.integer(valueTypeType, table.index("time"))
+		e.integer(valueTypeUnit, table.index("nanoseconds"))
+	})
+
+	functions := make(map[string]int64)
+	locations := make(map[Location]int64)
+	var functionOrder []string
+	var locationOrder []Location
+	for _, sample := range p.sortedSamples() {
+		ids := make([]int64, len(sample.stack))
+		for index, location := range sample.stack {
+			if _, ok := functions[location.Function]; !ok {
+				functions[location.Function] = int64(len(functions) + 1)
+				functionOrder = append(functionOrder, location.Function)
+			}
+			id, ok := locations[location]
+			if !ok {
+				id = int64(len(locations) + 1)
+				locations[location] = id
+				locationOrder = append(locationOrder, location)
+			}
+			ids[index] = id
+		}
+		profile.message(profileSample, func(e *encoder) {
+			e.packed(sampleLocationID, ids)
+			e.packed(sampleValue, []int64{sample.count, int64(sample.time)})
+		})
+	}
+	for _, location := range locationOrder {
+		location := location
+		profile.message(profileLocation, func(e *encoder) {
+			e.integer(locationID, locations[location])
+			e.message(locationLine, func(e *encoder) {
+				e.integer(lineFunctionID, functions[location.Function])
+				e.integer(lineLine, int64(location.Line))
+			})
+		})
+	}
+	for _, function := range functionOrder {
+		function := function
+		profile.message(profileFunction, func(e *encoder) {
+			e.integer(functionID, functions[function])
+			e.integer(functionName, table.index(function))
+			e.integer(functionSystemName, table.index(function))
+			e.integer(functionFilename, table.index(p.source))
+			e.integer(functionStartLine, int64(p.starts[function]))
+		})
+	}
+	periodType := func(e *encoder) {
+		e.integer(valueTypeType, table.index("time"))
+		e.integer(valueTypeUnit, table.index("nanoseconds"))
+	}
+	profile.message(profilePeriodType, periodType)
+	for _, value := range table.values {
+		profile.bytes(profileStringTable, []byte(value))
+	}
+	profile.integer(profileTimeNanos, p.start.UnixNano())
+	profile.integer(profileDurationNanos, int64(time.Since(p.start)))
+	profile.integer(profilePeriod, 1)
+
+	writer := gzip.NewWriter(w)
+	if _, err := writer.Write(profile.buffer); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// sortedSamples returns the samples ordered by their stacks, for profiles not to depend on the order of a map.
+func (p *Profiler) sortedSamples() []*sample {
+	keys := make([]string, 0, len(p.samples))
+	for key := range p.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]*sample, len(keys))
+	for index, key := range keys {
+		samples[index] = p.samples[key]
+	}
+	return samples
+}
@@ -0,0 +1,138 @@
+// Package profiler measures where a Lox program spends its time. Its interpreter hooks follow every statement a
+// task starts and every function it enters and leaves; the time between two events is charged to the call stack
+// the task had meanwhile. A Profiler collects the stacks of all the tasks of a program and writes them in the pprof
+// format, so that `go tool pprof` can show flame graphs of Lox code, or as a plain-text summary.
+package profiler
+
+import (
+	"golox/lox/interpreter"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Location is a line of a function. The location of a frame which isn't the innermost one is the line of the call
+// in progress.
+type Location struct {
+	Function string
+	Line     int
+}
+
+// sample is the time charged to one call stack, Stack holding its locations from the innermost frame outwards.
+type sample struct {
+	stack []Location
+	count int64 // number of times time was charged to the stack
+	time  time.Duration
+}
+
+// Profiler aggregates the stacks of the tasks of one program. It is safe for concurrent use.
+type Profiler struct {
+	mutex      sync.Mutex
+	source     string
+	start      time.Time
+	samples    map[string]*sample
+	calls      map[string]int64   // number of calls of each function
+	executions map[Location]int64 // number of statements started on each line
+	starts     map[string]int     // the line each function starts on
+}
+
+// NewProfiler returns a profiler for the script source, which names the file of the profiled functions.
+func NewProfiler(source string) *Profiler {
+	return &Profiler{
+		source:     source,
+		start:      time.Now(),
+		samples:    make(map[string]*sample),
+		calls:      make(map[string]int64),
+		executions: make(map[Location]int64),
+		starts:     make(map[string]int),
+	}
+}
+
+// stack follows the calls of one task. It isn't safe for concurrent use, every task has a stack of its own.
+type stack struct {
+	interpreter.NoHooks
+	profiler *Profiler
+	frames   []Location
+	lines    []int // the lines the innermost frame was on before the statements in progress
+	last     time.Time
+	running  bool
+}
+
+// newStack returns the stack of a task whose code outside of any function is named root. The stack is paused.
+func (p *Profiler) newStack(root string) *stack {
+	return &stack{profiler: p, frames: []Location{{Function: root}}}
+}
+
+// charge charges the time elapsed since the previous event to the current stack.
+func (s *stack) charge() {
+	now := time.Now()
+	if s.running {
+		s.profiler.record(s.frames, now.Sub(s.last))
+	}
+	s.last = now
+	s.running = true
+}
+
+func (p *Profiler) record(frames []Location, elapsed time.Duration) {
+	var key strings.Builder
+	for index := len(frames) - 1; index >= 0; index-- {
+		key.WriteString(frames[index].Function)
+		key.WriteByte(':')
+		key.WriteString(strconv.Itoa(frames[index].Line))
+		key.WriteByte(';')
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	entry, ok := p.samples[key.String()]
+	if !ok {
+		stack := make([]Location, 0, len(frames))
+		for index := len(frames) - 1; index >= 0; index-- {
+			stack = append(stack, frames[index])
+		}
+		entry = &sample{stack: stack}
+		p.samples[key.String()] = entry
+	}
+	entry.count++
+	entry.time += elapsed
+}
+
+// statement moves the innermost frame to the statement starting on line.
+func (s *stack) statement(line int) {
+	s.charge()
+	top := &s.frames[len(s.frames)-1]
+	s.lines = append(s.lines, top.Line)
+	top.Line = line
+	location := *top
+	s.profiler.mutex.Lock()
+	s.profiler.executions[location]++
+	s.profiler.mutex.Unlock()
+}
+
+// statementEnd moves the innermost frame back to the statement enclosing the one done.
+func (s *stack) statementEnd() {
+	s.charge()
+	s.frames[len(s.frames)-1].Line = s.lines[len(s.lines)-1]
+	s.lines = s.lines[:len(s.lines)-1]
+}
+
+// enter pushes a frame for a call of function, which starts on line start.
+func (s *stack) enter(function string, start int) {
+	s.charge()
+	s.frames = append(s.frames, Location{Function: function, Line: start})
+	s.profiler.mutex.Lock()
+	s.profiler.calls[function]++
+	s.profiler.starts[function] = start
+	s.profiler.mutex.Unlock()
+}
+
+func (s *stack) exit() {
+	s.charge()
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+// pause stops charging time to the stack until its next event.
+func (s *stack) pause() {
+	s.charge()
+	s.running = false
+}
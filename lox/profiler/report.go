@@ -0,0 +1,168 @@
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// FunctionStats is what a function cost. Flat is the time spent in the function itself and Cum the time spent in
+// the function and the functions it called.
+type FunctionStats struct {
+	Name  string
+	Calls int64
+	Flat  time.Duration
+	Cum   time.Duration
+}
+
+// LineStats is what a line cost. Executions counts the statements started on the line.
+type LineStats struct {
+	Location
+	Executions int64
+	Flat       time.Duration
+	Cum        time.Duration
+}
+
+// Functions returns the stats of every function profiled, the most expensive first.
+func (p *Profiler) Functions() []FunctionStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	stats := make(map[string]*FunctionStats)
+	get := func(name string) *FunctionStats {
+		if _, ok := stats[name]; !ok {
+			stats[name] = &FunctionStats{Name: name, Calls: p.calls[name]}
+		}
+		return stats[name]
+	}
+	for name := range p.calls {
+		get(name)
+	}
+	for _, sample := range p.samples {
+		get(sample.stack[0].Function).Flat += sample.time
+		// a recursive function is charged once per sample
+		seen := make(map[string]bool)
+		for _, location := range sample.stack {
+			if !seen[location.Function] {
+				seen[location.Function] = true
+				get(location.Function).Cum += sample.time
+			}
+		}
+	}
+	result := make([]FunctionStats, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Flat != result[j].Flat {
+			return result[i].Flat > result[j].Flat
+		}
+		if result[i].Cum != result[j].Cum {
+			return result[i].Cum > result[j].Cum
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Lines returns the stats of every line profiled, the most expensive first.
+func (p *Profiler) Lines() []LineStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	stats := make(map[Location]*LineStats)
+	get := func(location Location) *LineStats {
+		if _, ok := stats[location]; !ok {
+			stats[location] = &LineStats{Location: location, Executions: p.executions[location]}
+		}
+		return stats[location]
+	}
+	for location := range p.executions {
+		get(location)
+	}
+	for _, sample := range p.samples {
+		get(sample.stack[0]).Flat += sample.time
+		seen := make(map[Location]bool)
+		for _, location := range sample.stack {
+			if !seen[location] {
+				seen[location] = true
+				get(location).Cum += sample.time
+			}
+		}
+	}
+	result := make([]LineStats, 0, len(stats))
+	for _, stat := range stats {
+		// the time a task spends before its first statement isn't on any line
+		if stat.Line != 0 {
+			result = append(result, *stat)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Flat != result[j].Flat {
+			return result[i].Flat > result[j].Flat
+		}
+		if result[i].Cum != result[j].Cum {
+			return result[i].Cum > result[j].Cum
+		}
+		if result[i].Function != result[j].Function {
+			return result[i].Function < result[j].Function
+		}
+		return result[i].Line < result[j].Line
+	})
+	return result
+}
+
+// total returns the time charged to all the stacks.
+func (p *Profiler) total() time.Duration {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var total time.Duration
+	for _, sample := range p.samples {
+		total += sample.time
+	}
+	return total
+}
+
+// WriteTop writes the n most expensive functions and lines, in the manner of `go tool pprof -top`.
+func (p *Profiler) WriteTop(w io.Writer, n int) error {
+	total := p.total()
+	percent := func(value time.Duration) string {
+		if total == 0 {
+			return "0.00%"
+		}
+		return fmt.Sprintf("%.2f%%", float64(value)*100/float64(total))
+	}
+	functions := p.Functions()
+	if _, err := fmt.Fprintf(w, "Showing top %d functions of %d, total %v\n", min(n, len(functions)), len(functions), total); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%12s %7s %12s %7s %10s  %s\n", "flat", "flat%", "cum", "cum%", "calls", "function"); err != nil {
+		return err
+	}
+	for _, function := range functions[:min(n, len(functions))] {
+		if _, err := fmt.Fprintf(w, "%12v %7s %12v %7s %10d  %s\n", function.Flat, percent(function.Flat), function.Cum, percent(function.Cum), function.Calls, function.Name); err != nil {
+			return err
+		}
+	}
+	lines := p.Lines()
+	if _, err := fmt.Fprintf(w, "\nShowing top %d lines of %d\n", min(n, len(lines)), len(lines)); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%12s %7s %12s %7s %10s  %s\n", "flat", "flat%", "cum", "cum%", "executions", "line"); err != nil {
+		return err
+	}
+	for _, line := range lines[:min(n, len(lines))] {
+		location := line.Function + ":" + strconv.Itoa(line.Line)
+		if _, err := fmt.Fprintf(w, "%12v %7s %12v %7s %10d  %s\n", line.Flat, percent(line.Flat), line.Cum, percent(line.Cum), line.Executions, location); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
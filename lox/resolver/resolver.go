@@ -3,9 +3,13 @@ package resolver
 import (
 	"errors"
 	"golox/lox/ast"
+	"golox/lox/catalog"
 	"golox/lox/interpreter"
 	"golox/lox/lexer"
+	"golox/lox/suggest"
 	"golox/utils"
+	"sort"
+	"strconv"
 )
 
 type functionType int
@@ -13,21 +17,56 @@ type functionType int
 const (
 	NONE = iota
 	FUNCTION
+	GENERATOR
 )
 
+// binding is what the resolver knows about a declared name.
+type binding struct {
+	defined     bool
+	constant    bool
+	declaration lexer.Token
+}
+
 type Resolver struct {
-	interpreter     *interpreter.Interpreter
-	scopes          []map[string]bool
-	currentFunction functionType
+	interpreter       *interpreter.Interpreter
+	scopes            []map[string]*binding
+	globals           map[string]*binding // top-level constants, the only globals the resolver tracks
+	currentFunction   functionType
+	loopDepth         int // loops enclosing the current statement within the current function
+	constantFunctions bool
+	patternNames      map[string]bool // names bound so far by the pattern being resolved
+	strict            bool
+	declared          map[string]bool // in strict mode, the natives and the globals the program declares
+	reporter          *utils.Reporter
 }
 
 func NewResolver(interpreter *interpreter.Interpreter) *Resolver {
-	return &Resolver{interpreter: interpreter, scopes: make([]map[string]bool, 0), currentFunction: NONE}
+	return &Resolver{interpreter: interpreter, scopes: make([]map[string]*binding, 0), globals: make(map[string]*binding, 0), currentFunction: NONE, reporter: utils.DefaultReporter}
+}
+
+// SetReporter sets where resolution errors are reported.
+func (i *Resolver) SetReporter(reporter *utils.Reporter) {
+	i.reporter = reporter
+}
+
+// SetConstantFunctions makes function declarations immutable: assigning to a name bound by `fun name() {}` becomes
+// an error, just like assigning to a `const`.
+func (i *Resolver) SetConstantFunctions(constant bool) {
+	i.constantFunctions = constant
+}
+
+// SetStrict rejects the references to global variables which neither the program nor the interpreter declares, which
+// are otherwise only found when they run. A program starting with the statement "use strict"; is strict too.
+func (i *Resolver) SetStrict(strict bool) {
+	i.strict = strict
 }
 
 func (i *Resolver) Resolve(obj interface{}) (interface{}, error) {
 	switch v := obj.(type) {
 	case []ast.Stmt:
+		if len(i.scopes) == 0 {
+			i.declareProgram(v)
+		}
 		for _, statement := range v {
 			_, err := i.Resolve(statement)
 			if err != nil {
@@ -39,8 +78,10 @@ func (i *Resolver) Resolve(obj interface{}) (interface{}, error) {
 		return v.Accept(i)
 	case ast.Expr:
 		return v.Accept(i)
+	case ast.Pattern:
+		return v.Accept(i)
 	}
-	utils.Report(-1, "Resolver#Resolve", "Impossible code reached")
+	i.reporter.Diagnose(utils.Diagnostic{Line: -1, Where: "Resolver#Resolve", Code: catalog.Internal, Message: "Impossible code reached"})
 	return nil, errors.New("impossible code reached")
 }
 
@@ -63,7 +104,7 @@ func (i *Resolver) VisitIfStmt(stmt *ast.If) (interface{}, error) {
 		return nil, err
 	}
 	if stmt.ElseBranch != nil {
-		_, err = i.Resolve(stmt.ThenBranch)
+		_, err = i.Resolve(stmt.ElseBranch)
 		if err != nil {
 			return nil, err
 		}
@@ -78,7 +119,21 @@ func (i *Resolver) VisitPrintStmt(stmt *ast.Print) (interface{}, error) {
 
 func (i *Resolver) VisitReturnStmt(stmt *ast.Return) (interface{}, error) {
 	if i.currentFunction == NONE {
-		utils.Report(stmt.KeyWord.Line, "Resolver#VisitReturnStmt ", "`return` in top code")
+		return nil, i.raiseError(stmt.KeyWord, catalog.TopLevelReturn, "Can't return from top-level code.")
+	}
+	if stmt.Value != nil {
+		if i.currentFunction == GENERATOR {
+			return nil, i.raiseError(stmt.KeyWord, catalog.GeneratorReturn, "Can't return a value from a generator")
+		}
+		_, err := i.Resolve(stmt.Value)
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (i *Resolver) VisitYieldStmt(stmt *ast.Yield) (interface{}, error) {
+	if i.currentFunction != GENERATOR {
+		return nil, i.raiseError(stmt.Keyword, catalog.YieldOutsideFunction, "Can't yield outside of a function")
 	}
 	if stmt.Value != nil {
 		_, err := i.Resolve(stmt.Value)
@@ -87,6 +142,30 @@ func (i *Resolver) VisitReturnStmt(stmt *ast.Return) (interface{}, error) {
 	return nil, nil
 }
 
+func (i *Resolver) VisitAssertStmt(stmt *ast.Assert) (interface{}, error) {
+	_, err := i.Resolve(stmt.Condition)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.Message != nil {
+		_, err = i.Resolve(stmt.Message)
+	}
+	return nil, err
+}
+
+func (i *Resolver) VisitTestStmt(stmt *ast.Test) (interface{}, error) {
+	if len(i.scopes) != 0 {
+		return nil, i.raiseError(stmt.Keyword, catalog.NestedTest, "Test blocks must be at the top level")
+	}
+	i.beginScope()
+	_, err := i.Resolve(stmt.Body)
+	if err != nil {
+		return nil, err
+	}
+	i.endScope()
+	return nil, nil
+}
+
 func (i *Resolver) VisitWhileStmt(stmt *ast.While) (interface{}, error) {
 	_, err := i.Resolve(stmt.Condition)
 	if err != nil {
@@ -99,21 +178,181 @@ func (i *Resolver) VisitWhileStmt(stmt *ast.While) (interface{}, error) {
 			return nil, err
 		}
 	}
+	i.loopDepth++
 	_, err = i.Resolve(stmt.Body)
 	if err != nil {
 		return nil, err
 	}
+	i.loopDepth--
 	return nil, nil
 }
 
-func (i *Resolver) VisitBreakStmt(_ *ast.Break) (interface{}, error) {
+func (i *Resolver) VisitForInStmt(stmt *ast.ForIn) (interface{}, error) {
+	_, err := i.Resolve(stmt.Iterable)
+	if err != nil {
+		return nil, err
+	}
+	// the loop variable lives in a scope of its own, matching the environment created for each iteration
+	i.beginScope()
+	err = i.declare(stmt.Name, false)
+	if err != nil {
+		return nil, err
+	}
+	i.define(stmt.Name)
+	i.loopDepth++
+	_, err = i.Resolve(stmt.Body)
+	if err != nil {
+		return nil, err
+	}
+	i.loopDepth--
+	i.endScope()
 	return nil, nil
 }
 
-func (i *Resolver) VisitContinueStmt(_ *ast.Continue) (interface{}, error) {
+func (i *Resolver) VisitBreakStmt(stmt *ast.Break) (interface{}, error) {
+	if i.loopDepth == 0 {
+		return nil, i.raiseError(stmt.Keyword, catalog.JumpOutsideLoop, "Can't use 'break' outside of a loop.")
+	}
+	return nil, nil
+}
+
+func (i *Resolver) VisitContinueStmt(stmt *ast.Continue) (interface{}, error) {
+	if i.loopDepth == 0 {
+		return nil, i.raiseError(stmt.Keyword, catalog.JumpOutsideLoop, "Can't use 'continue' outside of a loop.")
+	}
 	return nil, nil
 }
 
+func (i *Resolver) VisitMatchStmt(stmt *ast.Match) (interface{}, error) {
+	_, err := i.Resolve(stmt.Subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, matchCase := range stmt.Cases {
+		// every case gets its own scope holding the variables bound by its patterns
+		i.beginScope()
+		alternatives := make([]map[string]bool, 0, len(matchCase.Patterns))
+		for _, pattern := range matchCase.Patterns {
+			i.patternNames = make(map[string]bool, 0)
+			_, err = i.Resolve(pattern)
+			if err != nil {
+				return nil, err
+			}
+			alternatives = append(alternatives, i.patternNames)
+		}
+		if err := i.checkAlternatives(matchCase.Patterns, alternatives); err != nil {
+			return nil, err
+		}
+		if matchCase.Guard != nil {
+			_, err = i.Resolve(matchCase.Guard)
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err = i.Resolve(matchCase.Body)
+		if err != nil {
+			return nil, err
+		}
+		i.endScope()
+	}
+	return nil, nil
+}
+
+// checkAlternatives rejects a case whose patterns, which bound the names in alternatives, don't all bind the same
+// names: only those of the pattern which matched are defined when the body runs.
+func (i *Resolver) checkAlternatives(patterns []ast.Pattern, alternatives []map[string]bool) error {
+	union := make(map[string]bool, 0)
+	for _, bound := range alternatives {
+		for name := range bound {
+			union[name] = true
+		}
+	}
+	names := make([]string, 0, len(union))
+	for name := range union {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for index, bound := range alternatives {
+		for _, name := range names {
+			if !bound[name] {
+				return i.raiseError(patternToken(patterns[index]), catalog.AlternativeBindings,
+					"Every alternative of a case must bind '"+name+"'.")
+			}
+		}
+	}
+	return nil
+}
+
+// patternToken returns the first token of pattern.
+func patternToken(pattern ast.Pattern) lexer.Token {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		return pattern.Token
+	case *ast.BindingPattern:
+		return pattern.Name
+	case *ast.WildcardPattern:
+		return pattern.Token
+	case *ast.ListPattern:
+		return pattern.Bracket
+	case *ast.MapPattern:
+		return pattern.Brace
+	}
+	return lexer.Token{}
+}
+
+func (i *Resolver) VisitLiteralPattern(_ *ast.LiteralPattern) (interface{}, error) {
+	return nil, nil
+}
+
+func (i *Resolver) VisitBindingPattern(pattern *ast.BindingPattern) (interface{}, error) {
+	return nil, i.declarePatternName(pattern.Name)
+}
+
+func (i *Resolver) VisitWildcardPattern(_ *ast.WildcardPattern) (interface{}, error) {
+	return nil, nil
+}
+
+func (i *Resolver) VisitListPattern(pattern *ast.ListPattern) (interface{}, error) {
+	for _, element := range pattern.Elements {
+		_, err := i.Resolve(element)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pattern.Rest != nil {
+		return nil, i.declarePatternName(*pattern.Rest)
+	}
+	return nil, nil
+}
+
+func (i *Resolver) VisitMapPattern(pattern *ast.MapPattern) (interface{}, error) {
+	for _, value := range pattern.Values {
+		_, err := i.Resolve(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// declarePatternName binds a pattern variable in the current case scope. Alternatives of the same case
+// (`case [x], x =>`) bind the same names, but a single pattern can't bind one twice.
+func (i *Resolver) declarePatternName(name lexer.Token) error {
+	if i.patternNames[name.Lexeme] {
+		return i.raiseError(name, catalog.DuplicateBinding, "Duplicate binding '"+name.Lexeme+"' in pattern")
+	}
+	i.patternNames[name.Lexeme] = true
+	if _, ok := i.scopes[len(i.scopes)-1][name.Lexeme]; ok {
+		return nil
+	}
+	err := i.declare(name, false)
+	if err != nil {
+		return err
+	}
+	i.define(name)
+	return nil
+}
+
 func (i *Resolver) VisitBinaryExpr(expr *ast.Binary) (interface{}, error) {
 	_, err := i.Resolve(expr.Left)
 	if err != nil {
@@ -174,6 +413,14 @@ func (i *Resolver) VisitAssignExpr(expr *ast.Assign) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = i.checkAssignable(expr.Name)
+	if err != nil {
+		return nil, err
+	}
+	err = i.checkDeclared(expr.Name, false)
+	if err != nil {
+		return nil, err
+	}
 	_, err = i.resolveLocal(expr, expr.Name)
 	if err != nil {
 		return nil, err
@@ -181,6 +428,51 @@ func (i *Resolver) VisitAssignExpr(expr *ast.Assign) (interface{}, error) {
 	return nil, nil
 }
 
+func (i *Resolver) VisitUpdateExpr(expr *ast.Update) (interface{}, error) {
+	if v, ok := expr.Target.(*ast.Variable); ok {
+		err := i.checkAssignable(v.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err := i.Resolve(expr.Target)
+	return nil, err
+}
+
+func (i *Resolver) VisitSpreadExpr(expr *ast.Spread) (interface{}, error) {
+	_, err := i.Resolve(expr.Expression)
+	return nil, err
+}
+
+func (i *Resolver) VisitNamedArgExpr(expr *ast.NamedArg) (interface{}, error) {
+	_, err := i.Resolve(expr.Value)
+	return nil, err
+}
+
+func (i *Resolver) VisitSpawnExpr(expr *ast.Spawn) (interface{}, error) {
+	_, err := i.Resolve(expr.Call)
+	return nil, err
+}
+
+func (i *Resolver) VisitTypeCheckExpr(expr *ast.TypeCheck) (interface{}, error) {
+	_, err := i.Resolve(expr.Expression)
+	return nil, err
+}
+
+func (i *Resolver) VisitMapExpr(expr *ast.Map) (interface{}, error) {
+	for index, key := range expr.Keys {
+		_, err := i.Resolve(key)
+		if err != nil {
+			return nil, err
+		}
+		_, err = i.Resolve(expr.Values[index])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
 func (i *Resolver) VisitTernaryExpr(expr *ast.Ternary) (interface{}, error) {
 	_, err := i.Resolve(expr.ConditionalExpr)
 	if err != nil {
@@ -200,12 +492,21 @@ func (i *Resolver) VisitTernaryExpr(expr *ast.Ternary) (interface{}, error) {
 }
 
 func (i *Resolver) VisitFunctionExpr(expr *ast.FunctionExpr) (interface{}, error) {
+	enclosingFunction, enclosingLoopDepth := i.currentFunction, i.loopDepth
+	i.currentFunction = FUNCTION
+	if expr.Generator {
+		i.currentFunction = GENERATOR
+	}
+	i.loopDepth = 0
+	defer func() {
+		i.currentFunction, i.loopDepth = enclosingFunction, enclosingLoopDepth
+	}()
 	i.beginScope()
-	for _, param := range expr.Params {
-		i.declare(param)
-		i.define(param)
+	_, err := i.resolveParams(expr.Params)
+	if err != nil {
+		return nil, err
 	}
-	_, err := i.Resolve(expr.Body)
+	_, err = i.Resolve(expr.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -224,7 +525,10 @@ func (i *Resolver) VisitBlockStmt(stmt *ast.Block) (interface{}, error) {
 }
 
 func (i *Resolver) VisitVarStmt(stmt *ast.Var) (interface{}, error) {
-	i.declare(stmt.Name)
+	err := i.declare(stmt.Name, stmt.Constant)
+	if err != nil {
+		return nil, err
+	}
 	if stmt.Initializer != nil {
 		_, err := i.Resolve(stmt.Initializer)
 		if err != nil {
@@ -239,12 +543,16 @@ func (i *Resolver) VisitVariableExpr(expr *ast.Variable) (interface{}, error) {
 	if len(i.scopes) > 0 {
 		scope := i.scopes[len(i.scopes)-1]
 		if v, ok := scope[expr.Name.Lexeme]; ok {
-			if !v {
-				utils.Report(expr.Name.Line, "Resolver", "Can't read local variable in its own initializer")
+			if !v.defined {
+				return nil, i.raiseError(expr.Name, catalog.OwnInitializer, "Can't read local variable in its own initializer.")
 			}
 		}
 	}
-	_, err := i.resolveLocal(expr, expr.Name)
+	err := i.checkDeclared(expr.Name, true)
+	if err != nil {
+		return nil, err
+	}
+	_, err = i.resolveLocal(expr, expr.Name)
 	if err != nil {
 		return nil, err
 	}
@@ -252,9 +560,16 @@ func (i *Resolver) VisitVariableExpr(expr *ast.Variable) (interface{}, error) {
 }
 
 func (i *Resolver) VisitFunctionStmt(stmt *ast.Function) (interface{}, error) {
-	i.declare(stmt.Name)
+	err := i.declare(stmt.Name, i.constantFunctions)
+	if err != nil {
+		return nil, err
+	}
 	i.define(stmt.Name)
-	_, err := i.resolveFunction(stmt, FUNCTION)
+	kind := functionType(FUNCTION)
+	if stmt.Generator {
+		kind = GENERATOR
+	}
+	_, err = i.resolveFunction(stmt, kind)
 	if err != nil {
 		return nil, err
 	}
@@ -262,19 +577,41 @@ func (i *Resolver) VisitFunctionStmt(stmt *ast.Function) (interface{}, error) {
 }
 
 func (i *Resolver) resolveFunction(function *ast.Function, functionType0 functionType) (interface{}, error) {
-	enclosingFunction := i.currentFunction
+	enclosingFunction, enclosingLoopDepth := i.currentFunction, i.loopDepth
 	i.currentFunction = functionType0
+	i.loopDepth = 0
+	defer func() {
+		i.currentFunction, i.loopDepth = enclosingFunction, enclosingLoopDepth
+	}()
 	i.beginScope()
-	for _, param := range function.Params {
-		i.declare(param)
-		i.define(param)
+	_, err := i.resolveParams(function.Params)
+	if err != nil {
+		return nil, err
 	}
-	_, err := i.Resolve(function.Body)
+	_, err = i.Resolve(function.Body)
 	if err != nil {
 		return nil, err
 	}
 	i.endScope()
-	i.currentFunction = enclosingFunction
+	return nil, nil
+}
+
+// resolveParams declares the parameters in order; a default value is resolved in the function scope so it can
+// refer to the parameters declared before it.
+func (i *Resolver) resolveParams(params []ast.Param) (interface{}, error) {
+	for _, param := range params {
+		err := i.declare(param.Name, false)
+		if err != nil {
+			return nil, err
+		}
+		if param.Default != nil {
+			_, err := i.Resolve(param.Default)
+			if err != nil {
+				return nil, err
+			}
+		}
+		i.define(param.Name)
+	}
 	return nil, nil
 }
 
@@ -289,24 +626,30 @@ func (i *Resolver) resolveLocal(expr ast.Expr, name lexer.Token) (interface{}, e
 }
 
 func (i *Resolver) beginScope() {
-	i.scopes = append(i.scopes, make(map[string]bool, 0))
+	i.scopes = append(i.scopes, make(map[string]*binding, 0))
 }
 
 func (i *Resolver) endScope() {
 	i.scopes = i.scopes[:len(i.scopes)-1]
 }
 
-func (i *Resolver) declare(name lexer.Token) {
+func (i *Resolver) declare(name lexer.Token, constant bool) error {
 	if len(i.scopes) == 0 {
-		return
+		if previous, ok := i.globals[name.Lexeme]; ok {
+			return i.raiseError(name, catalog.Redeclaration, "Cannot redeclare constant '"+name.Lexeme+"' declared at line "+strconv.Itoa(previous.declaration.Line))
+		}
+		if constant {
+			i.globals[name.Lexeme] = &binding{defined: true, constant: true, declaration: name}
+		}
+		return nil
 	}
 	scope := i.scopes[len(i.scopes)-1]
 	if _, ok := scope[name.Lexeme]; ok {
-		utils.Report(name.Line, "Resolver#declare ", "Multiple definition")
+		return i.raiseError(name, catalog.Redeclaration, "Already a variable with this name in this scope.")
 	}
-	scope[name.Lexeme] = false
+	scope[name.Lexeme] = &binding{defined: false, constant: constant, declaration: name}
 	i.scopes[len(i.scopes)-1] = scope
-
+	return nil
 }
 
 func (i *Resolver) define(name lexer.Token) {
@@ -314,6 +657,99 @@ func (i *Resolver) define(name lexer.Token) {
 		return
 	}
 	scope := i.scopes[len(i.scopes)-1]
-	scope[name.Lexeme] = true
+	scope[name.Lexeme].defined = true
 	i.scopes[len(i.scopes)-1] = scope
 }
+
+// lookup finds the binding a name refers to from the innermost scope outwards, ending with the tracked globals.
+func (i *Resolver) lookup(name lexer.Token) (*binding, bool) {
+	for index := len(i.scopes) - 1; index >= 0; index-- {
+		if v, ok := i.scopes[index][name.Lexeme]; ok {
+			return v, true
+		}
+	}
+	v, ok := i.globals[name.Lexeme]
+	return v, ok
+}
+
+func (i *Resolver) checkAssignable(name lexer.Token) error {
+	if v, ok := i.lookup(name); ok && v.constant {
+		return i.raiseError(name, catalog.AssignConstant, "Cannot assign to constant '"+name.Lexeme+"' declared at line "+strconv.Itoa(v.declaration.Line))
+	}
+	return nil
+}
+
+// declareProgram collects, in strict mode, the names a reference to a global may use: the globals of the interpreter,
+// natives included, and the variables and functions declared at the top level of the program, before or after the
+// reference since a function may use a global declared after it.
+func (i *Resolver) declareProgram(statements []ast.Stmt) {
+	if isStrictPragma(statements) {
+		i.strict = true
+	}
+	if !i.strict {
+		return
+	}
+	i.declared = make(map[string]bool)
+	for _, name := range i.interpreter.GlobalNames() {
+		i.declared[name] = true
+	}
+	for _, statement := range statements {
+		switch v := statement.(type) {
+		case *ast.Var:
+			i.declared[v.Name.Lexeme] = true
+		case *ast.Function:
+			i.declared[v.Name.Lexeme] = true
+		}
+	}
+}
+
+// isStrictPragma tells whether a program starts with the statement "use strict";.
+func isStrictPragma(statements []ast.Stmt) bool {
+	if len(statements) == 0 {
+		return false
+	}
+	if expression, ok := statements[0].(*ast.Expression); ok {
+		if literal, ok := expression.Expression.(*ast.Literal); ok {
+			return literal.Value == "use strict"
+		}
+	}
+	return false
+}
+
+// checkDeclared rejects, in strict mode, a reference to a global which isn't declared, suggesting a name in scope
+// close to it, or a keyword for a read.
+func (i *Resolver) checkDeclared(name lexer.Token, read bool) error {
+	if !i.strict || i.declared[name.Lexeme] {
+		return nil
+	}
+	var candidates []string
+	for index := len(i.scopes) - 1; index >= 0; index-- {
+		if _, ok := i.scopes[index][name.Lexeme]; ok {
+			return nil
+		}
+		for candidate := range i.scopes[index] {
+			candidates = append(candidates, candidate)
+		}
+	}
+	for candidate := range i.declared {
+		candidates = append(candidates, candidate)
+	}
+	if read {
+		candidates = append(candidates, suggest.ExpressionKeywords()...)
+	}
+	var fix *utils.Fix
+	if closest := suggest.Closest(name.Lexeme, candidates); closest != "" {
+		fix = &utils.Fix{Line: name.Line, Column: name.Column, Old: name.Lexeme, New: closest}
+	}
+	return i.raiseErrorFix(name, catalog.UndefinedVariable, "Undefined variable '"+name.Lexeme+"'.", fix)
+}
+
+func (i *Resolver) raiseError(token lexer.Token, code string, message string) error {
+	return i.raiseErrorFix(token, code, message, nil)
+}
+
+// raiseErrorFix is raiseError for an error which fix corrects.
+func (i *Resolver) raiseErrorFix(token lexer.Token, code string, message string, fix *utils.Fix) error {
+	i.reporter.Diagnose(utils.Diagnostic{Line: token.Line, Where: "at '" + token.Lexeme + "'", Code: code, Message: message, Fix: fix})
+	return errors.New("resolve error")
+}
@@ -0,0 +1,84 @@
+// Package suggest finds the names a misspelled one was likely meant to be, for "Did you mean?" hints.
+package suggest
+
+import (
+	"golox/lox/lexer"
+	"sort"
+)
+
+// Closest returns the candidate name is most likely a typo of: the one at the smallest edit distance, the first in
+// alphabetical order among equally close ones. It returns "" when no candidate is close enough, allowing one edit
+// per three characters of name, so names shorter than three characters get no suggestion.
+func Closest(name string, candidates []string) string {
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		distance := Distance(name, candidate)
+		if distance < bestDistance || (distance == bestDistance && best != "" && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// Keywords returns the keywords of Lox in alphabetical order.
+func Keywords() []string {
+	keywords := make([]string, 0, len(lexer.KeyWords))
+	for keyword := range lexer.KeyWords {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+	return keywords
+}
+
+// ExpressionKeywords returns the keywords which can start an expression, in alphabetical order. They are the only
+// keywords a misspelled name read as a variable can stand for, statement keywords are suggested by the parser.
+func ExpressionKeywords() []string {
+	keywords := make([]string, 0)
+	for _, keyword := range Keywords() {
+		switch lexer.KeyWords[keyword] {
+		case lexer.TRUE, lexer.FALSE, lexer.NIL, lexer.FUN, lexer.SPAWN:
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// Distance is the number of insertions, deletions, substitutions and transpositions of adjacent characters which
+// turn a into b, the optimal string alignment distance.
+func Distance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	// rows[k][j] is the distance between the first i-2+k runes of s and the first j runes of t
+	rows := [3][]int{make([]int, len(t)+1), make([]int, len(t)+1), make([]int, len(t)+1)}
+	for j := range rows[2] {
+		rows[2][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		rows[0], rows[1], rows[2] = rows[1], rows[2], rows[0]
+		current, previous, beforePrevious := rows[2], rows[1], rows[0]
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				current[j] = minimum(current[j], beforePrevious[j-2]+1)
+			}
+		}
+	}
+	return rows[2][len(t)]
+}
+
+func minimum(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
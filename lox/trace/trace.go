@@ -0,0 +1,198 @@
+// Package trace logs what a Lox program does as it runs: every statement it executes, and every function it enters
+// and returns from, with the source line, the call depth, the arguments and the return value, and optionally the
+// bindings of the innermost scope before each statement. Events are written as text or as newline-delimited JSON.
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"golox/lox/ast"
+	"golox/lox/common"
+	"golox/lox/interpreter"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Format int
+
+const (
+	Text Format = iota
+	JSON
+)
+
+// Formats maps the names of the formats to them.
+var Formats = map[string]Format{"text": Text, "json": JSON}
+
+// Event is one line of a trace, see its JSON encoding for the fields each kind of event sets.
+type Event struct {
+	Event       string            `json:"event"` // "statement", "call" or "return"
+	Task        int               `json:"task"`  // 0 for the main script, then numbered as tasks and generators start
+	Line        int               `json:"line"`  // the line of the statement, or of the call for call and return
+	Depth       int               `json:"depth"` // the number of calls in progress, the callee's excluded
+	Statement   string            `json:"statement,omitempty"`
+	Function    string            `json:"function,omitempty"`
+	Arguments   []string          `json:"arguments,omitempty"`
+	Value       string            `json:"value,omitempty"` // the value returned, unless the call raised Error
+	Error       string            `json:"error,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+}
+
+// Tracer writes the events of all the tasks of a program. It is safe for concurrent use.
+type Tracer struct {
+	mutex       sync.Mutex
+	writer      io.Writer
+	format      Format
+	environment bool
+	tasks       int
+	err         error
+}
+
+func NewTracer(writer io.Writer, format Format) *Tracer {
+	return &Tracer{writer: writer, format: format}
+}
+
+// SetEnvironment adds the bindings of the innermost scope to every statement event.
+func (t *Tracer) SetEnvironment(environment bool) {
+	t.environment = environment
+}
+
+// Environment tells whether statement events hold the bindings of the innermost scope.
+func (t *Tracer) Environment() bool {
+	return t.environment
+}
+
+// Err returns the first error writing the trace; tracing stops at that error.
+func (t *Tracer) Err() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.err
+}
+
+// task follows one task of the program. It isn't safe for concurrent use, every task has one of its own.
+type task struct {
+	interpreter.NoHooks
+	tracer *Tracer
+	id     int
+	line   int   // the line of the statement in progress
+	calls  []int // the lines of the calls in progress
+}
+
+// newTask returns the next task of the program, the first one being its main script, which starts on line.
+func (t *Tracer) newTask(line int) *task {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	task := &task{tracer: t, id: t.tasks, line: line}
+	t.tasks++
+	return task
+}
+
+// Hooks returns the interpreter hooks tracing a program, see Interpreter.AddHooks.
+func (t *Tracer) Hooks() interpreter.Hooks {
+	return t.newTask(0)
+}
+
+func (t *task) OnStatement(i *interpreter.Interpreter, stmt ast.Stmt) {
+	var bindings map[string]string
+	if t.tracer.environment {
+		bindings = make(map[string]string)
+		for name, value := range i.Bindings() {
+			// the natives would clutter every statement of the main script
+			if _, function := value.(*interpreter.LoxFunction); !function {
+				if _, native := value.(interpreter.LoxCallable); native {
+					continue
+				}
+			}
+			bindings[name] = interpreter.Repr(value)
+		}
+	}
+	// the first line is enough to recognize a statement, its body is traced anyway
+	text, _, _ := strings.Cut(strings.TrimSuffix(ast.Format([]ast.Stmt{stmt}), "\n"), "\n")
+	t.line = ast.Line(stmt)
+	t.tracer.write(Event{Event: "statement", Task: t.id, Line: t.line, Depth: i.Depth(), Statement: text, Environment: bindings})
+}
+
+func (t *task) OnCall(i *interpreter.Interpreter, function *interpreter.LoxFunction, arguments []interface{}, named map[string]interface{}) {
+	values := make([]string, 0, len(arguments)+len(named))
+	for _, argument := range arguments {
+		values = append(values, interpreter.Repr(argument))
+	}
+	for _, param := range function.Declaration.Params {
+		if value, ok := named[param.Name.Lexeme]; ok {
+			values = append(values, param.Name.Lexeme+": "+interpreter.Repr(value))
+		}
+	}
+	t.calls = append(t.calls, t.line)
+	t.tracer.write(Event{Event: "call", Task: t.id, Line: t.line, Depth: i.Depth(), Function: function.Label(), Arguments: values})
+}
+
+func (t *task) OnReturn(i *interpreter.Interpreter, function *interpreter.LoxFunction, value interface{}, err error) {
+	t.line = t.calls[len(t.calls)-1]
+	t.calls = t.calls[:len(t.calls)-1]
+	event := Event{Event: "return", Task: t.id, Line: t.line, Depth: i.Depth(), Function: function.Label()}
+	switch v := err.(type) {
+	case nil:
+		event.Value = interpreter.Repr(value)
+	case common.RuntimeError:
+		event.Error = v.Reason
+	default:
+		event.Error = v.Error()
+	}
+	t.tracer.write(event)
+}
+
+// OnFork numbers the new task, which starts on the line of the spawn or test, if any.
+func (t *task) OnFork(_ *interpreter.Interpreter, _ *interpreter.Interpreter, fork interpreter.Fork) interpreter.Hooks {
+	return t.tracer.newTask(fork.Line)
+}
+
+func (t *Tracer) write(event Event) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.err != nil {
+		return
+	}
+	if t.format == JSON {
+		line, err := json.Marshal(event)
+		if err == nil {
+			_, err = t.writer.Write(append(line, '\n'))
+		}
+		t.err = err
+		return
+	}
+	_, t.err = io.WriteString(t.writer, text(event))
+}
+
+// text renders an event on a line indented by its depth, followed by the bindings of the environment one per line.
+func text(event Event) string {
+	var builder strings.Builder
+	indent := strings.Repeat("  ", event.Depth)
+	builder.WriteString(indent)
+	if event.Task != 0 {
+		builder.WriteString(fmt.Sprintf("task %d ", event.Task))
+	}
+	builder.WriteString(fmt.Sprintf("[line %d] depth %d: ", event.Line, event.Depth))
+	switch event.Event {
+	case "statement":
+		builder.WriteString(event.Statement)
+	case "call":
+		builder.WriteString("call " + event.Function + "(" + strings.Join(event.Arguments, ", ") + ")")
+	case "return":
+		if event.Error != "" {
+			builder.WriteString(event.Function + " raised " + event.Error)
+		} else {
+			builder.WriteString(event.Function + " returned " + event.Value)
+		}
+	}
+	builder.WriteString("\n")
+	names := make([]string, 0, len(event.Environment))
+	for name := range event.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		builder.WriteString(indent + "    " + name + " = " + event.Environment[name] + "\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,263 @@
+package loxrt
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// VariadicArity is the maximum arity reported by callables that accept any number of trailing arguments.
+const VariadicArity = -1
+
+// maxCallDepth bounds the nesting of Lox function calls like the interpreter does.
+const maxCallDepth = 10_000
+
+// Thread is the state of one task of the program: the main script, a spawned call or the body of a generator.
+type Thread struct {
+	depth     int             // number of Lox function calls in progress
+	generator *generatorState // the generator whose body the thread runs, nil for a task
+}
+
+// Callable is a value which can be called. Errors about the arguments are reported at line, the line of the call.
+type Callable interface {
+	Arity() (int, int)
+	Call(t *Thread, line int, arguments []Value, named map[string]Value) Value
+}
+
+// Argument is one argument of a call with spreads or named arguments. Name is empty for positional arguments, and
+// Spread marks a list whose elements are passed as separate positional arguments.
+type Argument struct {
+	Name   string
+	Value  Value
+	Spread bool
+}
+
+// Spread is the argument `...value`, which must be a list.
+func Spread(line int, value Value) Argument {
+	list, ok := value.(*List)
+	if !ok {
+		fail(line, "Can only spread lists")
+	}
+	return Argument{Value: list, Spread: true}
+}
+
+// Call calls callee with positional arguments.
+func Call(t *Thread, line int, callee Value, arguments ...Value) Value {
+	return call(t, line, callee, arguments, nil)
+}
+
+// CallArgs calls callee with arguments which include spreads or named arguments.
+func CallArgs(t *Thread, line int, callee Value, arguments []Argument) Value {
+	positional, named := expand(arguments)
+	return call(t, line, callee, positional, named)
+}
+
+func expand(arguments []Argument) ([]Value, map[string]Value) {
+	positional := make([]Value, 0, len(arguments))
+	var named map[string]Value
+	for _, argument := range arguments {
+		switch {
+		case argument.Spread:
+			positional = append(positional, argument.Value.(*List).Elements...)
+		case argument.Name != "":
+			if named == nil {
+				named = make(map[string]Value)
+			}
+			named[argument.Name] = argument.Value
+		default:
+			positional = append(positional, argument.Value)
+		}
+	}
+	return positional, named
+}
+
+func call(t *Thread, line int, callee Value, arguments []Value, named map[string]Value) Value {
+	function, ok := callee.(Callable)
+	if !ok {
+		fail(line, "Can only call functions and classes.")
+	}
+	if named != nil {
+		if _, ok := function.(*Function); !ok {
+			fail(line, "Function doesn't accept named arguments")
+		}
+	} else {
+		minArity, maxArity := function.Arity()
+		if len(arguments) < minArity || (maxArity != VariadicArity && len(arguments) > maxArity) {
+			fail(line, "Expected "+arityString(minArity, maxArity)+" arguments but got "+strconv.Itoa(len(arguments))+".")
+		}
+	}
+	return function.Call(t, line, arguments, named)
+}
+
+// arityString describes an arity range for error messages, e.g. "2", "1 to 3" or "at least 1".
+func arityString(minArity int, maxArity int) string {
+	if maxArity == VariadicArity {
+		return "at least " + strconv.Itoa(minArity)
+	}
+	if minArity == maxArity {
+		return strconv.Itoa(minArity)
+	}
+	return strconv.Itoa(minArity) + " to " + strconv.Itoa(maxArity)
+}
+
+// Param describes a parameter of a Lox function. Type is its annotation, empty when it has none.
+type Param struct {
+	Name    string
+	Default bool
+	Rest    bool
+	Type    string
+}
+
+// Function is a Lox function or closure. Its body binds the parameters itself through Arguments, so that default
+// values are evaluated in the scope of the parameters before them.
+type Function struct {
+	name      string
+	source    string // the declaration as written, for the `source` native
+	params    []Param
+	generator bool
+	body      func(t *Thread, args *Arguments) Value
+}
+
+func NewFunction(name string, source string, params []Param, generator bool, body func(t *Thread, args *Arguments) Value) *Function {
+	return &Function{name: name, source: source, params: params, generator: generator, body: body}
+}
+
+func (f *Function) Arity() (int, int) {
+	minArity := 0
+	for _, param := range f.params {
+		if param.Rest {
+			return minArity, VariadicArity
+		}
+		if !param.Default {
+			minArity++
+		}
+	}
+	return minArity, len(f.params)
+}
+
+func (f *Function) Call(t *Thread, line int, arguments []Value, named map[string]Value) Value {
+	if t.depth >= maxCallDepth {
+		fail(line, "Stack overflow.")
+	}
+	t.depth++
+	defer func() {
+		t.depth--
+	}()
+	minArity, maxArity := f.Arity()
+	if len(arguments) > maxArity && maxArity != VariadicArity {
+		fail(line, "Expected "+arityString(minArity, maxArity)+" arguments but got "+strconv.Itoa(len(arguments))+".")
+	}
+	for name := range named {
+		if !f.hasNamedParam(name) {
+			fail(line, "Unexpected named argument '"+name+"'")
+		}
+	}
+	return f.body(t, &Arguments{function: f, line: line, positional: arguments, named: named})
+}
+
+func (f *Function) hasNamedParam(name string) bool {
+	for _, param := range f.params {
+		if param.Name == name && !param.Rest {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Function) String() string {
+	if f.name == "" {
+		return "<fn anonymous>"
+	}
+	return "<fn " + f.name + ">"
+}
+
+// Missing is what Arguments.Bind returns for a parameter left to its default value.
+var Missing Value = &struct{ missing bool }{}
+
+// Arguments are the arguments of a call being bound to the parameters of a function, one parameter at a time.
+type Arguments struct {
+	function   *Function
+	line       int
+	positional []Value
+	named      map[string]Value
+}
+
+// Bind returns the value of parameter index, or Missing when it falls back to its default value.
+func (a *Arguments) Bind(index int) Value {
+	param := a.function.params[index]
+	if param.Rest {
+		rest := make([]Value, 0)
+		if index < len(a.positional) {
+			rest = append(rest, a.positional[index:]...)
+		}
+		for _, value := range rest {
+			a.check(param, value)
+		}
+		return &List{Elements: rest}
+	}
+	value, isNamed := a.named[param.Name]
+	if index < len(a.positional) {
+		if isNamed {
+			fail(a.line, "Argument '"+param.Name+"' given both by position and by name")
+		}
+		value = a.positional[index]
+	} else if !isNamed {
+		if !param.Default {
+			minArity, maxArity := a.function.Arity()
+			fail(a.line, "Missing argument '"+param.Name+"': expected "+arityString(minArity, maxArity)+" arguments but got "+strconv.Itoa(len(a.positional)+len(a.named)))
+		}
+		return Missing
+	}
+	return a.check(param, value)
+}
+
+// Default checks the default value of parameter index, which the function body evaluated after Bind returned
+// Missing.
+func (a *Arguments) Default(index int, value Value) Value {
+	return a.check(a.function.params[index], value)
+}
+
+func (a *Arguments) check(param Param, value Value) Value {
+	if param.Type != "" && !hasType(value, param.Type) {
+		fail(a.line, "Expected "+param.Type+" for argument '"+param.Name+"' but got "+TypeName(value)+".")
+	}
+	return value
+}
+
+// concurrent is set once the program spawns a task. From then on local variables, which closures may share between
+// tasks, are accessed under a lock like the interpreter's environments are.
+var concurrent int32
+
+var locals sync.RWMutex
+
+// Load and Store read and write a local variable of the generated code. Going through a call also keeps the read in
+// the left-to-right order of the calls around it, which Go doesn't guarantee for plain variable reads.
+func Load(variable *Value) Value {
+	if atomic.LoadInt32(&concurrent) == 0 {
+		return *variable
+	}
+	locals.RLock()
+	defer locals.RUnlock()
+	return *variable
+}
+
+func Store(variable *Value, value Value) Value {
+	if atomic.LoadInt32(&concurrent) == 0 {
+		*variable = value
+		return value
+	}
+	locals.Lock()
+	defer locals.Unlock()
+	*variable = value
+	return value
+}
+
+// UpdateLocal is `++` or `--` on a local variable, delta being 1 or -1.
+func UpdateLocal(variable *Value, line int, delta float64, prefix bool) Value {
+	old := Load(variable)
+	value := Store(variable, increment(old, line, delta))
+	if prefix {
+		return value
+	}
+	return old
+}
@@ -0,0 +1,138 @@
+package loxrt
+
+import (
+	"runtime"
+)
+
+// abandoned unwinds the body of a generator nobody holds a reference to anymore.
+var abandoned = &struct{ abandoned bool }{}
+
+// Generator is the iterator returned by calling a generator function. Like the interpreter's, its body runs on a
+// goroutine of its own and hands control back and forth with the caller over unbuffered channels, so the two never
+// run at the same time. Tasks sharing a generator take turns.
+type Generator struct {
+	*generatorState
+}
+
+type generatorState struct {
+	name   string
+	resume chan struct{}
+	yields chan generatorResult
+	done   chan struct{}
+	start  func()
+	// guarded by the mutex of the scheduler
+	started  bool
+	running  bool
+	finished bool
+	caller   *generatorState // the generator whose body asked for the value being computed, nil for a task
+}
+
+// generatorResult is a yielded value, or the end of the body when ok is false. failure is what the body panicked
+// with, nil when it returned normally.
+type generatorResult struct {
+	value   Value
+	ok      bool
+	failure interface{}
+}
+
+// Yielder is how the body of a generator yields its values.
+type Yielder struct {
+	state *generatorState
+}
+
+// NewGenerator creates the generator of a call to the generator function name. The body runs at the call depth of
+// t, or generators creating each other would never stop.
+func NewGenerator(t *Thread, name string, body func(t *Thread, y *Yielder)) *Generator {
+	state := &generatorState{
+		name:   name,
+		resume: make(chan struct{}),
+		yields: make(chan generatorResult),
+		done:   make(chan struct{}),
+	}
+	depth := t.depth
+	state.start = func() {
+		go func() {
+			var failure interface{}
+			defer func() {
+				if failure = recover(); failure == abandoned {
+					return
+				}
+				state.yields <- generatorResult{ok: false, failure: failure}
+			}()
+			body(&Thread{depth: depth, generator: state}, &Yielder{state: state})
+		}()
+	}
+	generator := &Generator{generatorState: state}
+	// the goroutine only references the state, so the finalizer releases a body suspended in a yield once the
+	// program drops the generator
+	runtime.SetFinalizer(generator, func(g *Generator) {
+		close(g.done)
+	})
+	return generator
+}
+
+// Yield hands value to the consumer of the generator and waits until it asks for the next one.
+func (y *Yielder) Yield(value Value) {
+	y.state.yields <- generatorResult{value: value, ok: true}
+	select {
+	case <-y.state.resume:
+	case <-y.state.done:
+		panic(abandoned)
+	}
+}
+
+func (g *Generator) next(t *Thread, line int) (Value, bool) {
+	ok, started := g.acquire(t, line)
+	if !ok {
+		return nil, false
+	}
+	if !started {
+		g.start()
+	} else {
+		g.resume <- struct{}{}
+	}
+	result := <-g.yields
+	g.release(result.ok)
+	if result.failure != nil {
+		panic(result.failure)
+	}
+	return result.value, result.ok
+}
+
+// acquire waits for the turn of t to resume the generator, ok is false when it is finished.
+func (g *Generator) acquire(t *Thread, line int) (ok bool, started bool) {
+	tasks.mutex.Lock()
+	defer tasks.mutex.Unlock()
+	// the body asking for its own next value, directly or through other generators, would wait on itself forever
+	for outer := t.generator; outer != nil; outer = outer.caller {
+		if outer == g.generatorState {
+			fail(line, "Generator is already running")
+		}
+	}
+	tasks.block(line, func() bool {
+		return !g.running
+	})
+	if g.finished {
+		return false, false
+	}
+	g.running, g.caller = true, t.generator
+	started, g.started = g.started, true
+	return true, started
+}
+
+func (g *Generator) release(ok bool) {
+	tasks.mutex.Lock()
+	defer tasks.mutex.Unlock()
+	g.running, g.caller = false, nil
+	if !ok {
+		g.finished = true
+	}
+	tasks.wake()
+}
+
+func (g *Generator) String() string {
+	if g.name == "" {
+		return "<generator anonymous>"
+	}
+	return "<generator " + g.name + ">"
+}
@@ -0,0 +1,135 @@
+package loxrt
+
+import (
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Iterator walks the value of a for-in loop. The generated loop calls Next before each iteration and reads the
+// loop variable from Value.
+type Iterator struct {
+	next  func() (Value, bool)
+	value Value
+}
+
+func (it *Iterator) Next() bool {
+	value, ok := it.next()
+	it.value = value
+	return ok
+}
+
+func (it *Iterator) Value() Value {
+	return it.value
+}
+
+// Iterate returns the iterator of a for-in loop over value at line. Strings are iterated rune by rune and a function
+// taking no argument is called once per iteration until it returns nil.
+func Iterate(t *Thread, line int, value Value) *Iterator {
+	switch v := value.(type) {
+	case *List:
+		index := 0
+		return &Iterator{next: func() (Value, bool) {
+			if index >= len(v.Elements) {
+				return nil, false
+			}
+			index++
+			return v.Elements[index-1], true
+		}}
+	case *Map:
+		index := 0
+		return &Iterator{next: func() (Value, bool) {
+			if index >= len(v.Keys) {
+				return nil, false
+			}
+			key := v.Keys[index]
+			index++
+			return &List{Elements: []Value{key, v.Values[key]}}, true
+		}}
+	case *Range:
+		next := v.Start
+		return &Iterator{next: func() (Value, bool) {
+			if (v.Step > 0 && next >= v.Stop) || (v.Step < 0 && next <= v.Stop) {
+				return nil, false
+			}
+			value := next
+			next += v.Step
+			return value, true
+		}}
+	case *Generator:
+		return &Iterator{next: func() (Value, bool) {
+			return v.next(t, line)
+		}}
+	case *Channel:
+		return &Iterator{next: func() (Value, bool) {
+			tasks.mutex.Lock()
+			defer tasks.mutex.Unlock()
+			return v.receive(line)
+		}}
+	case string:
+		offset := 0
+		return &Iterator{next: func() (Value, bool) {
+			if offset >= len(v) {
+				return nil, false
+			}
+			_, width := utf8.DecodeRuneInString(v[offset:])
+			offset += width
+			return v[offset-width : offset], true
+		}}
+	case *Function:
+		if v.generator {
+			fail(line, "Can't iterate over a generator function, call it to create a generator")
+		}
+		return callableIterator(t, line, v)
+	case Callable:
+		return callableIterator(t, line, v)
+	}
+	fail(line, "Can only iterate over strings, lists, maps, ranges and iterator functions")
+	return nil
+}
+
+func callableIterator(t *Thread, line int, callable Callable) *Iterator {
+	if minArity, _ := callable.Arity(); minArity != 0 {
+		fail(line, "Can only iterate over strings, lists, maps, ranges and iterator functions")
+	}
+	return &Iterator{next: func() (Value, bool) {
+		value := callable.Call(t, line, []Value{}, nil)
+		return value, value != nil
+	}}
+}
+
+// Range is the lazy arithmetic sequence returned by the `range` native.
+type Range struct {
+	Start float64
+	Stop  float64
+	Step  float64
+}
+
+func (r *Range) String() string {
+	return "range(" + Stringify(r.Start) + ", " + Stringify(r.Stop) + ", " + Stringify(r.Step) + ")"
+}
+
+// rangeNative is `range(stop)`, `range(start, stop)` or `range(start, stop, step)`.
+func rangeNative(t *Thread, line int, arguments []Value) Value {
+	bounds := make([]float64, 0, len(arguments))
+	for index, argument := range arguments {
+		v, ok := argument.(float64)
+		if !ok || math.IsNaN(v) {
+			fail(line, "range() argument "+strconv.Itoa(index+1)+" must be a number")
+		}
+		bounds = append(bounds, v)
+	}
+	r := &Range{Start: 0, Step: 1}
+	switch len(bounds) {
+	case 1:
+		r.Stop = bounds[0]
+	case 2:
+		r.Start, r.Stop = bounds[0], bounds[1]
+	case 3:
+		r.Start, r.Stop, r.Step = bounds[0], bounds[1], bounds[2]
+	}
+	if r.Step == 0 {
+		fail(line, "range() step can't be zero")
+	}
+	return r
+}
@@ -0,0 +1,46 @@
+package loxrt
+
+import "strings"
+
+// Map is the value of a map literal, its keys in the order they were first written in.
+type Map struct {
+	Keys   []Value
+	Values map[Value]Value
+}
+
+// NewMap builds the map of a literal at line from its keys and values, alternating.
+func NewMap(line int, entries ...Value) *Map {
+	m := &Map{Keys: make([]Value, 0, len(entries)/2), Values: make(map[Value]Value, len(entries)/2)}
+	for index := 0; index < len(entries); index += 2 {
+		key := mapKey(entries[index])
+		switch key.(type) {
+		case nil, bool, float64, string:
+		default:
+			fail(line, "Map keys must be nil, booleans, numbers or strings, got "+TypeName(key)+".")
+		}
+		if _, ok := m.Values[key]; !ok {
+			m.Keys = append(m.Keys, key)
+		}
+		m.Values[key] = entries[index+1]
+	}
+	return m
+}
+
+func (m *Map) String() string {
+	entries := make([]string, 0, len(m.Keys))
+	for _, key := range m.Keys {
+		entries = append(entries, repr(key)+": "+repr(m.Values[key]))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// mapKey converts the numbers which aren't float64 so that equal numbers are the same key.
+func mapKey(key Value) Value {
+	switch v := key.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return key
+}
@@ -0,0 +1,102 @@
+package loxrt
+
+// Pattern is the left-hand side of a `case` in a match statement.
+type Pattern interface {
+	match(value Value, bindings map[string]Value) bool
+}
+
+// LiteralPattern matches values equal to a number, string, boolean or nil.
+type LiteralPattern struct {
+	Value Value
+}
+
+func (p LiteralPattern) match(value Value, _ map[string]Value) bool {
+	return p.Value == value
+}
+
+// BindingPattern matches any value and binds it to Name.
+type BindingPattern struct {
+	Name string
+}
+
+func (p BindingPattern) match(value Value, bindings map[string]Value) bool {
+	bindings[p.Name] = value
+	return true
+}
+
+// WildcardPattern is `_`.
+type WildcardPattern struct{}
+
+func (WildcardPattern) match(Value, map[string]Value) bool {
+	return true
+}
+
+// ListPattern matches a list element by element. Without a Rest the lengths must be equal, with a `...rest` the
+// remaining elements are bound to Rest as a list.
+type ListPattern struct {
+	Elements []Pattern
+	Rest     string
+	HasRest  bool
+}
+
+func (p ListPattern) match(value Value, bindings map[string]Value) bool {
+	list, ok := value.(*List)
+	if !ok {
+		return false
+	}
+	if len(list.Elements) < len(p.Elements) || (!p.HasRest && len(list.Elements) != len(p.Elements)) {
+		return false
+	}
+	for index, element := range p.Elements {
+		if !element.match(list.Elements[index], bindings) {
+			return false
+		}
+	}
+	if p.HasRest {
+		rest := make([]Value, len(list.Elements)-len(p.Elements))
+		copy(rest, list.Elements[len(p.Elements):])
+		bindings[p.Rest] = &List{Elements: rest}
+	}
+	return true
+}
+
+// MapPattern matches a map holding every key of Keys with a value matching the pattern at the same index of Values.
+type MapPattern struct {
+	Keys   []Value
+	Values []Pattern
+}
+
+func (p MapPattern) match(value Value, bindings map[string]Value) bool {
+	m, ok := value.(*Map)
+	if !ok {
+		return false
+	}
+	for index, key := range p.Keys {
+		element, ok := m.Values[key]
+		if !ok || !p.Values[index].match(element, bindings) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchCase reports whether subject matches one of the patterns of a case. For every pattern which matches, bind
+// is called with the variables it bound and decides whether the case applies; it assigns the case's variables and
+// evaluates its guard. bind is nil for a case which binds nothing and has no guard.
+func MatchCase(subject Value, patterns []Pattern, bind func(bindings map[string]Value) bool) bool {
+	for _, pattern := range patterns {
+		bindings := make(map[string]Value)
+		if !pattern.match(subject, bindings) {
+			continue
+		}
+		if bind == nil || bind(bindings) {
+			return true
+		}
+	}
+	return false
+}
+
+// NonExhaustive fails a match statement at line whose subject no case matched.
+func NonExhaustive(line int, subject Value) {
+	fail(line, "Non-exhaustive match: no case matches "+Stringify(subject))
+}
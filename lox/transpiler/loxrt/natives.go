@@ -0,0 +1,379 @@
+package loxrt
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Native is a function implemented by the runtime.
+type Native struct {
+	minArity int
+	maxArity int
+	call     func(t *Thread, line int, arguments []Value) Value
+}
+
+func (n *Native) Arity() (int, int) {
+	return n.minArity, n.maxArity
+}
+
+func (n *Native) Call(t *Thread, line int, arguments []Value, _ map[string]Value) Value {
+	return n.call(t, line, arguments)
+}
+
+func (n *Native) String() string {
+	return "<native fn>"
+}
+
+// natives are the globals every program starts with.
+var natives = map[string]Value{
+	"clock":      &Native{0, 0, clock},
+	"range":      &Native{1, 3, rangeNative},
+	"wait":       &Native{1, VariadicArity, wait},
+	"channel":    &Native{0, 1, channel},
+	"send":       &Native{2, 2, send},
+	"receive":    &Native{1, 1, receive},
+	"close":      &Native{1, 1, closeNative},
+	"select":     &Native{1, VariadicArity, selectNative},
+	"readLine":   &Native{0, 0, readLine},
+	"type":       &Native{1, 1, typeNative},
+	"arity":      &Native{1, 1, arity},
+	"name":       &Native{1, 1, name},
+	"isCallable": &Native{1, 1, isCallable},
+	"locals":     &Native{0, 0, localsNative},
+	"source":     &Native{1, 1, source},
+}
+
+// nativeNames maps the natives to their names.
+var nativeNames = make(map[*Native]string)
+
+func init() {
+	// globals lists the natives, so it can't be in their initializer
+	natives["globals"] = &Native{0, 0, globals}
+	for name, native := range natives {
+		nativeNames[native.(*Native)] = name
+	}
+}
+
+// Global is a global variable. Globals are looked up by name when the program runs, so like in the interpreter a
+// function may use a global declared after it, and reading one which isn't defined yet is a runtime error.
+type Global struct {
+	mutex    sync.RWMutex
+	name     string
+	defined  bool
+	constant bool
+	value    Value
+}
+
+// allGlobals are the global variables created by NewGlobal, for the `globals` native.
+var allGlobals = struct {
+	mutex sync.Mutex
+	list  []*Global
+}{}
+
+// NewGlobal returns the global variable name, which is already defined if it is a native.
+func NewGlobal(name string) *Global {
+	value, defined := natives[name]
+	global := &Global{name: name, defined: defined, value: value}
+	allGlobals.mutex.Lock()
+	defer allGlobals.mutex.Unlock()
+	allGlobals.list = append(allGlobals.list, global)
+	return global
+}
+
+// Get reads the global. locals are the names of the local variables in scope, which an error suggests along with the
+// defined globals.
+func (g *Global) Get(line int, locals ...string) Value {
+	g.mutex.RLock()
+	value, defined := g.value, g.defined
+	g.mutex.RUnlock()
+	if !defined {
+		g.undefined(line, true, locals)
+	}
+	return value
+}
+
+func (g *Global) Define(value Value) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.value, g.defined, g.constant = value, true, false
+}
+
+// DefineConstant defines the global as a binding Assign refuses to overwrite.
+func (g *Global) DefineConstant(value Value) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.value, g.defined, g.constant = value, true, true
+}
+
+func (g *Global) Assign(line int, value Value, locals ...string) Value {
+	g.mutex.Lock()
+	defined, constant := g.defined, g.constant
+	if defined && !constant {
+		g.value = value
+	}
+	g.mutex.Unlock()
+	if !defined {
+		g.undefined(line, false, locals)
+	}
+	if constant {
+		fail(line, "Cannot assign to constant '"+g.name+"'")
+	}
+	return value
+}
+
+// undefined fails because the global isn't defined, suggesting the closest defined global or local, or keyword when
+// keywords is set, like the interpreter does.
+func (g *Global) undefined(line int, keywords bool, locals []string) {
+	candidates := locals
+	for name := range definedGlobals() {
+		candidates = append(candidates, name)
+	}
+	if keywords {
+		candidates = append(candidates, ExpressionKeywords...)
+	}
+	reason := "Undefined variable '" + g.name + "'."
+	if suggestion := closest(g.name, candidates); suggestion != "" {
+		reason += " Did you mean '" + suggestion + "'?"
+	}
+	fail(line, reason)
+}
+
+// definedGlobals returns the values of the defined global variables, natives included.
+func definedGlobals() map[string]Value {
+	bindings := make(map[string]Value, len(natives))
+	for name, native := range natives {
+		bindings[name] = native
+	}
+	allGlobals.mutex.Lock()
+	list := allGlobals.list
+	allGlobals.mutex.Unlock()
+	for _, global := range list {
+		global.mutex.RLock()
+		if global.defined {
+			bindings[global.name] = global.value
+		}
+		global.mutex.RUnlock()
+	}
+	return bindings
+}
+
+// Update is `++` or `--` on the global, delta being 1 or -1. The variable is looked up at line, the operator is at
+// operatorLine.
+func (g *Global) Update(line int, operatorLine int, delta float64, prefix bool, locals ...string) Value {
+	old := g.Get(line, locals...)
+	value := g.Assign(line, increment(old, operatorLine, delta), locals...)
+	if prefix {
+		return value
+	}
+	return old
+}
+
+func clock(_ *Thread, _ int, _ []Value) Value {
+	return float64(time.Now().UnixMicro())
+}
+
+// Task is the handle returned by `spawn`. Finished is guarded by the mutex of the scheduler.
+type Task struct {
+	name     string
+	finished bool
+	value    Value
+	failure  interface{}
+}
+
+func (t *Task) String() string {
+	if t.name == "" {
+		return "<task>"
+	}
+	return "<task " + t.name + ">"
+}
+
+// Spawn is `spawn callee(arguments)`: the arguments are evaluated by the caller, the call runs on a new task and
+// the expression yields its handle. An error of the call is raised again by wait().
+func Spawn(t *Thread, line int, callee Value, arguments []Argument) Value {
+	positional, named := expand(arguments)
+	task := &Task{}
+	if v, ok := callee.(*Function); ok {
+		task.name = v.name
+	}
+	atomic.StoreInt32(&concurrent, 1)
+	tasks.start()
+	go func() {
+		defer tasks.finish(task)
+		defer func() {
+			task.failure = recover()
+		}()
+		task.value = call(&Thread{}, line, callee, positional, named)
+	}()
+	return task
+}
+
+// wait is the `wait(task, ...)` native. It returns the result of a single task, or the list of results when given
+// several.
+func wait(_ *Thread, line int, arguments []Value) Value {
+	results := make([]Value, 0, len(arguments))
+	tasks.mutex.Lock()
+	defer tasks.mutex.Unlock()
+	for index, argument := range arguments {
+		task, ok := argument.(*Task)
+		if !ok {
+			fail(line, "wait() argument "+strconv.Itoa(index+1)+" must be a task")
+		}
+		tasks.block(line, func() bool {
+			return task.finished
+		})
+		if task.failure != nil {
+			panic(task.failure)
+		}
+		results = append(results, task.value)
+	}
+	if len(results) == 1 {
+		return results[0]
+	}
+	return &List{Elements: results}
+}
+
+// Channel is a channel created by the `channel` native. It carries values of any type. Its state is guarded by the
+// mutex of the scheduler.
+type Channel struct {
+	buffer   []Value
+	capacity int
+	closed   bool
+	sent     int
+	received int
+}
+
+func (c *Channel) String() string {
+	return "<channel>"
+}
+
+func (c *Channel) ready() bool {
+	return len(c.buffer) > 0 || c.closed
+}
+
+// receive waits for a value, ok is false once the channel is closed and drained. It is called with the mutex of the
+// scheduler held.
+func (c *Channel) receive(line int) (value Value, ok bool) {
+	tasks.block(line, c.ready)
+	if len(c.buffer) == 0 {
+		return nil, false
+	}
+	value, c.buffer = c.buffer[0], c.buffer[1:]
+	c.received++
+	tasks.wake()
+	return value, true
+}
+
+func channelArgument(line int, name string, argument Value) *Channel {
+	channel, ok := argument.(*Channel)
+	if !ok {
+		fail(line, name+"() expects a channel")
+	}
+	return channel
+}
+
+func channel(_ *Thread, line int, arguments []Value) Value {
+	capacity := 0.0
+	if len(arguments) == 1 {
+		v, ok := arguments[0].(float64)
+		if !ok || v < 0 || v != float64(int(v)) {
+			fail(line, "channel() capacity must be a non-negative integer")
+		}
+		capacity = v
+	}
+	return &Channel{capacity: int(capacity)}
+}
+
+func send(_ *Thread, line int, arguments []Value) Value {
+	channel := channelArgument(line, "send", arguments[0])
+	tasks.mutex.Lock()
+	defer tasks.mutex.Unlock()
+	if channel.capacity > 0 {
+		tasks.block(line, func() bool {
+			return len(channel.buffer) < channel.capacity || channel.closed
+		})
+	}
+	if channel.closed {
+		fail(line, "send() on a closed channel")
+	}
+	channel.buffer = append(channel.buffer, arguments[1])
+	channel.sent++
+	tasks.wake()
+	if channel.capacity > 0 {
+		return nil
+	}
+	sent := channel.sent
+	tasks.block(line, func() bool {
+		return channel.received >= sent || channel.closed
+	})
+	if channel.received < sent {
+		fail(line, "send() on a closed channel")
+	}
+	return nil
+}
+
+func receive(_ *Thread, line int, arguments []Value) Value {
+	channel := channelArgument(line, "receive", arguments[0])
+	tasks.mutex.Lock()
+	defer tasks.mutex.Unlock()
+	value, _ := channel.receive(line)
+	return value
+}
+
+func closeNative(_ *Thread, line int, arguments []Value) Value {
+	channel := channelArgument(line, "close", arguments[0])
+	tasks.mutex.Lock()
+	defer tasks.mutex.Unlock()
+	if channel.closed {
+		fail(line, "close() of a closed channel")
+	}
+	channel.closed = true
+	if channel.capacity == 0 {
+		channel.buffer = nil
+	}
+	tasks.wake()
+	return nil
+}
+
+// selectNative waits until one of the channels can be received from, the first in the order of the arguments when
+// several can, and returns the list [channel, value]; value is nil when that channel was closed.
+func selectNative(_ *Thread, line int, arguments []Value) Value {
+	channels := make([]*Channel, 0, len(arguments))
+	for index, argument := range arguments {
+		channel, ok := argument.(*Channel)
+		if !ok {
+			fail(line, "select() argument "+strconv.Itoa(index+1)+" must be a channel")
+		}
+		channels = append(channels, channel)
+	}
+	tasks.mutex.Lock()
+	defer tasks.mutex.Unlock()
+	var chosen *Channel
+	tasks.block(line, func() bool {
+		for _, channel := range channels {
+			if channel.ready() {
+				chosen = channel
+				return true
+			}
+		}
+		return false
+	})
+	value, _ := chosen.receive(line)
+	return &List{Elements: []Value{chosen, value}}
+}
+
+// readLine returns the next line of the standard input without its line terminator, or nil at the end of the input.
+func readLine(_ *Thread, _ int, _ []Value) Value {
+	input.mutex.Lock()
+	defer input.mutex.Unlock()
+	output.mutex.Lock()
+	_ = output.writer.Flush()
+	output.mutex.Unlock()
+	line, err := input.reader.ReadString('\n')
+	if err != nil && line == "" {
+		return nil
+	}
+	return strings.TrimRight(line, "\r\n")
+}
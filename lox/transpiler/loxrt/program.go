@@ -0,0 +1,93 @@
+package loxrt
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+)
+
+// output buffers what print writes. It is flushed when the program ends, before a runtime error is reported and
+// before reading the input, so a prompt shows up before the program waits for an answer.
+var output = struct {
+	mutex  sync.Mutex
+	writer *bufio.Writer
+}{writer: bufio.NewWriter(os.Stdout)}
+
+// input is read by readLine, under its own mutex so that a task waiting for input doesn't hold up the output of
+// the others.
+var input = struct {
+	mutex  sync.Mutex
+	reader *bufio.Reader
+}{reader: bufio.NewReader(os.Stdin)}
+
+// Main runs the program and exits: with status 70 after reporting a runtime error, 0 otherwise.
+func Main(program func(t *Thread)) {
+	code := run(program)
+	output.mutex.Lock()
+	_ = output.writer.Flush()
+	output.mutex.Unlock()
+	os.Exit(code)
+}
+
+func run(program func(t *Thread)) (code int) {
+	defer func() {
+		failure := recover()
+		if failure == nil {
+			return
+		}
+		err, ok := failure.(*Error)
+		if !ok {
+			panic(failure)
+		}
+		output.mutex.Lock()
+		defer output.mutex.Unlock()
+		_ = output.writer.Flush()
+		_, _ = fmt.Fprintln(os.Stderr, err.Error())
+		code = 70
+	}()
+	program(&Thread{})
+	return 0
+}
+
+// Fail raises a runtime error at line.
+func Fail(line int, reason string) Value {
+	fail(line, reason)
+	return nil
+}
+
+// NegativeZero is -0, which Go constant expressions can't produce.
+func NegativeZero() Value {
+	return math.Copysign(0, -1)
+}
+
+func Print(value Value) {
+	text := Stringify(value)
+	output.mutex.Lock()
+	defer output.mutex.Unlock()
+	_, _ = output.writer.WriteString(text)
+	_ = output.writer.WriteByte('\n')
+}
+
+// Assert is `assert condition, message;`, source being the condition as written. message is nil when the assertion
+// has none, it is only evaluated when the assertion fails.
+func Assert(line int, source string, condition Value, message func() Value) {
+	if !Truthy(condition) {
+		assertionFailed(line, "Assertion failed: "+source, message)
+	}
+}
+
+// AssertBinary is Assert for a condition which is a binary operator, so a failure can show its operands.
+func AssertBinary(line int, source string, operatorLine int, operator string, left Value, right Value, message func() Value) {
+	if !Truthy(Binary(operatorLine, operator, left, right)) {
+		assertionFailed(line, "Assertion failed: "+source+" (left: "+repr(left)+", right: "+repr(right)+")", message)
+	}
+}
+
+func assertionFailed(line int, reason string, message func() Value) {
+	if message != nil {
+		reason += ": " + Stringify(message())
+	}
+	fail(line, reason)
+}
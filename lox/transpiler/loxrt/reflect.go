@@ -0,0 +1,84 @@
+package loxrt
+
+import "sort"
+
+func callableArgument(line int, name string, argument Value) Callable {
+	callable, ok := argument.(Callable)
+	if !ok {
+		fail(line, name+"() expects a function")
+	}
+	return callable
+}
+
+// typeNative returns the name of the type of its argument as written in type annotations.
+func typeNative(_ *Thread, _ int, arguments []Value) Value {
+	return TypeName(arguments[0])
+}
+
+// arity returns the number of positional arguments a function requires.
+func arity(_ *Thread, line int, arguments []Value) Value {
+	minArity, _ := callableArgument(line, "arity", arguments[0]).Arity()
+	return float64(minArity)
+}
+
+// name returns the name a function was declared with, or defined as for a native, and nil for an anonymous one.
+func name(_ *Thread, line int, arguments []Value) Value {
+	var name string
+	switch v := callableArgument(line, "name", arguments[0]).(type) {
+	case *Function:
+		name = v.name
+	case *Native:
+		name = nativeNames[v]
+	}
+	if name == "" {
+		return nil
+	}
+	return name
+}
+
+func isCallable(_ *Thread, _ int, arguments []Value) Value {
+	_, ok := arguments[0].(Callable)
+	return ok
+}
+
+// globals returns the defined global variables, natives included, as a list of [name, value] pairs ordered by name.
+func globals(_ *Thread, _ int, _ []Value) Value {
+	return bindingList(definedGlobals())
+}
+
+// localsNative returns no variables: the local variables only exist in the generated code, which passes them to
+// CallLocals where it may call the `locals` native.
+func localsNative(_ *Thread, _ int, _ []Value) Value {
+	return bindingList(nil)
+}
+
+// CallLocals is a call with positional arguments which may reach the `locals` native, scope holding the local
+// variables visible at the call. It returns them when callee is the native, and calls callee otherwise.
+func CallLocals(t *Thread, line int, callee Value, scope map[string]Value, arguments ...Value) Value {
+	if native, ok := callee.(*Native); ok && nativeNames[native] == "locals" && len(arguments) == 0 {
+		return bindingList(scope)
+	}
+	return call(t, line, callee, arguments, nil)
+}
+
+// source returns the declaration of a function, and nil for a native.
+func source(_ *Thread, line int, arguments []Value) Value {
+	if function, ok := callableArgument(line, "source", arguments[0]).(*Function); ok {
+		return function.source
+	}
+	return nil
+}
+
+// bindingList turns bindings into a list of [name, value] pairs ordered by name.
+func bindingList(bindings map[string]Value) *List {
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]Value, len(names))
+	for index, name := range names {
+		pairs[index] = &List{Elements: []Value{name, bindings[name]}}
+	}
+	return &List{Elements: pairs}
+}
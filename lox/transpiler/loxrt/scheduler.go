@@ -0,0 +1,56 @@
+package loxrt
+
+import "sync"
+
+// scheduler lets the tasks of the program wait for channels and for each other like the interpreter's does: the
+// last task to fall asleep finds the deadlock and fails instead.
+type scheduler struct {
+	mutex    sync.Mutex
+	changed  *sync.Cond
+	running  int
+	asleep   int
+	deadlock bool
+}
+
+var tasks = newScheduler()
+
+func newScheduler() *scheduler {
+	s := &scheduler{running: 1}
+	s.changed = sync.NewCond(&s.mutex)
+	return s
+}
+
+// block waits until ready returns true, with the mutex held. It fails at line on a deadlock, leaving the mutex to
+// the deferred unlock of the caller.
+func (s *scheduler) block(line int, ready func() bool) {
+	for !ready() {
+		if s.deadlock || s.running == 1 {
+			s.deadlock = true
+			s.wake()
+			fail(line, "Deadlock: all tasks are waiting")
+		}
+		s.running--
+		s.asleep++
+		s.changed.Wait()
+	}
+}
+
+func (s *scheduler) wake() {
+	s.running += s.asleep
+	s.asleep = 0
+	s.changed.Broadcast()
+}
+
+func (s *scheduler) start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.running++
+}
+
+func (s *scheduler) finish(task *Task) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	task.finished = true
+	s.running--
+	s.wake()
+}
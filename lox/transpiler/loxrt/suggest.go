@@ -0,0 +1,54 @@
+package loxrt
+
+// ExpressionKeywords are the keywords which can start an expression in alphabetical order, the same as
+// golox/lox/suggest.ExpressionKeywords.
+var ExpressionKeywords = []string{"false", "fun", "nil", "spawn", "true"}
+
+// closest and distance are golox/lox/suggest.Closest and Distance.
+func closest(name string, candidates []string) string {
+	best, bestDistance := "", len(name)/3+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		distance := distance(name, candidate)
+		if distance < bestDistance || (distance == bestDistance && best != "" && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+func distance(a string, b string) int {
+	s, t := []rune(a), []rune(b)
+	rows := [3][]int{make([]int, len(t)+1), make([]int, len(t)+1), make([]int, len(t)+1)}
+	for j := range rows[2] {
+		rows[2][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		rows[0], rows[1], rows[2] = rows[1], rows[2], rows[0]
+		current, previous, beforePrevious := rows[2], rows[1], rows[0]
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = minimum(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				current[j] = minimum(current[j], beforePrevious[j-2]+1)
+			}
+		}
+	}
+	return rows[2][len(t)]
+}
+
+func minimum(values ...int) int {
+	result := values[0]
+	for _, value := range values[1:] {
+		if value < result {
+			result = value
+		}
+	}
+	return result
+}
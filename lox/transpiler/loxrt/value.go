@@ -0,0 +1,255 @@
+// Package loxrt is the runtime of the Go programs golox transpiles Lox scripts to. It holds what the generated code
+// doesn't spell out itself: dynamic values and their operators, functions and calls, generators, tasks, channels and
+// the natives. Everything here behaves like the tree-walking interpreter in golox/lox/interpreter, down to the error
+// messages, so that a transpiled script prints exactly what the interpreted one does.
+//
+// The transpiler copies this package next to the generated program, so it must only depend on the standard library.
+package loxrt
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value is a Lox value: nil, bool, float64, string or one of the pointer types of this package.
+type Value = interface{}
+
+// Error is a runtime error. Operations fail by panicking with an *Error, Main reports it and exits.
+type Error struct {
+	Line   int
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "[line " + strconv.Itoa(e.Line) + "] Error: " + e.Reason
+}
+
+func fail(line int, reason string) {
+	panic(&Error{Line: line, Reason: reason})
+}
+
+// Inf and NaN are the numbers the transpiler can't write as literals.
+func Inf(sign int) Value {
+	return math.Inf(sign)
+}
+
+func NaN() Value {
+	return math.NaN()
+}
+
+// Truthy tells whether a condition with this value holds: everything but nil and false does.
+func Truthy(value Value) bool {
+	if value == nil {
+		return false
+	}
+	if v, ok := value.(bool); ok {
+		return v
+	}
+	return true
+}
+
+func increment(old Value, line int, delta float64) Value {
+	number, ok := old.(float64)
+	if !ok {
+		fail(line, "Operand must be a number.")
+	}
+	return number + delta
+}
+
+func Not(value Value) Value {
+	return !Truthy(value)
+}
+
+func Negate(line int, value Value) Value {
+	number, ok := value.(float64)
+	if !ok {
+		fail(line, "Operand must be a number.")
+	}
+	return -number
+}
+
+// And and Or evaluate their right operand only when the left one doesn't decide the result.
+func And(left Value, right func() Value) Value {
+	if !Truthy(left) {
+		return left
+	}
+	return right()
+}
+
+func Or(left Value, right func() Value) Value {
+	if Truthy(left) {
+		return left
+	}
+	return right()
+}
+
+// Cond is the conditional operator `condition ? then : otherwise`.
+func Cond(condition Value, then func() Value, otherwise func() Value) Value {
+	if Truthy(condition) {
+		return then()
+	}
+	return otherwise()
+}
+
+func number(value Value) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func numbers(line int, left Value, right Value) (float64, float64) {
+	a, okLeft := number(left)
+	b, okRight := number(right)
+	if !okLeft || !okRight {
+		fail(line, "Operands must be numbers.")
+	}
+	return a, b
+}
+
+// Binary applies a binary operator, written as in Lox, to two evaluated operands.
+func Binary(line int, operator string, left Value, right Value) Value {
+	switch operator {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "+":
+		return add(line, left, right)
+	}
+	a, b := numbers(line, left, right)
+	switch operator {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		return a / b
+	case "%":
+		return math.Mod(a, b)
+	}
+	panic("loxrt: unknown operator " + operator)
+}
+
+// add is `+`: it adds numbers and concatenates strings, also with a number on either side.
+func add(line int, left Value, right Value) Value {
+	leftString, leftIsString := left.(string)
+	rightString, rightIsString := right.(string)
+	leftNumber, leftIsNumber := number(left)
+	rightNumber, rightIsNumber := number(right)
+	switch {
+	case leftIsString && rightIsString:
+		return leftString + rightString
+	case leftIsString && rightIsNumber:
+		return leftString + strconv.FormatFloat(rightNumber, 'f', -1, 64)
+	case leftIsNumber && rightIsString:
+		return strconv.FormatFloat(leftNumber, 'f', -1, 64) + rightString
+	case leftIsNumber && rightIsNumber:
+		return leftNumber + rightNumber
+	}
+	fail(line, "Operands must be two numbers or two strings.")
+	return nil
+}
+
+// Stringify formats a value the way print does.
+func Stringify(value Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		return strings.TrimSuffix(strconv.FormatFloat(v, 'f', -1, 64), ".0")
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// repr is Stringify with strings quoted, so that "1" and 1 can be told apart.
+func repr(value Value) string {
+	if v, ok := value.(string); ok {
+		return strconv.Quote(v)
+	}
+	return Stringify(value)
+}
+
+// List is a list value, created for instance by a rest parameter.
+type List struct {
+	Elements []Value
+}
+
+func (l *List) String() string {
+	elements := make([]string, 0, len(l.Elements))
+	for _, element := range l.Elements {
+		elements = append(elements, repr(element))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// TypeName returns the name of the type of a value, as written in type annotations.
+func TypeName(value Value) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case float64, int, int64:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case *List:
+		return "list"
+	case *Map:
+		return "map"
+	case *Range:
+		return "range"
+	case *Generator:
+		return "generator"
+	case *Task:
+		return "task"
+	case *Channel:
+		return "channel"
+	case Callable:
+		return "function"
+	}
+	return "any"
+}
+
+// hasType reports whether value has one of the types of an annotation, written like `number | nil`.
+func hasType(value Value, annotation string) bool {
+	name := TypeName(value)
+	for _, typeName := range strings.Split(annotation, " | ") {
+		if typeName == "any" || typeName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckType is the runtime check the type checker inserts where a value of unknown type meets an annotation.
+func CheckType(line int, annotation string, value Value) Value {
+	if !hasType(value, annotation) {
+		fail(line, "Expected "+annotation+" but got "+TypeName(value)+".")
+	}
+	return value
+}
@@ -0,0 +1,762 @@
+// Package transpiler translates a resolved Lox program into a self-contained Go program. The generated code keeps
+// the structure of the source: Lox locals become Go variables captured by Go closures, loops become Go loops, and
+// what Go can't express directly, like dynamic values, calls and generators, is left to the runtime package loxrt,
+// which is shipped with the generated program. The result prints and fails exactly like the interpreter.
+package transpiler
+
+import (
+	"embed"
+	"go/format"
+	"golox/lox/ast"
+	"golox/lox/lexer"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed loxrt/*.go
+var runtimeFiles embed.FS
+
+// Options configure the generated program.
+type Options struct {
+	// Runtime is the import path of the loxrt package in the module of the generated program.
+	Runtime string
+	// Source names the script in the header of the generated file.
+	Source string
+	// Package is the name of the generated package, main when empty. Other packages export the program as Program
+	// instead of declaring main, so that several programs can be linked into one binary.
+	Package string
+	// ConstantFunctions makes global function declarations immutable, see Interpreter.SetConstantFunctions.
+	ConstantFunctions bool
+}
+
+// Transpile returns the formatted source of the package running statements, which must have passed the resolver and
+// the type checker.
+func Transpile(statements []ast.Stmt, options Options) ([]byte, error) {
+	t := &Transpiler{options: options, out: &strings.Builder{}, declared: make(map[string]bool), nameLists: make(map[string]string)}
+	t.reflective = reachesLocals(statements)
+	for _, statement := range statements {
+		t.stmt(statement)
+	}
+	var source strings.Builder
+	source.WriteString("// Code generated by golox transpile")
+	if options.Source != "" {
+		source.WriteString(" from " + options.Source)
+	}
+	pkg, program := options.Package, "Program"
+	if pkg == "" || pkg == "main" {
+		pkg, program = "main", "program"
+	}
+	source.WriteString(". DO NOT EDIT.\n\npackage " + pkg + "\n\nimport rt " + strconv.Quote(options.Runtime) + "\n\n")
+	for _, name := range t.globals {
+		source.WriteString("var g_" + name + " = rt.NewGlobal(" + strconv.Quote(name) + ")\n")
+	}
+	source.WriteString(t.declarations.String())
+	if pkg == "main" {
+		source.WriteString("\nfunc main() {\nrt.Main(program)\n}\n")
+	}
+	source.WriteString("\nfunc " + program + "(t *rt.Thread) {\n")
+	source.WriteString(t.out.String())
+	source.WriteString("}\n")
+	return format.Source([]byte(source.String()))
+}
+
+// WriteRuntime writes the files of the loxrt package to dir.
+func WriteRuntime(dir string) error {
+	entries, err := runtimeFiles.ReadDir("loxrt")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		content, err := runtimeFiles.ReadFile("loxrt/" + entry.Name())
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteModule writes a Go module named module to dir, holding the program transpiled from statements in main.go and
+// the runtime in loxrt/. `go build` in dir then builds the program.
+func WriteModule(dir string, module string, statements []ast.Stmt, options Options) error {
+	options.Runtime = module + "/loxrt"
+	source, err := Transpile(statements, options)
+	if err != nil {
+		return err
+	}
+	if err := WriteRuntime(filepath.Join(dir, "loxrt")); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module "+module+"\n\ngo 1.19\n"), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "main.go"), source, 0o644)
+}
+
+type functionKind int
+
+const (
+	topLevel functionKind = iota
+	function
+	generator
+)
+
+// Transpiler generates the Go code of statements as a visitor: expressions return their Go expression, statements
+// write their Go statements to out.
+type Transpiler struct {
+	options      Options
+	out          *strings.Builder
+	declarations strings.Builder     // package-level variables holding parameter and pattern descriptions and local names
+	nameLists    map[string]string   // the variables of the lists of local names declared, by their content
+	globals      []string            // the global variables used by the program, in order of first use
+	declared     map[string]bool     // the names in globals
+	scopes       []map[string]string // Lox names of the enclosing local scopes mapped to their Go variables
+	kind         functionKind        // the kind of the function being generated
+	counter      int                 // numbers the generated names
+	reflective   bool                // every call passes the locals in scope, see reachesLocals
+}
+
+// reachesLocals tells whether statements may get hold of the `locals` native other than by calling it by name, like
+// `var l = locals;` or through the list returned by `globals`. Any call may then reach the native, so every call
+// passes the locals in scope. Locals shadowing the natives count too, which only costs speed.
+func reachesLocals(statements []ast.Stmt) bool {
+	reaches := false
+	var inspect func(node ast.Node) bool
+	inspect = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Call:
+			if variable, ok := node.Callee.(*ast.Variable); ok && variable.Name.Lexeme == "locals" {
+				for _, argument := range node.Arguments {
+					ast.Inspect(argument, inspect)
+				}
+				return false
+			}
+		case *ast.Variable:
+			if node.Name.Lexeme == "locals" || node.Name.Lexeme == "globals" {
+				reaches = true
+			}
+		}
+		return !reaches
+	}
+	for _, statement := range statements {
+		ast.Inspect(statement, inspect)
+	}
+	return reaches
+}
+
+func (t *Transpiler) stmt(stmt ast.Stmt) {
+	_, _ = stmt.Accept(t)
+}
+
+func (t *Transpiler) expr(expr ast.Expr) string {
+	code, _ := expr.Accept(t)
+	return code.(string)
+}
+
+func (t *Transpiler) write(lines ...string) {
+	for _, line := range lines {
+		t.out.WriteString(line)
+		t.out.WriteString("\n")
+	}
+}
+
+func (t *Transpiler) next() string {
+	t.counter++
+	return strconv.Itoa(t.counter)
+}
+
+func (t *Transpiler) beginScope() {
+	t.scopes = append(t.scopes, make(map[string]string))
+}
+
+func (t *Transpiler) endScope() {
+	t.scopes = t.scopes[:len(t.scopes)-1]
+}
+
+// declare declares a Lox variable in the current scope and returns its Go variable, or "" for a global.
+func (t *Transpiler) declare(name string) string {
+	if len(t.scopes) == 0 {
+		t.global(name)
+		return ""
+	}
+	variable := "l_" + name + "_" + t.next()
+	t.scopes[len(t.scopes)-1][name] = variable
+	return variable
+}
+
+// lookup returns the Go variable of a local, or "" when name refers to a global, like the resolver decides.
+func (t *Transpiler) lookup(name string) string {
+	for index := len(t.scopes) - 1; index >= 0; index-- {
+		if variable, ok := t.scopes[index][name]; ok {
+			return variable
+		}
+	}
+	t.global(name)
+	return ""
+}
+
+// localNames generates the trailing argument of the methods of rt.Global passing the names of the Lox locals in
+// scope, which errors about undefined globals suggest; it is empty at the top level.
+func (t *Transpiler) localNames() string {
+	names := make(map[string]bool)
+	for _, scope := range t.scopes {
+		for name := range scope {
+			names[name] = true
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, strconv.Quote(name))
+	}
+	sort.Strings(sorted)
+	list := strings.Join(sorted, ", ")
+	variable, ok := t.nameLists[list]
+	if !ok {
+		variable = "names_" + t.next()
+		t.nameLists[list] = variable
+		t.declarations.WriteString("var " + variable + " = []string{" + list + "}\n")
+	}
+	return ", " + variable + "..."
+}
+
+// scope generates a map of the Lox locals visible at this point of the program to their values, for `locals()`.
+func (t *Transpiler) scope() string {
+	variables := make(map[string]string)
+	for index := len(t.scopes) - 1; index >= 0; index-- {
+		for name, variable := range t.scopes[index] {
+			if _, shadowed := variables[name]; !shadowed {
+				variables[name] = variable
+			}
+		}
+	}
+	names := make([]string, 0, len(variables))
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]string, len(names))
+	for index, name := range names {
+		entries[index] = strconv.Quote(name) + ": rt.Load(&" + variables[name] + ")"
+	}
+	return "map[string]rt.Value{" + strings.Join(entries, ", ") + "}"
+}
+
+func (t *Transpiler) global(name string) {
+	if !t.declared[name] {
+		t.declared[name] = true
+		t.globals = append(t.globals, name)
+	}
+}
+
+// local declares a Go variable for a Lox local, marking it used since Lox has no such error.
+func (t *Transpiler) local(variable string, value string) {
+	t.write("var "+variable+" rt.Value", variable+" = "+value, "_ = "+variable)
+}
+
+// block generates statements in a Go block of their own.
+func (t *Transpiler) block(statements []ast.Stmt) {
+	t.write("{")
+	for _, statement := range statements {
+		t.stmt(statement)
+	}
+	t.write("}")
+}
+
+// thunk wraps an expression which may not be evaluated into a function.
+func (t *Transpiler) thunk(expr ast.Expr) string {
+	return "func() rt.Value { return " + t.expr(expr) + " }"
+}
+
+func line(token lexer.Token) string {
+	return strconv.Itoa(token.Line)
+}
+
+func literal(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return "rt.Inf(1)"
+		case math.IsInf(v, -1):
+			return "rt.Inf(-1)"
+		case math.IsNaN(v):
+			return "rt.NaN()"
+		case v == 0 && math.Signbit(v):
+			return "rt.NegativeZero()"
+		}
+		return "float64(" + strconv.FormatFloat(v, 'g', -1, 64) + ")"
+	}
+	panic("transpiler: unexpected literal " + strconv.Quote(ast.FormatExpr(&ast.Literal{Value: value})))
+}
+
+func annotation(annotation *ast.TypeAnnotation) string {
+	if annotation == nil {
+		return `""`
+	}
+	return strconv.Quote(annotation.String())
+}
+
+// function generates a function value. Its Go body binds the parameters one by one, evaluating the default values
+// in order, then runs the Lox body; a generator's body runs later on its own goroutine. source is the declaration as
+// printed by ast.Format.
+func (t *Transpiler) function(name string, source string, params []ast.Param, body []ast.Stmt, isGenerator bool) string {
+	descriptions := make([]string, 0, len(params))
+	for _, param := range params {
+		description := "{Name: " + strconv.Quote(param.Name.Lexeme)
+		if param.Default != nil {
+			description += ", Default: true"
+		}
+		if param.Rest {
+			description += ", Rest: true"
+		}
+		if param.Type != nil {
+			description += ", Type: " + annotation(param.Type)
+		}
+		descriptions = append(descriptions, description+"}")
+	}
+	paramsVariable := "params_" + t.next()
+	t.declarations.WriteString("var " + paramsVariable + " = []rt.Param{" + strings.Join(descriptions, ", ") + "}\n")
+
+	out, kind := t.out, t.kind
+	t.out = &strings.Builder{}
+	t.beginScope()
+	for index, param := range params {
+		variable := t.declare(param.Name.Lexeme)
+		t.local(variable, "args.Bind("+strconv.Itoa(index)+")")
+		if param.Default != nil {
+			t.write("if " + variable + " == rt.Missing {")
+			t.write(variable + " = args.Default(" + strconv.Itoa(index) + ", " + t.expr(param.Default) + ")")
+			t.write("}")
+		}
+	}
+	if isGenerator {
+		t.kind = generator
+		t.write("return rt.NewGenerator(t, " + strconv.Quote(name) + ", func(t *rt.Thread, y *rt.Yielder) {")
+		for _, statement := range body {
+			t.stmt(statement)
+		}
+		t.write("})")
+	} else {
+		t.kind = function
+		for _, statement := range body {
+			t.stmt(statement)
+		}
+		t.write("return nil")
+	}
+	t.endScope()
+	code := "rt.NewFunction(" + strconv.Quote(name) + ", " + strconv.Quote(source) + ", " + paramsVariable + ", " + strconv.FormatBool(isGenerator) + ", func(t *rt.Thread, args *rt.Arguments) rt.Value {\n" + t.out.String() + "})"
+	t.out, t.kind = out, kind
+	return code
+}
+
+// arguments generates the arguments of a call. plain reports whether they are all positional, in which case they
+// are separate Go arguments; otherwise they are the elements of an []rt.Argument literal.
+func (t *Transpiler) arguments(expressions []ast.Expr) (code []string, plain bool) {
+	plain = true
+	for _, argument := range expressions {
+		switch argument.(type) {
+		case *ast.Spread, *ast.NamedArg:
+			plain = false
+		}
+	}
+	for _, argument := range expressions {
+		switch argument := argument.(type) {
+		case *ast.Spread:
+			code = append(code, "rt.Spread("+line(argument.Operator)+", "+t.expr(argument.Expression)+")")
+		case *ast.NamedArg:
+			code = append(code, "{Name: "+strconv.Quote(argument.Name.Lexeme)+", Value: "+t.expr(argument.Value)+"}")
+		default:
+			if plain {
+				code = append(code, t.expr(argument))
+			} else {
+				code = append(code, "{Value: "+t.expr(argument)+"}")
+			}
+		}
+	}
+	return code, plain
+}
+
+func (t *Transpiler) VisitBinaryExpr(expr *ast.Binary) (interface{}, error) {
+	return "rt.Binary(" + line(expr.Operator) + ", " + strconv.Quote(expr.Operator.Lexeme) + ", " + t.expr(expr.Left) + ", " + t.expr(expr.Right) + ")", nil
+}
+
+func (t *Transpiler) VisitCallExpr(expr *ast.Call) (interface{}, error) {
+	callee := t.expr(expr.Callee)
+	arguments, plain := t.arguments(expr.Arguments)
+	variable, ok := expr.Callee.(*ast.Variable)
+	direct := ok && variable.Name.Lexeme == "locals" && t.lookup("locals") == ""
+	if plain && (direct || t.reflective) {
+		return "rt.CallLocals(" + strings.Join(append([]string{"t", line(expr.Paren), callee, t.scope()}, arguments...), ", ") + ")", nil
+	}
+	if plain {
+		return "rt.Call(" + strings.Join(append([]string{"t", line(expr.Paren), callee}, arguments...), ", ") + ")", nil
+	}
+	return "rt.CallArgs(t, " + line(expr.Paren) + ", " + callee + ", []rt.Argument{" + strings.Join(arguments, ", ") + "})", nil
+}
+
+func (t *Transpiler) VisitGroupingExpr(expr *ast.Grouping) (interface{}, error) {
+	return t.expr(expr.Expression), nil
+}
+
+func (t *Transpiler) VisitLiteralExpr(expr *ast.Literal) (interface{}, error) {
+	return literal(expr.Value), nil
+}
+
+func (t *Transpiler) VisitLogicalExpr(expr *ast.Logical) (interface{}, error) {
+	operator := "rt.And("
+	if expr.Operator.Type0 == lexer.OR {
+		operator = "rt.Or("
+	}
+	return operator + t.expr(expr.Left) + ", " + t.thunk(expr.Right) + ")", nil
+}
+
+func (t *Transpiler) VisitUnaryExpr(expr *ast.Unary) (interface{}, error) {
+	if expr.Operator.Type0 == lexer.BANG {
+		return "rt.Not(" + t.expr(expr.Right) + ")", nil
+	}
+	return "rt.Negate(" + line(expr.Operator) + ", " + t.expr(expr.Right) + ")", nil
+}
+
+func (t *Transpiler) VisitVariableExpr(expr *ast.Variable) (interface{}, error) {
+	if variable := t.lookup(expr.Name.Lexeme); variable != "" {
+		return "rt.Load(&" + variable + ")", nil
+	}
+	return "g_" + expr.Name.Lexeme + ".Get(" + line(expr.Name) + t.localNames() + ")", nil
+}
+
+func (t *Transpiler) VisitAssignExpr(expr *ast.Assign) (interface{}, error) {
+	variable := t.lookup(expr.Name.Lexeme)
+	var current string
+	if variable != "" {
+		current = "rt.Load(&" + variable + ")"
+	} else {
+		current = "g_" + expr.Name.Lexeme + ".Get(" + line(expr.Name) + t.localNames() + ")"
+	}
+	value := t.expr(expr.Value)
+	if expr.Operator.Type0 != lexer.EQUAL {
+		// the target is read before the right-hand side is evaluated, like the interpreter does
+		operator := strings.TrimSuffix(expr.Operator.Lexeme, "=")
+		value = "rt.Binary(" + line(expr.Operator) + ", " + strconv.Quote(operator) + ", " + current + ", " + value + ")"
+	}
+	if variable != "" {
+		return "rt.Store(&" + variable + ", " + value + ")", nil
+	}
+	return "g_" + expr.Name.Lexeme + ".Assign(" + line(expr.Name) + ", " + value + t.localNames() + ")", nil
+}
+
+func (t *Transpiler) VisitTernaryExpr(expr *ast.Ternary) (interface{}, error) {
+	return "rt.Cond(" + t.expr(expr.ConditionalExpr) + ", " + t.thunk(expr.ThenExpr) + ", " + t.thunk(expr.ElseExpr) + ")", nil
+}
+
+func (t *Transpiler) VisitFunctionExpr(expr *ast.FunctionExpr) (interface{}, error) {
+	source := expr.Source
+	if source == "" {
+		source = ast.FormatExpr(expr)
+	}
+	return t.function("", source, expr.Params, expr.Body, expr.Generator), nil
+}
+
+func (t *Transpiler) VisitUpdateExpr(expr *ast.Update) (interface{}, error) {
+	target, ok := expr.Target.(*ast.Variable)
+	if !ok {
+		return "rt.Fail(" + line(expr.Operator) + ", \"Invalid increment or decrement target\")", nil
+	}
+	delta := "1"
+	if expr.Operator.Type0 == lexer.DECREMENT {
+		delta = "-1"
+	}
+	prefix := strconv.FormatBool(expr.Prefix)
+	if variable := t.lookup(target.Name.Lexeme); variable != "" {
+		return "rt.UpdateLocal(&" + variable + ", " + line(expr.Operator) + ", " + delta + ", " + prefix + ")", nil
+	}
+	return "g_" + target.Name.Lexeme + ".Update(" + line(target.Name) + ", " + line(expr.Operator) + ", " + delta + ", " + prefix + t.localNames() + ")", nil
+}
+
+func (t *Transpiler) VisitSpreadExpr(expr *ast.Spread) (interface{}, error) {
+	return "rt.Fail(" + line(expr.Operator) + ", \"Spread is only allowed in call arguments\")", nil
+}
+
+func (t *Transpiler) VisitNamedArgExpr(expr *ast.NamedArg) (interface{}, error) {
+	return "rt.Fail(" + line(expr.Name) + ", \"Named argument is only allowed in call arguments\")", nil
+}
+
+func (t *Transpiler) VisitSpawnExpr(expr *ast.Spawn) (interface{}, error) {
+	callee := t.expr(expr.Call.Callee)
+	arguments, plain := t.arguments(expr.Call.Arguments)
+	if plain {
+		for index, argument := range arguments {
+			arguments[index] = "{Value: " + argument + "}"
+		}
+	}
+	return "rt.Spawn(t, " + line(expr.Call.Paren) + ", " + callee + ", []rt.Argument{" + strings.Join(arguments, ", ") + "})", nil
+}
+
+func (t *Transpiler) VisitMapExpr(expr *ast.Map) (interface{}, error) {
+	entries := make([]string, 0, 2*len(expr.Keys))
+	for index, key := range expr.Keys {
+		entries = append(entries, t.expr(key), t.expr(expr.Values[index]))
+	}
+	return "rt.NewMap(" + strings.Join(append([]string{line(expr.Brace)}, entries...), ", ") + ")", nil
+}
+
+func (t *Transpiler) VisitTypeCheckExpr(expr *ast.TypeCheck) (interface{}, error) {
+	return "rt.CheckType(" + line(expr.Token) + ", " + annotation(expr.Type) + ", " + t.expr(expr.Expression) + ")", nil
+}
+
+func (t *Transpiler) VisitBlockStmt(stmt *ast.Block) (interface{}, error) {
+	t.beginScope()
+	t.block(stmt.Statements)
+	t.endScope()
+	return nil, nil
+}
+
+func (t *Transpiler) VisitExpressionStmt(stmt *ast.Expression) (interface{}, error) {
+	code := t.expr(stmt.Expression)
+	if _, ok := stmt.Expression.(*ast.Literal); ok {
+		return nil, nil
+	}
+	t.write("_ = " + code)
+	return nil, nil
+}
+
+func (t *Transpiler) VisitFunctionStmt(stmt *ast.Function) (interface{}, error) {
+	name := stmt.Name.Lexeme
+	source := stmt.Source
+	if source == "" {
+		source = strings.TrimSuffix(ast.Format([]ast.Stmt{stmt}), "\n")
+	}
+	variable := t.declare(name)
+	if variable != "" {
+		// declared before the function is created, so that its body can call it
+		t.write("var "+variable+" rt.Value", "_ = "+variable)
+		t.write(variable + " = " + t.function(name, source, stmt.Params, stmt.Body, stmt.Generator))
+		return nil, nil
+	}
+	define := ".Define("
+	if t.options.ConstantFunctions {
+		define = ".DefineConstant("
+	}
+	t.write("g_" + name + define + t.function(name, source, stmt.Params, stmt.Body, stmt.Generator) + ")")
+	return nil, nil
+}
+
+func (t *Transpiler) VisitIfStmt(stmt *ast.If) (interface{}, error) {
+	t.write("if rt.Truthy(" + t.expr(stmt.Condition) + ") {")
+	t.stmt(stmt.ThenBranch)
+	if stmt.ElseBranch != nil {
+		t.write("} else {")
+		t.stmt(stmt.ElseBranch)
+	}
+	t.write("}")
+	return nil, nil
+}
+
+func (t *Transpiler) VisitPrintStmt(stmt *ast.Print) (interface{}, error) {
+	t.write("rt.Print(" + t.expr(stmt.Expression) + ")")
+	return nil, nil
+}
+
+func (t *Transpiler) VisitReturnStmt(stmt *ast.Return) (interface{}, error) {
+	switch {
+	case t.kind == generator:
+		t.write("return")
+	case stmt.Value == nil:
+		t.write("return nil")
+	default:
+		t.write("return " + t.expr(stmt.Value))
+	}
+	return nil, nil
+}
+
+func (t *Transpiler) VisitVarStmt(stmt *ast.Var) (interface{}, error) {
+	value := "nil"
+	if len(t.scopes) == 0 {
+		// a global's initializer may read the global being redeclared, so it is generated before the declaration
+		if stmt.Initializer != nil {
+			value = t.expr(stmt.Initializer)
+		}
+		t.declare(stmt.Name.Lexeme)
+		define := ".Define("
+		if stmt.Constant {
+			define = ".DefineConstant("
+		}
+		t.write("g_" + stmt.Name.Lexeme + define + value + ")")
+		return nil, nil
+	}
+	// a local is declared first, closures created by its initializer refer to it
+	variable := t.declare(stmt.Name.Lexeme)
+	if stmt.Initializer != nil {
+		value = t.expr(stmt.Initializer)
+	}
+	t.local(variable, value)
+	return nil, nil
+}
+
+func (t *Transpiler) VisitWhileStmt(stmt *ast.While) (interface{}, error) {
+	condition := "rt.Truthy(" + t.expr(stmt.Condition) + ")"
+	if stmt.OptionalMutate != nil {
+		t.write("for ; " + condition + "; _ = " + t.expr(stmt.OptionalMutate) + " {")
+	} else {
+		t.write("for " + condition + " {")
+	}
+	t.stmt(stmt.Body)
+	t.write("}")
+	return nil, nil
+}
+
+func (t *Transpiler) VisitBreakStmt(_ *ast.Break) (interface{}, error) {
+	t.write("break")
+	return nil, nil
+}
+
+func (t *Transpiler) VisitContinueStmt(_ *ast.Continue) (interface{}, error) {
+	t.write("continue")
+	return nil, nil
+}
+
+// VisitMatchStmt generates a block trying the cases in order. Each case declares its pattern variables in a block
+// of its own, which MatchCase assigns for every pattern that matches before evaluating the guard.
+func (t *Transpiler) VisitMatchStmt(stmt *ast.Match) (interface{}, error) {
+	id := t.next()
+	subject, end := "match_"+id, "end_"+id
+	t.write("{", "var "+subject+" rt.Value = "+t.expr(stmt.Subject))
+	for _, matchCase := range stmt.Cases {
+		t.beginScope()
+		names := make([]string, 0)
+		patterns := make([]string, 0, len(matchCase.Patterns))
+		for _, pattern := range matchCase.Patterns {
+			code, _ := pattern.Accept(t)
+			patterns = append(patterns, code.(string))
+			ast.Inspect(pattern, func(node ast.Node) bool {
+				switch node := node.(type) {
+				case *ast.BindingPattern:
+					names = append(names, node.Name.Lexeme)
+				case *ast.ListPattern:
+					if node.Rest != nil {
+						names = append(names, node.Rest.Lexeme)
+					}
+				}
+				return true
+			})
+		}
+		patternsVariable := "patterns_" + t.next()
+		t.declarations.WriteString("var " + patternsVariable + " = []rt.Pattern{" + strings.Join(patterns, ", ") + "}\n")
+		t.write("{")
+		assignments := make([]string, 0)
+		for _, name := range names {
+			if _, ok := t.scopes[len(t.scopes)-1][name]; ok {
+				continue
+			}
+			variable := t.declare(name)
+			t.write("var "+variable+" rt.Value", "_ = "+variable)
+			assignments = append(assignments, variable+" = bindings["+strconv.Quote(name)+"]")
+		}
+		bind := "nil"
+		if len(assignments) > 0 || matchCase.Guard != nil {
+			guard := "true"
+			if matchCase.Guard != nil {
+				guard = "rt.Truthy(" + t.expr(matchCase.Guard) + ")"
+			}
+			bind = "func(bindings map[string]rt.Value) bool {\n" + strings.Join(assignments, "\n") + "\nreturn " + guard + "\n}"
+		}
+		t.write("if rt.MatchCase(" + subject + ", " + patternsVariable + ", " + bind + ") {")
+		t.stmt(matchCase.Body)
+		t.write("goto "+end, "}", "}")
+		t.endScope()
+	}
+	t.write("rt.NonExhaustive("+line(stmt.Keyword)+", "+subject+")", end+":", "}")
+	return nil, nil
+}
+
+func (t *Transpiler) VisitForInStmt(stmt *ast.ForIn) (interface{}, error) {
+	iterator := "it_" + t.next()
+	t.write("for " + iterator + " := rt.Iterate(t, " + line(stmt.Keyword) + ", " + t.expr(stmt.Iterable) + "); " + iterator + ".Next(); {")
+	t.beginScope()
+	t.local(t.declare(stmt.Name.Lexeme), iterator+".Value()")
+	t.stmt(stmt.Body)
+	t.endScope()
+	t.write("}")
+	return nil, nil
+}
+
+func (t *Transpiler) VisitYieldStmt(stmt *ast.Yield) (interface{}, error) {
+	value := "nil"
+	if stmt.Value != nil {
+		value = t.expr(stmt.Value)
+	}
+	t.write("y.Yield(" + value + ")")
+	return nil, nil
+}
+
+func (t *Transpiler) VisitAssertStmt(stmt *ast.Assert) (interface{}, error) {
+	message := "nil"
+	if stmt.Message != nil {
+		message = t.thunk(stmt.Message)
+	}
+	source := strconv.Quote(stmt.Source)
+	if binary, ok := stmt.Condition.(*ast.Binary); ok {
+		t.write("rt.AssertBinary(" + line(stmt.Keyword) + ", " + source + ", " + line(binary.Operator) + ", " + strconv.Quote(binary.Operator.Lexeme) + ", " + t.expr(binary.Left) + ", " + t.expr(binary.Right) + ", " + message + ")")
+		return nil, nil
+	}
+	t.write("rt.Assert(" + line(stmt.Keyword) + ", " + source + ", " + t.expr(stmt.Condition) + ", " + message + ")")
+	return nil, nil
+}
+
+// VisitTestStmt generates nothing: running a program only collects its test blocks.
+func (t *Transpiler) VisitTestStmt(_ *ast.Test) (interface{}, error) {
+	return nil, nil
+}
+
+func (t *Transpiler) VisitLiteralPattern(pattern *ast.LiteralPattern) (interface{}, error) {
+	return "rt.LiteralPattern{Value: " + literal(pattern.Value) + "}", nil
+}
+
+func (t *Transpiler) VisitBindingPattern(pattern *ast.BindingPattern) (interface{}, error) {
+	return "rt.BindingPattern{Name: " + strconv.Quote(pattern.Name.Lexeme) + "}", nil
+}
+
+func (t *Transpiler) VisitWildcardPattern(_ *ast.WildcardPattern) (interface{}, error) {
+	return "rt.WildcardPattern{}", nil
+}
+
+func (t *Transpiler) VisitListPattern(pattern *ast.ListPattern) (interface{}, error) {
+	elements := make([]string, 0, len(pattern.Elements))
+	for _, element := range pattern.Elements {
+		code, _ := element.Accept(t)
+		elements = append(elements, code.(string))
+	}
+	code := "rt.ListPattern{Elements: []rt.Pattern{" + strings.Join(elements, ", ") + "}"
+	if pattern.Rest != nil {
+		code += ", Rest: " + strconv.Quote(pattern.Rest.Lexeme) + ", HasRest: true"
+	}
+	return code + "}", nil
+}
+
+func (t *Transpiler) VisitMapPattern(pattern *ast.MapPattern) (interface{}, error) {
+	keys := make([]string, 0, len(pattern.Keys))
+	values := make([]string, 0, len(pattern.Values))
+	for index, key := range pattern.Keys {
+		keys = append(keys, literal(key.(*ast.LiteralPattern).Value))
+		code, _ := pattern.Values[index].Accept(t)
+		values = append(values, code.(string))
+	}
+	return "rt.MapPattern{Keys: []rt.Value{" + strings.Join(keys, ", ") + "}, Values: []rt.Pattern{" + strings.Join(values, ", ") + "}}", nil
+}
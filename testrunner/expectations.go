@@ -0,0 +1,58 @@
+package testrunner
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	expectedOutputPattern       = regexp.MustCompile(`// expect: ?(.*)$`)
+	expectedRuntimeErrorPattern = regexp.MustCompile(`// expect runtime error: (.+)$`)
+	expectedCompileErrorPattern = regexp.MustCompile(`// (\[((java|c) )?line (\d+)\] )?(Error.*)$`)
+)
+
+// Expectations are what a test script declares about its own behavior in comments:
+//
+//	print 1 + 2;        // expect: 3
+//	print undefined;    // expect runtime error: Undefined variable 'undefined'
+//	var 1 = 2;          // Error at '1': Expect variable name
+//	// [line 7] Error at end: Expect ';' after value
+//
+// An error comment without an explicit line refers to the line it is written on. Lines tagged `[c line N]` belong
+// to the C implementation of Lox and are ignored.
+type Expectations struct {
+	Output        []string
+	CompileErrors []string
+	RuntimeError  string
+	ExitCode      int
+}
+
+// ParseExpectations collects the expectations written in source.
+func ParseExpectations(source string) Expectations {
+	expectations := Expectations{Output: make([]string, 0), CompileErrors: make([]string, 0)}
+	for index, line := range strings.Split(source, "\n") {
+		line = strings.TrimRight(line, "\r")
+		lineNumber := index + 1
+		if match := expectedOutputPattern.FindStringSubmatch(line); match != nil {
+			expectations.Output = append(expectations.Output, match[1])
+			continue
+		}
+		if match := expectedRuntimeErrorPattern.FindStringSubmatch(line); match != nil {
+			expectations.RuntimeError = "[line " + strconv.Itoa(lineNumber) + "] Error: " + match[1]
+			expectations.ExitCode = 70
+			continue
+		}
+		if match := expectedCompileErrorPattern.FindStringSubmatch(line); match != nil {
+			if match[3] == "c" {
+				continue
+			}
+			if match[4] != "" {
+				lineNumber, _ = strconv.Atoi(match[4])
+			}
+			expectations.CompileErrors = append(expectations.CompileErrors, "[line "+strconv.Itoa(lineNumber)+"] "+match[5])
+			expectations.ExitCode = 65
+		}
+	}
+	return expectations
+}
@@ -0,0 +1,166 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats maps the names accepted by `golox test -format` to the reporters writing them. Every reporter returns
+// the number of failed scripts.
+var Formats = map[string]func(w io.Writer, results []Result) int{
+	"text":  Report,
+	"tap":   ReportTAP,
+	"junit": ReportJUnit,
+}
+
+// Report prints a line per script and per test block, the differences of the failed ones and a summary.
+func Report(w io.Writer, results []Result) int {
+	tests, failedTests := 0, 0
+	for _, result := range results {
+		if result.Passed() {
+			_, _ = fmt.Fprintf(w, "PASS %s (%s)\n", result.Path, result.Duration.Round(time.Microsecond))
+		} else {
+			_, _ = fmt.Fprintf(w, "FAIL %s\n", result.Path)
+		}
+		for _, failure := range result.Failures {
+			_, _ = fmt.Fprintf(w, "    %s\n", failure)
+		}
+		for _, test := range result.Tests {
+			tests++
+			if test.Passed() {
+				_, _ = fmt.Fprintf(w, "    ok   %s (%s)\n", test.Name, test.Duration.Round(time.Microsecond))
+				continue
+			}
+			failedTests++
+			_, _ = fmt.Fprintf(w, "    FAIL %s\n        %s\n", test.Name, test.Failure)
+		}
+	}
+	failed := failedCount(results)
+	_, _ = fmt.Fprintf(w, "%d passed, %d failed", len(results)-failed, failed)
+	if tests > 0 {
+		_, _ = fmt.Fprintf(w, " (test blocks: %d passed, %d failed)", tests-failedTests, failedTests)
+	}
+	_, _ = fmt.Fprintln(w)
+	return failed
+}
+
+// ReportTAP writes the results in the Test Anything Protocol, version 13: a test point per script for its
+// expectations, followed by one per test block.
+func ReportTAP(w io.Writer, results []Result) int {
+	points := 0
+	for _, result := range results {
+		points += 1 + len(result.Tests)
+	}
+	_, _ = fmt.Fprintf(w, "TAP version 13\n1..%d\n", points)
+	point := 0
+	for _, result := range results {
+		point++
+		writeTAPPoint(w, point, result.Path, result.Failures)
+		for _, test := range result.Tests {
+			point++
+			failures := make([]string, 0, 1)
+			if !test.Passed() {
+				failures = append(failures, test.Failure)
+			}
+			writeTAPPoint(w, point, result.Path+" > "+test.Name, failures)
+		}
+	}
+	return failedCount(results)
+}
+
+func writeTAPPoint(w io.Writer, number int, description string, failures []string) {
+	if len(failures) == 0 {
+		_, _ = fmt.Fprintf(w, "ok %d - %s\n", number, description)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "not ok %d - %s\n  ---\n  message: |\n", number, description)
+	for _, failure := range failures {
+		_, _ = fmt.Fprintf(w, "    %s\n", failure)
+	}
+	_, _ = fmt.Fprintln(w, "  ...")
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// ReportJUnit writes the results as JUnit XML: a test suite per script, holding an "expectations" test case for
+// its output and errors and a test case per test block.
+func ReportJUnit(w io.Writer, results []Result) int {
+	suites := junitTestSuites{}
+	for _, result := range results {
+		suite := junitTestSuite{Name: result.Path, Time: seconds(result.Duration)}
+		suite.Cases = append(suite.Cases, junitCase("expectations", result.Path, result.Duration, result.Failures))
+		for _, test := range result.Tests {
+			failures := make([]string, 0, 1)
+			if !test.Passed() {
+				failures = append(failures, test.Failure)
+			}
+			suite.Cases = append(suite.Cases, junitCase(test.Name, result.Path, test.Duration, failures))
+		}
+		for _, testCase := range suite.Cases {
+			suite.Tests++
+			if testCase.Failure != nil {
+				suite.Failures++
+			}
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+	_, _ = io.WriteString(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	_ = encoder.Encode(suites)
+	_, _ = fmt.Fprintln(w)
+	return failedCount(results)
+}
+
+func junitCase(name string, className string, duration time.Duration, failures []string) junitTestCase {
+	testCase := junitTestCase{Name: name, ClassName: className, Time: seconds(duration)}
+	if len(failures) > 0 {
+		testCase.Failure = &junitFailure{Message: failures[0], Text: strings.Join(failures, "\n")}
+	}
+	return testCase
+}
+
+func seconds(duration time.Duration) string {
+	return strconv.FormatFloat(duration.Seconds(), 'f', 6, 64)
+}
+
+func failedCount(results []Result) int {
+	failed := 0
+	for _, result := range results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
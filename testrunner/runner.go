@@ -0,0 +1,171 @@
+// Package testrunner runs golden-file tests: Lox scripts annotated with the output and errors they must produce,
+// see Expectations, along with the `test` blocks they declare. It backs the `golox test` command and can be used
+// from `go test` as well.
+package testrunner
+
+import (
+	"bytes"
+	"golox/VM"
+	"golox/lox/common"
+	"golox/lox/coverage"
+	"golox/lox/interpreter"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStepLimit keeps a script stuck in an infinite loop from hanging the whole run.
+const DefaultStepLimit = 10_000_000
+
+type Options struct {
+	StepLimit int64             // 0 selects DefaultStepLimit
+	Optimize  bool              // run the scripts through the optimizer, see VM.SetOptimize
+	Strict    bool              // reject references to undeclared globals, see VM.SetStrict
+	Coverage  *coverage.Profile // when set, the coverage of every script is measured into it
+}
+
+// Result is the outcome of one test script. Failures describe every difference from the expectations, Tests are
+// the outcomes of the `test` blocks the script declares.
+type Result struct {
+	Path     string
+	Failures []string
+	Tests    []Case
+	Duration time.Duration
+}
+
+func (r Result) Passed() bool {
+	for _, test := range r.Tests {
+		if !test.Passed() {
+			return false
+		}
+	}
+	return len(r.Failures) == 0
+}
+
+// Case is the outcome of one `test` block. Failure is empty if the test passed.
+type Case struct {
+	Name     string
+	Failure  string
+	Duration time.Duration
+}
+
+func (c Case) Passed() bool {
+	return c.Failure == ""
+}
+
+// Discover returns the .lox files named by paths, walking directories recursively, in lexical order.
+func Discover(paths []string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(file, ".lox") {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Run discovers and runs the test scripts named by paths.
+func Run(paths []string, options Options) ([]Result, error) {
+	files, err := Discover(paths)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(files))
+	for _, file := range files {
+		results = append(results, RunFile(file, options))
+	}
+	return results, nil
+}
+
+// RunFile runs one test script in a VM of its own and compares what it does with its expectations.
+func RunFile(path string, options Options) Result {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return Result{Path: path, Failures: []string{err.Error()}}
+	}
+	return RunSource(path, string(source), options)
+}
+
+// RunSource is RunFile for a script which is already in memory; path only names the result.
+func RunSource(path string, source string, options Options) Result {
+	expectations := ParseExpectations(source)
+	stepLimit := options.StepLimit
+	if stepLimit == 0 {
+		stepLimit = DefaultStepLimit
+	}
+	var stdout, stderr bytes.Buffer
+	vm := &VM.VM{}
+	vm.SetStdin(strings.NewReader(""))
+	vm.SetStdout(&stdout)
+	vm.SetStderr(&stderr)
+	vm.SetStepLimit(stepLimit)
+	vm.SetOptimize(options.Optimize)
+	vm.SetStrict(options.Strict)
+	if options.Coverage != nil {
+		vm.SetCoverage(options.Coverage.File(path, source))
+	}
+	start := time.Now()
+	exitCode, tests := vm.RunTestsStr(source)
+	result := Result{Path: path, Duration: time.Since(start)}
+	for _, test := range tests {
+		result.Tests = append(result.Tests, newCase(test))
+	}
+
+	result.Failures = append(result.Failures, diffLines("output", expectations.Output, lines(stdout.String()))...)
+	expectedErrors := expectations.CompileErrors
+	if expectations.RuntimeError != "" {
+		expectedErrors = append(expectedErrors, expectations.RuntimeError)
+	}
+	result.Failures = append(result.Failures, diffLines("error", expectedErrors, lines(stderr.String()))...)
+	if exitCode != expectations.ExitCode {
+		result.Failures = append(result.Failures, "expected exit code "+strconv.Itoa(expectations.ExitCode)+" but got "+strconv.Itoa(exitCode))
+	}
+	return result
+}
+
+func newCase(test interpreter.TestResult) Case {
+	testCase := Case{Name: test.Name, Duration: test.Duration}
+	if runtimeError, ok := test.Err.(common.RuntimeError); ok {
+		testCase.Failure = "[line " + strconv.Itoa(runtimeError.Token.Line) + "] Error: " + runtimeError.Reason
+	} else if test.Err != nil {
+		testCase.Failure = "[line " + strconv.Itoa(test.Line) + "] Error: " + test.Err.Error()
+	}
+	return testCase
+}
+
+func lines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines compares expected and actual line by line and describes each mismatch.
+func diffLines(kind string, expected []string, actual []string) []string {
+	failures := make([]string, 0)
+	for index := 0; index < len(expected) || index < len(actual); index++ {
+		switch {
+		case index >= len(actual):
+			failures = append(failures, "missing "+kind+" "+strconv.Quote(expected[index]))
+		case index >= len(expected):
+			failures = append(failures, "unexpected "+kind+" "+strconv.Quote(actual[index]))
+		case expected[index] != actual[index]:
+			failures = append(failures, "expected "+kind+" "+strconv.Quote(expected[index])+" but got "+strconv.Quote(actual[index]))
+		}
+	}
+	return failures
+}
@@ -1,27 +1,21 @@
-//go:build ignore
-// +build ignore
-
 package tests
 
 import (
-	"fmt"
-	"github.com/stretchr/testify/assert"
 	"golox/lox/ast"
 	"golox/lox/lexer"
 	"testing"
 )
 
 func TestAST_Printer(t *testing.T) {
-	expression := &ast.Binary{Left: &ast.Unary{Operator: lexer.Token{Type0: lexer.MINUS, Lexeme: "-", Line: 1}, Right: &ast.Literal{Value: 123}},
+	expression := &ast.Binary{Left: &ast.Unary{Operator: lexer.Token{Type0: lexer.MINUS, Lexeme: "-", Line: 1}, Right: &ast.Literal{Value: 123.0}},
 		Operator: lexer.Token{Type0: lexer.STAR, Lexeme: "*", Line: 1},
 		Right:    &ast.Grouping{Expression: &ast.Literal{Value: 45.67}}}
 
 	printer := ast.AstPrinter{}
 	str, _ := printer.Print(expression)
-	fmt.Println()
-	fmt.Println(str.(string))
-	fmt.Println()
-	assert.Equal(t, "(* (- 123) (group 45.67))", str.(string))
+	if str.(string) != "(* (- 123) (group 45.67))" {
+		t.Errorf("got %s", str)
+	}
 }
 
 func TestAST_Printer_RPN(t *testing.T) {
@@ -32,8 +26,26 @@ func TestAST_Printer_RPN(t *testing.T) {
 
 	printer := ast.AstPrinterRPN{}
 	str, _ := printer.Print(expression)
-	fmt.Println()
-	fmt.Println(str.(string))
-	fmt.Println()
-	assert.Equal(t, "1 2 + 4 3 - *", str.(string))
+	if str.(string) != "1 2 + 4 3 - *" {
+		t.Errorf("got %s", str)
+	}
+}
+
+func TestAST_PrintProgram(t *testing.T) {
+	statements, ok := parse(`fun add(a, b = 1) { return a + b; }
+var x = add(2);
+while (x < 5) x += 1;
+print x > 4 ? "big" : "small";
+`)
+	if !ok {
+		t.Fatal("program doesn't parse")
+	}
+	expected := `(fun add (a (= b 1)) (return (+ a b)))
+(var x (call add 2))
+(while (< x 5) (; (+= x 1)))
+(print (?: (> x 4) "big" "small"))
+`
+	if printed := (&ast.AstPrinter{}).PrintProgram(statements); printed != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, printed)
+	}
 }
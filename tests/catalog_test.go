@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"golox/VM"
+	"golox/lox/catalog"
+	"io"
+	"strings"
+	"testing"
+)
+
+// hasCode runs source and tells whether it reports an error with code.
+func hasCode(source string, code string) bool {
+	vm := &VM.VM{}
+	vm.SetStdout(io.Discard)
+	vm.SetStderr(io.Discard)
+	vm.SetStepLimit(100000)
+	vm.RunStr(source)
+	for _, diagnostic := range vm.Diagnostics() {
+		if diagnostic.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCatalogExamples checks that the example of every entry reports its code and the fixed example doesn't.
+func TestCatalogExamples(t *testing.T) {
+	// too long to be written out
+	abridged := map[string]bool{catalog.InvalidNumber: true, catalog.TooManyArguments: true}
+	for _, entry := range catalog.Entries() {
+		if entry.Example == "" || abridged[entry.Code] {
+			continue
+		}
+		if !hasCode(entry.Example, entry.Code) {
+			t.Errorf("the example of %s doesn't report it:\n%s", entry.Code, entry.Example)
+		}
+		if hasCode(entry.Fixed, entry.Code) {
+			t.Errorf("the fixed example of %s reports it:\n%s", entry.Code, entry.Fixed)
+		}
+	}
+}
+
+// TestDiagnosticCodes checks that errors of each stage report their code.
+func TestDiagnosticCodes(t *testing.T) {
+	for _, test := range []struct {
+		source, code string
+	}{
+		{"print 1", catalog.MissingSemicolon},
+		{"print @;", catalog.UnexpectedCharacter},
+		{"return 1;", catalog.TopLevelReturn},
+		{"var n: number = \"one\";", catalog.TypeMismatch},
+		{"print undefined;", catalog.UndefinedVariable},
+		{"print -\"one\";", catalog.OperandType},
+		{"fun f(a) {}\nf(1, 2);", catalog.ArgumentCount},
+		{"send(1, 2);", catalog.InvalidNativeArgument},
+	} {
+		if !hasCode(test.source, test.code) {
+			t.Errorf("%q doesn't report %s", test.source, test.code)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	entry, ok := catalog.Lookup("LOX-P004")
+	if !ok {
+		t.Fatal("LOX-P004 isn't in the catalog")
+	}
+	explanation := catalog.Explain(entry)
+	for _, want := range []string{"LOX-P004: Missing semicolon\n", "\n    print \"hello\";\n"} {
+		if !strings.Contains(explanation, want) {
+			t.Errorf("the explanation of LOX-P004 lacks %q:\n%s", want, explanation)
+		}
+	}
+	if _, ok := catalog.Lookup("LOX-P999"); ok {
+		t.Error("LOX-P999 is in the catalog")
+	}
+}
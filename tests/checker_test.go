@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"bytes"
+	"golox/lox/ast"
+	"golox/lox/checker"
+	"golox/utils"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		source string
+		checks int    // runtime checks inserted
+		error  string // the compile error expected, if any
+	}{
+		{"var x: number = 1 + 2;", 0, ""},
+		{"var x: string = 1 + y;", 1, ""},
+		{"var x: bool = 1 + y;", 0, "Error at 'x': Expected bool but got number | string."},
+		{`var x: number | string = 1 + y;`, 0, ""},
+		// programs without annotations are left alone, even when they fail at runtime
+		{`print 1 + nil; print -"a";`, 0, ""},
+		// a local which is never assigned has the type of its initializer
+		{"fun f() { var n = 1; var s: number = n; }", 0, ""},
+		{`fun f() { var n = "a"; var s: number = n; }`, 0, "Error at 's': Expected number but got string."},
+		{"fun f(x) { var n = 1; n = x; var s: number = n; }", 1, ""},
+		{"fun f(x) { var n = 1; n++; var s: number = n; }", 1, ""},
+		// so does a global, as long as no code assigns it, even code coming before its declaration
+		{"var g = 1; var s: number = g;", 0, ""},
+		{`fun h() { g = "s"; } var g = 1; var s: number = g;`, 1, ""},
+		{`var g = 1; var g = "s"; var s: number = g;`, 1, ""},
+		// pattern variables shadow the variables outside the match
+		{"var n = 1; match (x) { case n => { var s: number = n; } }", 1, ""},
+		{"fun f(a: number) {} f(1);", 0, ""},
+		{`fun f(a: number) {} f("s");`, 0, "Error at ')': Expected number for argument 'a' but got string."},
+		{`fun f(a: number, b: string) {} f(b: 1, a: 2);`, 0, "Error at ')': Expected string for argument 'b' but got number."},
+		{`fun f(...a: number) {} f(1, "s");`, 0, "Error at ')': Expected number for argument 'a' but got string."},
+		{`fun f(a: number) {} f(...x);`, 0, ""},
+		{`fun f(a: number) {} f = g; f("s");`, 0, ""},
+		// the result of a call has the declared return type
+		{"fun f(): string { return x; } var n: number = f();", 1, "Error at 'n': Expected number but got string."},
+		{"fun f(): number { while (true) { return 1; } }", 0, ""},
+		{"fun f(): number { while (true) { break; } }", 0, "Error at 'f': Missing return in function returning number."},
+		{"fun f(): number? { }", 0, ""},
+		{"var x: number = 1; x += y;", 1, ""},
+		{`var x: string = "a"; x += y;`, 0, ""},
+	}
+	for _, test := range tests {
+		statements, ok := parse(test.source)
+		if !ok {
+			t.Fatalf("%s doesn't parse", test.source)
+		}
+		var errors bytes.Buffer
+		c := checker.NewChecker()
+		c.SetReporter(utils.NewReporter(&errors))
+		_ = c.Check(statements)
+		checks := 0
+		for _, statement := range statements {
+			ast.Inspect(statement, func(node ast.Node) bool {
+				if _, ok := node.(*ast.TypeCheck); ok {
+					checks++
+				}
+				return true
+			})
+		}
+		if checks != test.checks {
+			t.Errorf("checking %s: expected %d runtime checks but got %d", test.source, test.checks, checks)
+		}
+		reported := strings.TrimSpace(errors.String())
+		if (test.error == "") != (reported == "") || !strings.Contains(reported, test.error) {
+			t.Errorf("checking %s: expected error %q but got %q", test.source, test.error, reported)
+		}
+	}
+}
+
+func TestFormatTypeAnnotations(t *testing.T) {
+	source := "var x: number | nil = 1;\nfun f(a: string, ...rest: any): bool {\n  return true;\n}\n"
+	statements, ok := parse(source)
+	if !ok {
+		t.Fatalf("%s doesn't parse", source)
+	}
+	if formatted := ast.Format(statements); formatted != source {
+		t.Errorf("expected\n%s\nbut got\n%s", source, formatted)
+	}
+}
@@ -0,0 +1,80 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// buildGolox builds golox into a temporary directory and returns the path of the binary and of the go tool.
+func buildGolox(t *testing.T) (golox string, goTool string) {
+	if testing.Short() {
+		t.Skip("builds golox")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	golox = filepath.Join(t.TempDir(), "golox")
+	build := exec.Command(goTool, "build", "-o", golox, "../cmd")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, output)
+	}
+	return golox, goTool
+}
+
+// TestCommandFlags checks that the flags of a command are accepted before and after its arguments.
+func TestCommandFlags(t *testing.T) {
+	golox, _ := buildGolox(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "a.lox")
+	if err := os.WriteFile(script, []byte("print 1 + 2;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cases := [][]string{
+		{"ast", "--format=sexpr", script},
+		{"ast", script, "--format=sexpr"},
+		{"ast", script, "-format", "sexpr"},
+	}
+	for _, args := range cases {
+		run := execute(golox, args...)
+		if want := (transpiledRun{stdout: "(print (+ 1 2))\n"}); run != want {
+			t.Errorf("golox %v exits with %d and prints\n%s%s", args, run.exitCode, run.stdout, run.stderr)
+		}
+	}
+	if run := execute(golox, "ast", script, "--format=yaml"); run.exitCode != 64 {
+		t.Errorf("golox ast with an unknown format exits with %d, want 64", run.exitCode)
+	}
+}
+
+// TestTranspileCommandModule checks that transpiling a script whose name isn't a valid module path gives a module
+// which builds.
+func TestTranspileCommandModule(t *testing.T) {
+	golox, goTool := buildGolox(t)
+	dir := t.TempDir()
+	script := filepath.Join(dir, "my prog.lox")
+	if err := os.WriteFile(script, []byte("print 1 + 2;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if run := execute(golox, "transpile", script); run.exitCode != 0 {
+		t.Fatalf("golox transpile exits with %d and prints\n%s%s", run.exitCode, run.stdout, run.stderr)
+	}
+	module, err := os.ReadFile(filepath.Join(dir, "my prog-go", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(module), "module my-prog\n") {
+		t.Errorf("go.mod starts with %q, want module my-prog", module)
+	}
+	program := filepath.Join(dir, "program")
+	build := exec.Command(goTool, "build", "-o", program, ".")
+	build.Dir = filepath.Join(dir, "my prog-go")
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, output)
+	}
+	if run := execute(program); run != (transpiledRun{stdout: "3\n"}) {
+		t.Errorf("the program exits with %d and prints\n%s%s", run.exitCode, run.stdout, run.stderr)
+	}
+}
@@ -1,10 +1,29 @@
 package tests
 
 import (
+	"bytes"
 	"golox/VM"
+	"io"
 	"testing"
 )
 
+// checkRun runs snippet on vm and checks its exit code and what it prints to stdout and stderr.
+func checkRun(t *testing.T, vm *VM.VM, snippet string, code int, stdout string, stderr string) {
+	t.Helper()
+	var out, err bytes.Buffer
+	vm.SetStdout(&out)
+	vm.SetStderr(&err)
+	if got := vm.RunStr(snippet); got != code {
+		t.Errorf("exit code %d, want %d", got, code)
+	}
+	if out.String() != stdout {
+		t.Errorf("stdout\n%s\nwant\n%s", out.String(), stdout)
+	}
+	if err.String() != stderr {
+		t.Errorf("stderr\n%s\nwant\n%s", err.String(), stderr)
+	}
+}
+
 func TestCodeSnippet1(t *testing.T) {
 	snippet := `// Your first Lox program!
 /* nested
@@ -118,7 +137,12 @@ print a;
 }
 `
 	vm := &VM.VM{}
-	vm.RunStr(snippet)
+	vm.SetStdout(io.Discard)
+	vm.SetStderr(io.Discard)
+	vm.SetStepLimit(10000)
+	if code := vm.RunStr(snippet); code != 70 {
+		t.Errorf("expected exit code 70 once the step limit is hit, got %d", code)
+	}
 }
 
 func TestFibonacciCodeSnippet(t *testing.T) {
@@ -319,3 +343,278 @@ sayHi("Too little");
 	vm.RunStr(snippet)
 
 }
+
+func TestCompoundAssignment(t *testing.T) {
+	snippet := `
+var a = 10;
+a += 5;
+a -= 3;
+a *= 2;
+a /= 4;
+a %= 4;
+print a; // 2
+var s = "count: ";
+s += 3;
+print s;
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "2\ncount: 3\n", "")
+}
+
+func TestPrefixPostfixIncrement(t *testing.T) {
+	snippet := `
+var a = 1;
+print a++; // 1
+print a;   // 2
+print ++a; // 3
+print a--; // 3
+print --a; // 1
+fun counter() {
+  var i = 0;
+  fun next() {
+    return i++;
+  }
+  return next;
+}
+var next = counter();
+print next(); // 0
+print next(); // 1
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "1\n2\n3\n3\n1\n0\n1\n", "")
+}
+
+func TestDefaultParameters(t *testing.T) {
+	snippet := `
+fun greet(name, greeting = "Hello", punctuation = greeting + "!") {
+  print greeting + ", " + name + punctuation;
+}
+greet("Reader");
+greet("Reader", "Goodbye");
+greet("Reader", punctuation: "?");
+greet(greeting: "Hi", name: "Reader");
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "Hello, ReaderHello!\nGoodbye, ReaderGoodbye!\nHello, Reader?\nHi, ReaderHi!\n", "")
+}
+
+func TestRestParameterAndSpread(t *testing.T) {
+	snippet := `
+fun list(...items) {
+  return items;
+}
+fun sum(first, ...rest) {
+  var total = first;
+  fun add(a = 0, b = 0, c = 0, d = 0) { return a + b + c + d; }
+  return total + add(...rest);
+}
+print list(1, "two", 3); // [1, "two", 3]
+print sum(1);            // 1
+print sum(1, ...list(2, 3, 4)); // 10
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "[1, \"two\", 3]\n1\n10\n", "")
+}
+
+func TestConstBinding(t *testing.T) {
+	snippet := `
+const greeting = "hello";
+fun shout() {
+  let suffix = "!";
+  print greeting + suffix;
+}
+shout();
+{
+  var greeting = "shadowed";
+  greeting = "reassigned";
+  print greeting;
+}
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "hello!\nreassigned\n", "")
+}
+
+func TestConstFunctionDeclaration(t *testing.T) {
+	snippet := `
+fun answer() { return 42; }
+print answer();
+answer = fun () { return 0; };
+print answer();
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "42\n0\n", "")
+	vm := &VM.VM{}
+	vm.SetConstantFunctions(true)
+	checkRun(t, vm, snippet, 65, "", "[line 4] Error at 'answer': Cannot assign to constant 'answer' declared at line 2\n")
+}
+
+func TestMatchStatement(t *testing.T) {
+	snippet := `
+fun list(...items) { return items; }
+fun describe(value) {
+  match (value) {
+    case nil => return "nothing";
+    case 1, 2 => return "small";
+    case "hi" => return "greeting";
+    case [x, y] => return "pair of " + x + " and " + y;
+    case [head, ...tail] => return "list starting with " + head;
+    case n if n > 10 => return "big number " + n;
+    case _ => return "something else";
+  }
+}
+print describe(nil);
+print describe(2);
+print describe("hi");
+print describe(list(1, 2));
+print describe(list(1, 2, 3));
+print describe(42);
+print describe(5);
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "nothing\nsmall\ngreeting\npair of 1 and 2\nlist starting with 1\nbig number 42\nsomething else\n", "")
+}
+
+func TestNonExhaustiveMatch(t *testing.T) {
+	snippet := `
+match (3) {
+  case 1 => print "one";
+}
+`
+	checkRun(t, &VM.VM{}, snippet, 70, "", "[line 2] Error: Non-exhaustive match: no case matches 3\n")
+}
+
+func TestForInLoop(t *testing.T) {
+	snippet := `
+fun list(...items) { return items; }
+for (c in "你好!") print c;
+for (var i in range(0, 10, 3)) print i;
+for (i in range(3)) {
+  if (i == 1) continue;
+  print i;
+}
+for (item in list("a", "b", "c")) {
+  if (item == "c") break;
+  print item;
+}
+fun countdown(n) {
+  fun next() {
+    if (n == 0) return nil;
+    return n--;
+  }
+  return next;
+}
+for (n in countdown(3)) print n;
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "你\n好\n!\n0\n3\n6\n9\n0\n2\na\nb\n3\n2\n1\n", "")
+}
+
+func TestForInFreshBinding(t *testing.T) {
+	snippet := `
+var first;
+var last;
+for (i in range(3)) {
+  if (first == nil) first = fun () { print i; };
+  last = fun () { print i; };
+}
+first(); // 0
+last();  // 2
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "0\n2\n", "")
+}
+
+func TestGenerator(t *testing.T) {
+	snippet := `
+fun* naturals() {
+  var n = 0;
+  while (true) {
+    yield n;
+    n++;
+  }
+}
+fun evens(limit) {
+  for (n in range(limit)) {
+    if (n % 2 == 0) {
+      yield n;
+    }
+  }
+}
+for (even in evens(7)) print even;
+var numbers = naturals();
+for (n in numbers) {
+  if (n == 3) break;
+  print n;
+}
+for (n in numbers) { // resumes after 3
+  if (n == 6) break;
+  print n;
+}
+print numbers;
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "0\n2\n4\n6\n0\n1\n2\n4\n5\n<generator naturals>\n", "")
+}
+
+func TestSpawnAndWait(t *testing.T) {
+	snippet := `
+fun fib(n) {
+  if (n <= 1) return n;
+  return fib(n - 2) + fib(n - 1);
+}
+var first = spawn fib(10);
+var second = spawn fib(12);
+print wait(first);         // 55
+print wait(first, second); // [55, 144]
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "55\n[55, 144]\n", "")
+}
+
+func TestChannels(t *testing.T) {
+	snippet := `
+var jobs = channel(10);
+var results = channel();
+fun worker(id) {
+  for (job in jobs) send(results, job * 2);
+}
+var workers = 3;
+for (i in range(workers)) spawn worker(i);
+for (job in range(5)) send(jobs, job);
+close(jobs);
+var total = 0;
+for (i in range(5)) total += receive(results);
+print total; // 20
+
+var quit = channel();
+spawn close(quit);
+match (select(quit)) {
+  case [_, nil] => print "quit";
+}
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "20\nquit\n", "")
+}
+
+// TestSharedGenerator has two tasks take the values of one generator in turns, run it with -race to check the
+// generator's state is guarded.
+func TestSharedGenerator(t *testing.T) {
+	snippet := `
+fun list(...items) { return items; }
+fun* numbers() {
+  for (n in range(100)) yield n;
+}
+var shared = numbers();
+fun consume() {
+  var total = 0;
+  var count = 0;
+  for (n in shared) {
+    total += n;
+    count++;
+  }
+  return list(total, count);
+}
+var results = wait(spawn consume(), spawn consume());
+var total = 0;
+var count = 0;
+for (result in results) {
+  match (result) {
+    case [t, c] => {
+      total += t;
+      count += c;
+    }
+  }
+}
+print total;
+print count;
+`
+	checkRun(t, &VM.VM{}, snippet, 0, "4950\n100\n", "")
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"bufio"
+	"golox/testrunner"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const conformanceDir = "conformance"
+
+// TestConformance runs the reference Lox test suite ported under tests/conformance. Scripts listed in
+// known_failures.txt must fail and all the others must pass, so that both regressions and closed gaps show up.
+func TestConformance(t *testing.T) {
+	runConformance(t, testrunner.Options{})
+}
+
+// TestConformanceOptimized checks that the optimizer doesn't change the outcome of any conformance script.
+func TestConformanceOptimized(t *testing.T) {
+	runConformance(t, testrunner.Options{Optimize: true})
+}
+
+func runConformance(t *testing.T, options testrunner.Options) {
+	knownFailures, err := readKnownFailures(filepath.Join(conformanceDir, "known_failures.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := testrunner.Discover([]string{conformanceDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	passed := 0
+	for _, file := range files {
+		name := filepath.ToSlash(strings.TrimPrefix(file, conformanceDir+string(filepath.Separator)))
+		result := testrunner.RunFile(file, options)
+		reason, known := knownFailures[name]
+		delete(knownFailures, name)
+		switch {
+		case result.Passed() && known:
+			t.Errorf("%s passes now, remove it from known_failures.txt (%s)", name, reason)
+		case !result.Passed() && !known:
+			t.Errorf("%s fails:\n    %s", name, strings.Join(result.Failures, "\n    "))
+		}
+		if result.Passed() {
+			passed++
+		}
+	}
+	for name := range knownFailures {
+		t.Errorf("%s is listed in known_failures.txt but doesn't exist", name)
+	}
+	t.Logf("conformance: %d of %d scripts pass (%.1f%%)", passed, len(files), 100*float64(passed)/float64(len(files)))
+}
+
+// readKnownFailures maps the scripts listed in path to the reason they fail for.
+func readKnownFailures(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	failures := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		failures[fields[0]] = strings.Join(fields[1:], " ")
+	}
+	return failures, scanner.Err()
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bytes"
+	"golox/VM"
+	"golox/lox/coverage"
+	"golox/testrunner"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const coveredScript = `fun sign(n) {
+  if (n < 0) return -1;
+  if (n == 0) return 0;
+  return 1;
+}
+var i = 0;
+while (i < 3) i = i + 1;
+print sign(5) == 1 and sign(-1) == -1;
+print true or sign(0);
+print i > 2 ? "big" : "small";
+`
+
+func cover(t *testing.T, source string) *coverage.Profile {
+	profile := coverage.NewProfile()
+	var stdout bytes.Buffer
+	vm := &VM.VM{}
+	vm.SetStdout(&stdout)
+	vm.SetCoverage(profile.File("covered.lox", source))
+	if code := vm.RunStr(source); code != 0 {
+		t.Fatalf("the script exits with %d", code)
+	}
+	return profile
+}
+
+func TestCoverageCounts(t *testing.T) {
+	file := cover(t, coveredScript).Files()[0]
+	var uncovered []int
+	for _, statement := range file.Statements() {
+		if statement.Count == 0 {
+			uncovered = append(uncovered, statement.Line)
+		}
+	}
+	if len(uncovered) != 1 || uncovered[0] != 3 {
+		t.Errorf("the statements left uncovered are on lines %v, want [3]", uncovered)
+	}
+	var branches []string
+	for _, branch := range file.Branches() {
+		branches = append(branches, branch.Kind+" "+outcomesOf(branch))
+	}
+	want := []string{"if 1/1", "if 0/1", "while 3/1", "and 0/1", "or 1/0", "ternary 1/0"}
+	if strings.Join(branches, ", ") != strings.Join(want, ", ") {
+		t.Errorf("the branches went %v, want %v", branches, want)
+	}
+	summary := file.Summary()
+	if summary != (coverage.Summary{Statements: 12, CoveredStatements: 11, Branches: 12, CoveredBranches: 8}) {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+}
+
+func outcomesOf(branch coverage.Branch) string {
+	return strconv.FormatInt(branch.Counts[0], 10) + "/" + strconv.FormatInt(branch.Counts[1], 10)
+}
+
+func TestCoverageLCOV(t *testing.T) {
+	var lcov strings.Builder
+	if err := cover(t, coveredScript).WriteLCOV(&lcov); err != nil {
+		t.Fatal(err)
+	}
+	want := `TN:
+SF:covered.lox
+DA:1,1
+DA:2,2
+DA:3,1
+DA:4,1
+DA:6,1
+DA:7,3
+DA:8,1
+DA:9,1
+DA:10,1
+BRDA:2,0,0,1
+BRDA:2,0,1,1
+BRDA:3,1,0,0
+BRDA:3,1,1,1
+BRDA:7,2,0,3
+BRDA:7,2,1,1
+BRDA:8,3,0,0
+BRDA:8,3,1,1
+BRDA:9,4,0,1
+BRDA:9,4,1,0
+BRDA:10,5,0,1
+BRDA:10,5,1,0
+BRF:12
+BRH:8
+LF:9
+LH:9
+end_of_record
+`
+	if lcov.String() != want {
+		t.Errorf("got the LCOV report\n%s\nwant\n%s", lcov.String(), want)
+	}
+}
+
+func TestCoverageHTML(t *testing.T) {
+	var html strings.Builder
+	if err := cover(t, coveredScript).WriteHTML(&html); err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{
+		`<tr class="covered" title="if: then 1, else 1"><td class="number">2</td><td class="hits">2</td>`,
+		`<tr class="partial" title="if: then 0, else 1"><td class="number">3</td>`,
+		`<tr><td class="number">5</td><td class="hits"></td><td class="source">}</td></tr>`,
+		`<td class="source">print i &gt; 2 ? &#34;big&#34; : &#34;small&#34;;</td>`,
+	} {
+		if !strings.Contains(html.String(), row) {
+			t.Errorf("the HTML report lacks %s", row)
+		}
+	}
+}
+
+// TestCoverageTestRunner checks that the test runner covers the test blocks of the scripts too.
+func TestCoverageTestRunner(t *testing.T) {
+	profile := coverage.NewProfile()
+	source := "fun twice(x) { return x * 2; }\nfun unused() { return 0; }\ntest \"twice\" {\n  assert twice(2) == 4;\n}\n"
+	result := testrunner.RunSource("twice.lox", source, testrunner.Options{Coverage: profile})
+	if !result.Passed() {
+		t.Fatalf("the script fails: %v", result.Failures)
+	}
+	var text strings.Builder
+	if err := profile.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+	want := "file       statements            branches\n" +
+		"twice.lox  83.3% (5/6)           100.0% (0/0)\n" +
+		"total      83.3% (5/6)           100.0% (0/0)\n"
+	if text.String() != want {
+		t.Errorf("got the summary\n%s\nwant\n%s", text.String(), want)
+	}
+}
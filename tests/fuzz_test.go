@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"golox/VM"
+	"golox/lox/ast"
+	"golox/lox/lexer"
+	"golox/lox/parser"
+	"golox/testrunner"
+	"golox/utils"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fuzzStepLimit keeps every fuzzed program short, runaway loops included.
+const fuzzStepLimit = 1000
+
+// addSeeds seeds f with the golden and conformance scripts. Crashers found by fuzzing are kept as regression
+// tests under testdata/fuzz/<target>, where `go test` runs them along with the seeds.
+func addSeeds(f *testing.F) {
+	files, err := testrunner.Discover([]string{"lox", conformanceDir})
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(source))
+	}
+}
+
+func parse(source string) ([]ast.Stmt, bool) {
+	reporter := utils.NewReporter(io.Discard)
+	lex := lexer.NewLexer(source)
+	lex.SetReporter(reporter)
+	tokens, lexerError := lex.ScanTokens()
+	p := parser.NewParser(tokens)
+	p.SetReporter(reporter)
+	p.SetSource(source)
+	statements, parseError := p.Parse()
+	return statements, !lexerError.HasError && !parseError.HasError
+}
+
+// FuzzLex checks that the lexer never panics and always ends the tokens with EOF, with non-decreasing lines.
+func FuzzLex(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, source string) {
+		lex := lexer.NewLexer(source)
+		lex.SetReporter(utils.NewReporter(io.Discard))
+		tokens, _ := lex.ScanTokens()
+		if len(tokens) == 0 || tokens[len(tokens)-1].Type0 != lexer.EOF {
+			t.Fatalf("tokens don't end with EOF: %v", tokens)
+		}
+		for index := 1; index < len(tokens); index++ {
+			if tokens[index].Line < tokens[index-1].Line {
+				t.Fatalf("token %d is on line %d, before the previous one on line %d", index, tokens[index].Line, tokens[index-1].Line)
+			}
+		}
+	})
+}
+
+// FuzzParse checks that the parser never panics and that formatting a program it accepts yields source which
+// parses to the same program again.
+func FuzzParse(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, source string) {
+		statements, ok := parse(source)
+		if !ok {
+			return
+		}
+		formatted := ast.Format(statements)
+		reparsed, ok := parse(formatted)
+		if !ok {
+			t.Fatalf("formatted program doesn't parse:\n%s", formatted)
+		}
+		if again := ast.Format(reparsed); again != formatted {
+			t.Fatalf("formatting isn't stable:\n%s\nbecame\n%s", formatted, again)
+		}
+	})
+}
+
+// FuzzRun checks that running any program never panics and stops within the step budget, which tasks share, or
+// on a deadlock.
+func FuzzRun(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, source string) {
+		vm := &VM.VM{}
+		vm.SetStdin(strings.NewReader(""))
+		vm.SetStdout(io.Discard)
+		vm.SetStderr(io.Discard)
+		vm.SetStepLimit(fuzzStepLimit)
+		done := make(chan int, 1)
+		go func() {
+			done <- vm.RunStr(source)
+		}()
+		select {
+		case code := <-done:
+			if code != 0 && code != 65 && code != 70 {
+				t.Fatalf("unexpected exit code %d", code)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("still running after 10s with a budget of %d statements", fuzzStepLimit)
+		}
+	})
+}
+
+// TestParseWithoutEOF checks that the parser copes with tokens it didn't get from the lexer.
+func TestParseWithoutEOF(t *testing.T) {
+	tokens := []lexer.Token{{Type0: lexer.PRINT, Lexeme: "print", Line: 1}, {Type0: lexer.NUMBER, Lexeme: "1", Literal: 1.0, Line: 1}}
+	for _, input := range [][]lexer.Token{nil, tokens} {
+		p := parser.NewParser(input)
+		p.SetReporter(utils.NewReporter(io.Discard))
+		_, parseError := p.Parse()
+		if input != nil && !parseError.HasError {
+			t.Errorf("expected an error for the missing ';'")
+		}
+	}
+}
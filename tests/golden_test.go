@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"golox/testrunner"
+	"strings"
+	"testing"
+)
+
+// TestGolden runs every script under tests/lox and checks it against its `// expect` annotations, and runs the
+// test blocks it declares.
+func TestGolden(t *testing.T) {
+	runGolden(t, testrunner.Options{})
+}
+
+// TestGoldenOptimized checks that the optimizer doesn't change what any golden script does.
+func TestGoldenOptimized(t *testing.T) {
+	runGolden(t, testrunner.Options{Optimize: true})
+}
+
+func runGolden(t *testing.T, options testrunner.Options) {
+	files, err := testrunner.Discover([]string{"lox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		file := file
+		t.Run(strings.TrimPrefix(file, "lox/"), func(t *testing.T) {
+			result := testrunner.RunFile(file, options)
+			for _, failure := range result.Failures {
+				t.Error(failure)
+			}
+			for _, test := range result.Tests {
+				if !test.Passed() {
+					t.Errorf("test %q: %s", test.Name, test.Failure)
+				}
+			}
+		})
+	}
+}
+
+func TestFailingTestBlock(t *testing.T) {
+	source := `
+test "passes" {
+  assert true;
+}
+test "fails" {
+  var expected = "1";
+  assert 1 == expected, "types differ";
+}
+`
+	result := testrunner.RunSource("blocks.lox", source, testrunner.Options{})
+	if len(result.Failures) != 0 {
+		t.Errorf("unexpected failures %v", result.Failures)
+	}
+	if len(result.Tests) != 2 {
+		t.Fatalf("expected 2 test blocks but got %d", len(result.Tests))
+	}
+	if !result.Tests[0].Passed() {
+		t.Errorf("expected test %q to pass, got %q", result.Tests[0].Name, result.Tests[0].Failure)
+	}
+	expected := `[line 7] Error: Assertion failed: 1 == expected (left: 1, right: "1"): types differ`
+	if result.Tests[1].Failure != expected {
+		t.Errorf("expected failure %q but got %q", expected, result.Tests[1].Failure)
+	}
+	if result.Passed() {
+		t.Error("expected the script to fail")
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"bytes"
+	"golox/VM"
+	"golox/lox/ast"
+	"golox/lox/common"
+	"golox/lox/interpreter"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorder records the events of every task of a program as text.
+type recorder struct {
+	interpreter.NoHooks
+	mutex  *sync.Mutex
+	events *[]string
+	task   string
+}
+
+func (r recorder) record(event string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	*r.events = append(*r.events, r.task+event)
+}
+
+func (r recorder) OnStatement(_ *interpreter.Interpreter, stmt ast.Stmt) {
+	r.record("statement " + strings.TrimSuffix(ast.Format([]ast.Stmt{stmt}), "\n"))
+}
+
+func (r recorder) OnCall(i *interpreter.Interpreter, function *interpreter.LoxFunction, arguments []interface{}, _ map[string]interface{}) {
+	var values []string
+	for _, argument := range arguments {
+		values = append(values, interpreter.Repr(argument))
+	}
+	r.record("call " + function.Label() + "(" + strings.Join(values, ", ") + ") at depth " + strconv.Itoa(i.Depth()))
+}
+
+func (r recorder) OnReturn(_ *interpreter.Interpreter, function *interpreter.LoxFunction, value interface{}, err error) {
+	if err != nil {
+		r.record("return " + function.Label() + " failing")
+		return
+	}
+	r.record("return " + function.Label() + " " + interpreter.Repr(value))
+}
+
+func (r recorder) OnError(_ *interpreter.Interpreter, err common.RuntimeError) {
+	r.record("error " + err.Reason)
+}
+
+func (r recorder) OnDefine(_ *interpreter.Interpreter, name string, value interface{}) {
+	r.record("define " + name + " = " + interpreter.Repr(value))
+}
+
+func (r recorder) OnAssign(_ *interpreter.Interpreter, name string, value interface{}) {
+	r.record("assign " + name + " = " + interpreter.Repr(value))
+}
+
+func (r recorder) OnFork(_ *interpreter.Interpreter, _ *interpreter.Interpreter, fork interpreter.Fork) interpreter.Hooks {
+	r.record("fork")
+	return recorder{mutex: r.mutex, events: r.events, task: "task: "}
+}
+
+func TestHooks(t *testing.T) {
+	var events []string
+	hooks := recorder{mutex: &sync.Mutex{}, events: &events}
+	vm := &VM.VM{}
+	var stdout, stderr bytes.Buffer
+	vm.SetStdout(&stdout)
+	vm.SetStderr(&stderr)
+	vm.AddHooks(hooks)
+	source := "var x = 1;\nfun inc(n) { return n + 1; }\nx = inc(x);\nwait(spawn inc(x));\nx = nil + 1;\n"
+	if code := vm.RunStr(source); code != 70 {
+		t.Fatalf("the script exits with %d", code)
+	}
+	want := []string{
+		"statement var x = 1;",
+		"define x = 1",
+		"statement fun inc(n) {\n  return n + 1;\n}",
+		"define inc = <fn inc>",
+		"statement x = inc(x);",
+		"call inc(1) at depth 0",
+		"define n = 1",
+		"statement return n + 1;",
+		"return inc 2",
+		"assign x = 2",
+		"statement wait(spawn inc(x));",
+		"fork",
+		"task: call inc(2) at depth 0",
+		"task: define n = 2",
+		"task: statement return n + 1;",
+		"task: return inc 3",
+		"statement x = nil + 1;",
+		"error Operands must be two numbers or two strings.",
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got the events\n%s\nwant\n%s", strings.Join(events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestHooksExpressions checks that every expression evaluated and every statement executed is reported, the
+// latter once started and once done.
+func TestHooksExpressions(t *testing.T) {
+	counter := &countingHooks{counts: make(map[string]int)}
+	vm := &VM.VM{}
+	vm.SetStdout(io.Discard)
+	vm.AddHooks(counter)
+	vm.RunStr("var a = 1 + 2 * 3;\nif (a > 5 and a < 10) print a; else print -a;\n")
+	var got []string
+	for event, count := range counter.counts {
+		got = append(got, event+" "+strconv.Itoa(count))
+	}
+	sort.Strings(got)
+	want := "branch 2, end 3, expression 13, statement 3"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("got %s, want %s", strings.Join(got, ", "), want)
+	}
+}
+
+type countingHooks struct {
+	interpreter.NoHooks
+	counts map[string]int
+}
+
+func (c *countingHooks) OnStatement(*interpreter.Interpreter, ast.Stmt)    { c.counts["statement"]++ }
+func (c *countingHooks) OnStatementEnd(*interpreter.Interpreter, ast.Stmt) { c.counts["end"]++ }
+func (c *countingHooks) OnExpression(*interpreter.Interpreter, ast.Expr)   { c.counts["expression"]++ }
+func (c *countingHooks) OnBranch(*interpreter.Interpreter, ast.Node, int)  { c.counts["branch"]++ }
+
+const benchmarkedScript = "fun fib(n) { if (n < 2) return n; return fib(n - 1) + fib(n - 2); }\nprint fib(20);\n"
+
+// BenchmarkHooks measures what hooks cost: "none" runs without any, to compare with the interpreter before hooks
+// existed, and "empty" with hooks doing nothing.
+func BenchmarkHooks(b *testing.B) {
+	for _, benchmark := range []struct {
+		name  string
+		hooks interpreter.Hooks
+	}{{"none", nil}, {"empty", interpreter.NoHooks{}}} {
+		b.Run(benchmark.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				vm := &VM.VM{}
+				vm.SetStdout(io.Discard)
+				if benchmark.hooks != nil {
+					vm.AddHooks(benchmark.hooks)
+				}
+				vm.RunStr(benchmarkedScript)
+			}
+		})
+	}
+}
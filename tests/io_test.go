@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"bytes"
+	"golox/VM"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapturedOutput(t *testing.T) {
+	snippet := `
+var name = readLine();
+print "Hello, " + name + "!";
+print readLine();
+print undefined;
+`
+	var stdout, stderr bytes.Buffer
+	vm := &VM.VM{}
+	vm.SetStdin(strings.NewReader("Reader\n"))
+	vm.SetStdout(&stdout)
+	vm.SetStderr(&stderr)
+	vm.RunStr(snippet)
+
+	if got, want := stdout.String(), "Hello, Reader!\nnil\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if got, want := stderr.String(), "[line 5] Error: Undefined variable 'undefined'.\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+}
+
+func TestConcurrentTasksOutput(t *testing.T) {
+	snippet := `
+fun shout(word) { print word; }
+wait(spawn shout("a"), spawn shout("b"), spawn shout("c"));
+`
+	var stdout bytes.Buffer
+	vm := &VM.VM{}
+	vm.SetStdout(&stdout)
+	vm.RunStr(snippet)
+
+	if got := len(strings.Split(strings.TrimSpace(stdout.String()), "\n")); got != 3 {
+		t.Errorf("got %d lines of output, want 3: %q", got, stdout.String())
+	}
+}
+
+// promptedReader gives its line once something was written to the writer it's paired with, or the end of the input
+// after a second.
+type promptedReader struct {
+	prompted chan struct{}
+	line     io.Reader
+}
+
+func (r *promptedReader) Read(p []byte) (int, error) {
+	select {
+	case <-r.prompted:
+		return r.line.Read(p)
+	case <-time.After(time.Second):
+		return 0, io.EOF
+	}
+}
+
+type promptingWriter struct {
+	bytes.Buffer
+	prompted chan struct{}
+}
+
+func (w *promptingWriter) Write(p []byte) (int, error) {
+	if w.Len() == 0 {
+		close(w.prompted)
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestPrintWhileReading(t *testing.T) {
+	snippet := `
+fun prompt() { print "name?"; }
+var task = spawn prompt();
+print readLine();
+wait(task);
+`
+	prompted := make(chan struct{})
+	stdout := &promptingWriter{prompted: prompted}
+	vm := &VM.VM{}
+	vm.SetStdin(&promptedReader{prompted: prompted, line: strings.NewReader("Reader\n")})
+	vm.SetStdout(stdout)
+	vm.RunStr(snippet)
+
+	if got, want := stdout.String(), "name?\nReader\n"; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+}
+
+// TestPromptReadLine checks that readLine in the prompt reads the line after the one it's called from, which the
+// prompt must not take as the next input.
+func TestPromptReadLine(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	vm := &VM.VM{}
+	vm.SetStdin(strings.NewReader("print readLine();\nhello\nprint 1;\n"))
+	vm.SetStdout(&stdout)
+	vm.SetStderr(&stderr)
+	vm.RunPrompt()
+
+	if got, want := stdout.String(), "> hello\n> 1\n> "; got != want {
+		t.Errorf("stdout = %q, want %q", got, want)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("stderr = %q, want nothing", stderr.String())
+	}
+}
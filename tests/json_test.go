@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"bytes"
+	"golox/VM"
+	"golox/lox/ast"
+	"golox/testrunner"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestJSONRoundTrip checks that decoding the JSON encoding of every golden and conformance script gives back the
+// same program.
+func TestJSONRoundTrip(t *testing.T) {
+	files, err := testrunner.Discover([]string{"lox", conformanceDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statements, ok := parse(string(source))
+		if !ok {
+			continue
+		}
+		encoded, err := ast.EncodeJSON(statements)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		decoded, err := ast.DecodeJSON(encoded)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if ast.Format(decoded) != ast.Format(statements) {
+			t.Errorf("%s: decoded program differs:\n%s", file, ast.Format(decoded))
+		}
+		if reencoded, _ := ast.EncodeJSON(decoded); !bytes.Equal(reencoded, encoded) {
+			t.Errorf("%s: encoding of the decoded program differs", file)
+		}
+	}
+}
+
+// TestJSONRun checks that a decoded program runs like its source.
+func TestJSONRun(t *testing.T) {
+	files, err := testrunner.Discover([]string{"lox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statements, ok := parse(string(source))
+		if !ok {
+			continue
+		}
+		encoded, _ := ast.EncodeJSON(statements)
+		decoded, err := ast.DecodeJSON(encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		run := func(runner func(vm *VM.VM) int) (int, string, string) {
+			var stdout, stderr bytes.Buffer
+			vm := &VM.VM{}
+			vm.SetStdin(strings.NewReader(""))
+			vm.SetStdout(&stdout)
+			vm.SetStderr(&stderr)
+			vm.SetStepLimit(testrunner.DefaultStepLimit)
+			return runner(vm), stdout.String(), stderr.String()
+		}
+		code, stdout, stderr := run(func(vm *VM.VM) int { return vm.RunStr(string(source)) })
+		decodedCode, decodedStdout, decodedStderr := run(func(vm *VM.VM) int { return vm.RunAST(decoded) })
+		if decodedCode != code || decodedStdout != stdout || decodedStderr != stderr {
+			t.Errorf("%s: decoded program exits with %d and prints\n%s%s\ninstead of %d and\n%s%s", file,
+				decodedCode, decodedStdout, decodedStderr, code, stdout, stderr)
+		}
+	}
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	tests := []struct {
+		json  string
+		error string
+	}{
+		{`{"version": 1, "statements": []}`, "unsupported AST version 1, expected 6"},
+		{`{"version": 6, "statements": [{"node": "Goto"}]}`, "unknown statement Goto"},
+		{`{"version": 6, "statements": [{"node": "Expression", "expression": null}]}`, "missing expression"},
+		{`{"version": 6, "statements": [{"node": "Expression", "expression": {"node": "Variable", "name": {"type": "WORD", "lexeme": "x", "line": 1}}}]}`, `unknown token type "WORD"`},
+		{`{"version": 6, "statements": [{"node": "Expression", "expression": {"node": "Literal", "type": "NUMBER", "value": [1]}}]}`, "Literal.value must be a literal value"},
+	}
+	for _, test := range tests {
+		_, err := ast.DecodeJSON([]byte(test.json))
+		if err == nil || err.Error() != test.error {
+			t.Errorf("decoding %s: expected error %q but got %v", test.json, test.error, err)
+		}
+	}
+}
+
+// TestJSONDecodedPositions checks that errors in a decoded program are reported at the lines it was encoded with.
+func TestJSONDecodedPositions(t *testing.T) {
+	statements, _ := parse("var a = 1;\n\nprint a + nil;\n")
+	encoded, _ := ast.EncodeJSON(statements)
+	decoded, _ := ast.DecodeJSON(encoded)
+	var stderr bytes.Buffer
+	vm := &VM.VM{}
+	vm.SetStdout(io.Discard)
+	vm.SetStderr(&stderr)
+	if code := vm.RunAST(decoded); code != 70 {
+		t.Errorf("expected exit code 70 but got %d", code)
+	}
+	if expected := "[line 3] Error: Operands must be two numbers or two strings.\n"; stderr.String() != expected {
+		t.Errorf("expected %q but got %q", expected, stderr.String())
+	}
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"golox/lox/ast"
+	"golox/lox/optimizer"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"print 1 + 2 * 3;", "print 7;"},
+		{`print "a" + "b" + 1;`, `print "ab1";`},
+		{"print !true == (2 > 1);", "print false;"},
+		{"print -(4 - 5);", "print 1;"},
+		{"print x + (1 + 1);", "print x + 2;"},
+		{"print true and x;", "print x;"},
+		{"print nil and x;", "print nil;"},
+		{"print 0 or x;", "print 0;"},
+		{"print false or x;", "print x;"},
+		{"print 1 < 2 ? a : b;", "print a;"},
+		{"if (false) print 1; else print 2;", "print 2;"},
+		{"if (!true) print 1;", ""},
+		{"if (x) { if (1 > 2) print 1; print 2; }", "if (x) {\n  print 2;\n}"},
+		{"while (false) print 1;", ""},
+		{"fun f() { return 1; print 2; }", "fun f() {\n  return 1;\n}"},
+		{"while (x) { break; x = x - 1; }", "while (x) {\n  break;\n}"},
+		// runtime errors are left to happen at runtime
+		{`print 1 + nil;`, `print 1 + nil;`},
+		{`print -"a";`, `print -"a";`},
+		// the operands of a failing assert are part of its report
+		{"assert 1 + 1 == 3;", "assert 2 == 3;"},
+	}
+	for _, test := range tests {
+		statements, ok := parse(test.source)
+		if !ok {
+			t.Fatalf("%s doesn't parse", test.source)
+		}
+		optimized := ast.Format(optimizer.Optimize(statements))
+		if test.expected != "" {
+			test.expected += "\n"
+		}
+		if optimized != test.expected {
+			t.Errorf("optimizing %s: expected\n%s\nbut got\n%s", test.source, test.expected, optimized)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"golox/VM"
+	"golox/lox/profiler"
+	"io"
+	"strings"
+	"testing"
+)
+
+const profiledScript = `fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+fun* count(n) {
+  for (var i = 0; i < n; i = i + 1) yield i;
+}
+var total = 0;
+for (x in count(10)) total = total + x;
+print fib(10);
+var double = fun (a) { return a * 2; };
+print double(total);
+`
+
+func profile(t *testing.T, source string) *profiler.Profiler {
+	p := profiler.NewProfiler("profiled.lox")
+	var stdout bytes.Buffer
+	vm := &VM.VM{}
+	vm.SetStdout(&stdout)
+	vm.SetProfiler(p)
+	if code := vm.RunStr(source); code != 0 {
+		t.Fatalf("the script exits with %d", code)
+	}
+	if stdout.String() != "55\n90\n" {
+		t.Errorf("the profiled script prints %q", stdout.String())
+	}
+	return p
+}
+
+func TestProfilerCounts(t *testing.T) {
+	p := profile(t, profiledScript)
+	calls := make(map[string]int64)
+	var total int64
+	for _, function := range p.Functions() {
+		calls[function.Name] = function.Calls
+		if function.Flat > function.Cum {
+			t.Errorf("%s: flat time %v exceeds cumulative time %v", function.Name, function.Flat, function.Cum)
+		}
+		total += int64(function.Flat)
+	}
+	want := map[string]int64{"fib": 177, "count": 1, "anonymous@11": 1, "(script)": 0}
+	for name, count := range want {
+		if got, ok := calls[name]; !ok || got != count {
+			t.Errorf("%s is called %d times, want %d", name, got, count)
+		}
+	}
+	executions := make(map[profiler.Location]int64)
+	for _, line := range p.Lines() {
+		executions[line.Location] = line.Executions
+	}
+	wantExecutions := map[profiler.Location]int64{
+		{Function: "fib", Line: 2}:       266, // 177 if statements, 89 of them returning
+		{Function: "fib", Line: 3}:       88,
+		{Function: "count", Line: 6}:     13, // the block, initializer and loop of the for, and 10 yields
+		{Function: "(script)", Line: 9}:  11,
+		{Function: "(script)", Line: 10}: 1,
+	}
+	for location, count := range wantExecutions {
+		if got := executions[location]; got != count {
+			t.Errorf("%s:%d runs %d statements, want %d", location.Function, location.Line, got, count)
+		}
+	}
+	if total == 0 {
+		t.Errorf("no time was charged")
+	}
+}
+
+func TestProfilerTop(t *testing.T) {
+	p := profile(t, profiledScript)
+	var top strings.Builder
+	if err := p.WriteTop(&top, 2); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(top.String()), "\n")
+	// a title, a header and two rows for functions, then a blank line and the same for lines
+	if len(lines) != 9 {
+		t.Fatalf("the summary has %d lines, want 9:\n%s", len(lines), top.String())
+	}
+	if !strings.HasPrefix(lines[0], "Showing top 2 functions of 4") || !strings.HasPrefix(lines[5], "Showing top 2 lines of") {
+		t.Errorf("unexpected summary titles:\n%s", top.String())
+	}
+	if !strings.Contains(top.String(), "fib") {
+		t.Errorf("fib isn't among the top functions:\n%s", top.String())
+	}
+}
+
+// TestProfilerPprof checks that the profile is a gzipped protocol buffer holding the names of the profiled
+// functions in its string table.
+func TestProfilerPprof(t *testing.T) {
+	p := profile(t, profiledScript)
+	var output bytes.Buffer
+	if err := p.WriteProfile(&output); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := gzip.NewReader(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := make(map[uint64]int)
+	strs := make(map[string]bool)
+	for len(data) > 0 {
+		key, n := uvarint(data)
+		if n == 0 {
+			t.Fatal("truncated field key")
+		}
+		data = data[n:]
+		field, wireType := key>>3, key&7
+		switch wireType {
+		case 0:
+			_, n = uvarint(data)
+			if n == 0 {
+				t.Fatal("truncated varint")
+			}
+			data = data[n:]
+		case 2:
+			length, n := uvarint(data)
+			if n == 0 || uint64(len(data)-n) < length {
+				t.Fatal("truncated bytes")
+			}
+			if field == 6 {
+				strs[string(data[n:n+int(length)])] = true
+			}
+			data = data[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d", wireType)
+		}
+		fields[field]++
+	}
+	for _, field := range []uint64{1, 2, 4, 5, 6, 11} {
+		if fields[field] == 0 {
+			t.Errorf("the profile has no field %d", field)
+		}
+	}
+	if fields[1] != 2 {
+		t.Errorf("the profile has %d sample types, want 2", fields[1])
+	}
+	for _, name := range []string{"fib", "count", "(script)", "profiled.lox", "nanoseconds"} {
+		if !strs[name] {
+			t.Errorf("the string table lacks %q", name)
+		}
+	}
+}
+
+// uvarint decodes a protocol buffer varint, returning 0 bytes read if data is truncated.
+func uvarint(data []byte) (uint64, int) {
+	var value uint64
+	for index, b := range data {
+		if index == 10 {
+			return 0, 0
+		}
+		value |= uint64(b&0x7f) << (7 * index)
+		if b < 0x80 {
+			return value, index + 1
+		}
+	}
+	return 0, 0
+}
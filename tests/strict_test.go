@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"bytes"
+	"golox/VM"
+	"golox/testrunner"
+	"strings"
+	"testing"
+)
+
+// TestStrict checks that SetStrict finds an undeclared global in a branch which never runs, like the pragma does.
+func TestStrict(t *testing.T) {
+	source := "var debug = false;\nif (debug) print debgu;\nprint \"done\";\n"
+	for _, test := range []struct {
+		strict         bool
+		code           int
+		stdout, stderr string
+	}{
+		{false, 0, "done\n", ""},
+		{true, 65, "", "[line 2] Error at 'debgu': Undefined variable 'debgu'. Did you mean 'debug'?\n"},
+	} {
+		var stdout, stderr bytes.Buffer
+		vm := &VM.VM{}
+		vm.SetStdout(&stdout)
+		vm.SetStderr(&stderr)
+		vm.SetStrict(test.strict)
+		if code := vm.RunStr(source); code != test.code || stdout.String() != test.stdout || stderr.String() != test.stderr {
+			t.Errorf("strict %v: exit %d, stdout %q, stderr %q, want exit %d, stdout %q, stderr %q", test.strict, code, stdout.String(), stderr.String(), test.code, test.stdout, test.stderr)
+		}
+	}
+}
+
+// TestStrictTestRunner checks that the test runner's Strict option fails a script whose expectations only hold
+// when undeclared globals are allowed.
+func TestStrictTestRunner(t *testing.T) {
+	source := "var debug = false;\nif (debug) print debgu;\nprint \"done\"; // expect: done\n"
+	if result := testrunner.RunSource("debug.lox", source, testrunner.Options{}); !result.Passed() {
+		t.Errorf("without Strict the script fails:\n%v", result.Failures)
+	}
+	result := testrunner.RunSource("debug.lox", source, testrunner.Options{Strict: true})
+	if result.Passed() {
+		t.Fatal("with Strict the script passes")
+	}
+	want := "[line 2] Error at 'debgu': Undefined variable 'debgu'. Did you mean 'debug'?"
+	found := false
+	for _, failure := range result.Failures {
+		found = found || strings.Contains(failure, want)
+	}
+	if !found {
+		t.Errorf("with Strict the failures %q don't mention %q", result.Failures, want)
+	}
+}
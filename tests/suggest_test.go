@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"bytes"
+	"golox/VM"
+	"golox/lox/catalog"
+	"golox/lox/suggest"
+	"golox/lox/transpiler/loxrt"
+	"golox/utils"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	for _, test := range []struct {
+		a, b     string
+		distance int
+	}{
+		{"", "", 0},
+		{"print", "print", 0},
+		{"pritn", "print", 1},
+		{"lenght", "length", 1},
+		{"count", "cont", 1},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 3},
+	} {
+		if got := suggest.Distance(test.a, test.b); got != test.distance {
+			t.Errorf("Distance(%q, %q) = %d, want %d", test.a, test.b, got, test.distance)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	names := []string{"length", "width", "height", "x", "y"}
+	for _, test := range []struct {
+		name, want string
+	}{
+		{"lenght", "length"},
+		{"widht", "width"},
+		{"pritn", "print"},
+		{"ture", "true"},
+		{"length", ""}, // the name itself
+		{"z", ""},      // too short for a suggestion
+		{"depth", ""},
+	} {
+		if got := suggest.Closest(test.name, append(names, suggest.Keywords()...)); got != test.want {
+			t.Errorf("Closest(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	for _, test := range []struct {
+		source, stderr string
+		want           utils.Diagnostic
+	}{{
+		"pritn \"hello\";\n",
+		"[line 1] Error at '\"hello\"': Expect ';' after expression. Did you mean 'print'?\n",
+		utils.Diagnostic{Line: 1, Where: "at '\"hello\"'", Code: catalog.MissingSemicolon, Message: "Expect ';' after expression.", Fix: &utils.Fix{Line: 1, Column: 1, Old: "pritn", New: "print"}},
+	}, {
+		"var total = 0;\n{\n  var count = 1;\n  cuont = 2;\n}\n",
+		"[line 4] Error: Undefined variable 'cuont'. Did you mean 'count'?\n",
+		utils.Diagnostic{Line: 4, Code: catalog.UndefinedVariable, Message: "Undefined variable 'cuont'.", Fix: &utils.Fix{Line: 4, Column: 3, Old: "cuont", New: "count"}},
+	}, {
+		"var count = 1;\n/* a\ncomment */ print cuont + 1;\n",
+		"[line 3] Error: Undefined variable 'cuont'. Did you mean 'count'?\n",
+		utils.Diagnostic{Line: 3, Code: catalog.UndefinedVariable, Message: "Undefined variable 'cuont'.", Fix: &utils.Fix{Line: 3, Column: 18, Old: "cuont", New: "count"}},
+	}, {
+		"print nothing;\n",
+		"[line 1] Error: Undefined variable 'nothing'.\n",
+		utils.Diagnostic{Line: 1, Code: catalog.UndefinedVariable, Message: "Undefined variable 'nothing'."},
+	}} {
+		var stderr bytes.Buffer
+		vm := &VM.VM{}
+		vm.SetStderr(&stderr)
+		vm.RunStr(test.source)
+		if stderr.String() != test.stderr {
+			t.Errorf("%q reports %q, want %q", test.source, stderr.String(), test.stderr)
+		}
+		if got := vm.Diagnostics(); len(got) != 1 || !reflect.DeepEqual(got[0], test.want) {
+			t.Errorf("%q has the diagnostics %+v, want %+v", test.source, got, test.want)
+		}
+	}
+}
+
+// TestRuntimeKeywords checks that transpiled programs suggest the same keywords as the interpreter.
+func TestRuntimeKeywords(t *testing.T) {
+	if !reflect.DeepEqual(loxrt.ExpressionKeywords, suggest.ExpressionKeywords()) {
+		t.Errorf("loxrt.ExpressionKeywords = %v, want %v", loxrt.ExpressionKeywords, suggest.ExpressionKeywords())
+	}
+}
+
+// TestReadSuggestsNoStatementKeyword checks that reading an undefined name only suggests keywords which can stand
+// where it is, at the start of an expression.
+func TestReadSuggestsNoStatementKeyword(t *testing.T) {
+	expression := make(map[string]bool)
+	for _, keyword := range suggest.ExpressionKeywords() {
+		expression[keyword] = true
+	}
+	names := []string{"fields"}
+	for _, keyword := range suggest.Keywords() {
+		names = append(names, keyword+"s")
+	}
+	for _, name := range names {
+		for _, source := range []string{"print " + name + ";\n", "\"use strict\";\nprint " + name + ";\n"} {
+			vm := &VM.VM{}
+			vm.SetStderr(io.Discard)
+			vm.RunStr(source)
+			for _, diagnostic := range vm.Diagnostics() {
+				if diagnostic.Fix != nil && !expression[diagnostic.Fix.New] {
+					t.Errorf("%q suggests %q", source, diagnostic.Fix.New)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"golox/VM"
+	"golox/lox/trace"
+	"strings"
+	"testing"
+)
+
+const tracedScript = `fun add(a, b = 1) {
+  var sum = a + b;
+  return sum;
+}
+print add(2, b: 3);
+fun boom() { return 1 / nil; }
+boom();
+`
+
+func traceRun(t *testing.T, source string, format trace.Format, environment bool) string {
+	var output, stdout, stderr bytes.Buffer
+	tracer := trace.NewTracer(&output, format)
+	tracer.SetEnvironment(environment)
+	vm := &VM.VM{}
+	vm.SetStdout(&stdout)
+	vm.SetStderr(&stderr)
+	vm.SetTracer(tracer)
+	vm.RunStr(source)
+	if tracer.Err() != nil {
+		t.Fatal(tracer.Err())
+	}
+	return output.String()
+}
+
+func TestTraceText(t *testing.T) {
+	want := `[line 1] depth 0: fun add(a, b = 1) {
+[line 5] depth 0: print add(2, b: 3);
+[line 5] depth 0: call add(2, b: 3)
+  [line 2] depth 1: var sum = a + b;
+  [line 3] depth 1: return sum;
+[line 5] depth 0: add returned 5
+[line 6] depth 0: fun boom() {
+[line 7] depth 0: boom();
+[line 7] depth 0: call boom()
+  [line 6] depth 1: return 1 / nil;
+[line 7] depth 0: boom raised Operands must be numbers.
+`
+	if got := traceRun(t, tracedScript, trace.Text, false); got != want {
+		t.Errorf("got the trace\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTraceEnvironment(t *testing.T) {
+	got := traceRun(t, tracedScript, trace.Text, true)
+	want := `  [line 3] depth 1: return sum;
+      a = 2
+      b = 3
+      sum = 5
+`
+	if !strings.Contains(got, want) {
+		t.Errorf("the trace lacks the bindings of the function's scope:\n%s", got)
+	}
+	if strings.Contains(got, "clock") {
+		t.Errorf("the trace lists the natives:\n%s", got)
+	}
+}
+
+func TestTraceJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(traceRun(t, tracedScript, trace.JSON, true), "\n"), "\n")
+	if len(lines) != 11 {
+		t.Fatalf("got %d events, want 11", len(lines))
+	}
+	var events []trace.Event
+	for _, line := range lines {
+		var event trace.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+		events = append(events, event)
+	}
+	call := events[2]
+	if call.Event != "call" || call.Function != "add" || call.Line != 5 || strings.Join(call.Arguments, ",") != "2,b: 3" {
+		t.Errorf("unexpected call event %+v", call)
+	}
+	ret := events[5]
+	if ret.Event != "return" || ret.Value != "5" || ret.Depth != 0 || ret.Error != "" {
+		t.Errorf("unexpected return event %+v", ret)
+	}
+	if events[4].Environment["sum"] != "5" {
+		t.Errorf("unexpected environment %v", events[4].Environment)
+	}
+	if failed := events[10]; failed.Event != "return" || failed.Error != "Operands must be numbers." || failed.Value != "" {
+		t.Errorf("unexpected return event %+v", failed)
+	}
+}
+
+// TestTraceTasks checks that the events of spawned tasks are told apart from those of the main script.
+func TestTraceTasks(t *testing.T) {
+	got := traceRun(t, "fun f(x) { return x; }\nprint wait(spawn f(1));\n", trace.Text, false)
+	want := `[line 1] depth 0: fun f(x) {
+[line 2] depth 0: print wait(spawn f(1));
+task 1 [line 2] depth 0: call f(1)
+  task 1 [line 1] depth 1: return x;
+task 1 [line 2] depth 0: f returned 1
+`
+	if got != want {
+		t.Errorf("got the trace\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"golox/VM"
+	"golox/lox/transpiler"
+	"golox/testrunner"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// transpiledRun is what running a script printed and its exit status.
+type transpiledRun struct {
+	stdout   string
+	stderr   string
+	exitCode int
+}
+
+// TestTranspile transpiles every script of tests/lox and tests/conformance which compiles and checks that the Go
+// program does exactly what the interpreter does. All the programs are linked into a single binary, which runs one
+// of them per process.
+func TestTranspile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds Go programs")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	files, err := testrunner.Discover([]string{"lox", conformanceDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := transpiler.WriteRuntime(filepath.Join(dir, "loxrt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module transpiled\n\ngo 1.19\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expected := make(map[string]transpiledRun)
+	var imports, programs strings.Builder
+	for index, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		run := interpret(string(source))
+		// step limits don't exist in Go programs
+		if run.exitCode == 65 || strings.Contains(run.stderr, "Step limit") {
+			continue
+		}
+		statements, ok := (&VM.VM{}).CompileStr(string(source))
+		if !ok {
+			t.Fatalf("%s doesn't compile", file)
+		}
+		pkg := "p" + strconv.Itoa(index)
+		code, err := transpiler.Transpile(statements, transpiler.Options{Runtime: "transpiled/loxrt", Source: file, Package: pkg})
+		if err != nil {
+			t.Fatalf("transpiling %s: %v", file, err)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, pkg), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, pkg, "program.go"), code, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		expected[file] = run
+		imports.WriteString("\t" + pkg + " \"transpiled/" + pkg + "\"\n")
+		programs.WriteString("\t" + strconv.Quote(file) + ": " + pkg + ".Program,\n")
+	}
+	main := "package main\n\nimport (\n\t\"os\"\n\trt \"transpiled/loxrt\"\n" + imports.String() + ")\n\n" +
+		"var programs = map[string]func(*rt.Thread){\n" + programs.String() + "}\n\n" +
+		"func main() {\n\trt.Main(programs[os.Args[1]])\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(main), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	build := exec.Command(goTool, "build", "-o", "programs", ".")
+	build.Dir = dir
+	if output, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, output)
+	}
+	for file, want := range expected {
+		got := execute(filepath.Join(dir, "programs"), file)
+		if got != want {
+			t.Errorf("%s: the interpreter exits with %d and prints\n%s%s\nbut the Go program exits with %d and prints\n%s%s", file, want.exitCode, want.stdout, want.stderr, got.exitCode, got.stdout, got.stderr)
+		}
+	}
+	t.Logf("%d of %d scripts transpiled", len(expected), len(files))
+}
+
+func interpret(source string) transpiledRun {
+	var stdout, stderr bytes.Buffer
+	vm := &VM.VM{}
+	vm.SetStdin(strings.NewReader(""))
+	vm.SetStdout(&stdout)
+	vm.SetStderr(&stderr)
+	vm.SetStepLimit(testrunner.DefaultStepLimit)
+	exitCode := vm.RunStr(source)
+	return transpiledRun{stdout: stdout.String(), stderr: stderr.String(), exitCode: exitCode}
+}
+
+func execute(binary string, args ...string) transpiledRun {
+	var stdout, stderr bytes.Buffer
+	command := exec.Command(binary, args...)
+	command.Stdin = strings.NewReader("")
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	exitCode := 0
+	var exitError *exec.ExitError
+	if err := command.Run(); errors.As(err, &exitError) {
+		exitCode = exitError.ExitCode()
+	} else if err != nil {
+		exitCode = -1
+	}
+	return transpiledRun{stdout: stdout.String(), stderr: stderr.String(), exitCode: exitCode}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"bytes"
+	"golox/lox/ast"
+	"golox/lox/lexer"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGeneratedASTUpToDate fails when the checked-in syntax tree differs from what ast.schema generates; run
+// `go generate ./lox/ast` to fix it.
+func TestGeneratedASTUpToDate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the generator with go run")
+	}
+	output := t.TempDir()
+	command := exec.Command("go", "run", filepath.Join("..", "tool", "genAST.go"), filepath.Join("..", "lox", "ast", "ast.schema"), output)
+	if message, err := command.CombinedOutput(); err != nil {
+		t.Fatalf("generator failed: %v\n%s", err, message)
+	}
+	generated, err := os.ReadDir(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generated) == 0 {
+		t.Fatal("generator wrote no files")
+	}
+	for _, file := range generated {
+		expected, _ := os.ReadFile(filepath.Join(output, file.Name()))
+		actual, err := os.ReadFile(filepath.Join("..", "lox", "ast", file.Name()))
+		if err != nil || !bytes.Equal(expected, actual) {
+			t.Errorf("lox/ast/%s is out of date, run go generate ./lox/ast", file.Name())
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	statements, _ := parse("fun f(a, b = x) { match (a) { case [y] if y > z => print y; } }\nvar w = f(1, ...v);")
+	names := make([]string, 0)
+	for _, statement := range statements {
+		ast.Inspect(statement, func(node ast.Node) bool {
+			if variable, ok := node.(*ast.Variable); ok {
+				names = append(names, variable.Name.Lexeme)
+			}
+			// don't look inside match guards
+			_, isBinary := node.(*ast.Binary)
+			return !isBinary
+		})
+	}
+	if got := strings.Join(names, " "); got != "x a y f v" {
+		t.Errorf("expected the variables x a y f v but got %s", got)
+	}
+}
+
+// TestTransform folds additions of number literals and removes print statements.
+func TestTransform(t *testing.T) {
+	statements, _ := parse("var a = 1 + 2 + x;\nprint a;\nif (a) { print a; a = 3 + 4; }\n")
+	fold := func(node ast.Node) ast.Node {
+		switch n := node.(type) {
+		case *ast.Print:
+			return nil
+		case *ast.Binary:
+			left, leftOk := n.Left.(*ast.Literal)
+			right, rightOk := n.Right.(*ast.Literal)
+			if leftOk && rightOk && n.Operator.Type0 == lexer.PLUS {
+				return &ast.Literal{Type: lexer.NUMBER, Value: left.Value.(float64) + right.Value.(float64)}
+			}
+		}
+		return node
+	}
+	transformed := make([]ast.Stmt, 0)
+	for _, statement := range statements {
+		if result := ast.Transform(statement, fold); result != nil {
+			transformed = append(transformed, result.(ast.Stmt))
+		}
+	}
+	expected := "var a = 3 + x;\nif (a) {\n  a = 7;\n}\n"
+	if formatted := ast.Format(transformed); formatted != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, formatted)
+	}
+}
+
+// TestBaseVisitor checks that a visitor embedding BaseVisitor only needs the methods it uses.
+func TestBaseVisitor(t *testing.T) {
+	var visitor struct {
+		ast.BaseVisitor
+	}
+	var _ ast.Visitor = visitor
+	var _ ast.StmtVisitor = visitor
+	var _ ast.PatternVisitor = visitor
+	if value, err := (&ast.Literal{Value: 1.0}).Accept(visitor); value != nil || err != nil {
+		t.Errorf("expected nothing but got %v, %v", value, err)
+	}
+}
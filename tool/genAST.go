@@ -2,101 +2,397 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	log "github.com/sirupsen/logrus"
+	"go/format"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// genAST generates the syntax tree of Lox from a schema, see lox/ast/ast.schema for its format: one file per kind
+// of node holding the node types and their visitor interface, and walk.go with the traversals shared by all kinds.
 func main() {
-	if len(os.Args) != 2 {
-		log.Error("Usage: go run genAST.go <output directory>")
+	if len(os.Args) != 3 {
+		log.Error("Usage: go run genAST.go <schema> <output directory>")
 		os.Exit(64)
 	}
-	outputDir := os.Args[1]
-	defineAst(outputDir, "Expr", []string{
-		"Binary   :  Left Expr, Operator Token, Right Expr",
-		"Grouping :  Expression Expr",
-		"Literal  :  Type TokenType// golang is static typed language cache type to avoid unnecessary type switch cost, Value interface{}",
-		"Unary    :  Operator Token, Right Expr",
-		"Ternary  :  ConditionalExpr Expr, ThenExpr Expr, ElseExpr Expr"})
-
-	path := outputDir + "/" + "expr.go"
-	err := exec.Command("go", "fmt", path).Run()
+	kinds, err := parseSchema(os.Args[1])
 	if err != nil {
 		log.Error(err)
-		return
+		os.Exit(65)
+	}
+	outputDir := os.Args[2]
+	source := filepath.Base(os.Args[1])
+	files := map[string][]byte{"walk.go": defineWalk(source, kinds)}
+	for _, kind := range kinds {
+		files[strings.ToLower(kind.name)+".go"] = defineAst(source, kind)
+	}
+	for name, code := range files {
+		formatted, err := format.Source(code)
+		if err != nil {
+			log.Error(name + ": " + err.Error())
+			os.Exit(70)
+		}
+		if err := os.WriteFile(filepath.Join(outputDir, name), formatted, 0644); err != nil {
+			log.Error(err)
+			os.Exit(74)
+		}
 	}
+}
+
+// kind is one kind of node, e.g. Expr, with its visitor interface.
+type kind struct {
+	name    string
+	visitor string
+	param   string
+	doc     []string
+	decls   []*decl
+}
+
+// decl is a node type, or a plain struct when node is false.
+type decl struct {
+	name   string
+	node   bool
+	doc    []string
+	fields []field
+}
 
-	//defineAst(outputDir, "Stmt", []string{
-	//	"Expression :  expression Expr",
-	//	"Print      :  expression Expr"})
+type field struct {
+	name   string
+	type0  string
+	source string // the field as written in the schema, comment included
 }
 
-func defineAst(outputDir string, baseName string, types []string) {
-	path := outputDir + "/" + strings.ToLower(baseName) + ".go"
-	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0755)
+func parseSchema(path string) ([]*kind, error) {
+	fd, err := os.Open(path)
 	if err != nil {
-		log.Error("Cannot create or open file with error " + err.Error())
-		os.Exit(64)
+		return nil, err
+	}
+	defer fd.Close()
+	kinds := make([]*kind, 0)
+	var doc []string
+	var current *decl
+	scanner := bufio.NewScanner(fd)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		fail := func(message string) ([]*kind, error) {
+			return nil, errors.New(path + ":" + strconv.Itoa(lineNumber) + ": " + message)
+		}
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case current != nil && line == "}":
+			current = nil
+		case current != nil:
+			words := strings.Fields(line)
+			if len(words) < 2 {
+				return fail("expected a field")
+			}
+			current.fields = append(current.fields, field{name: words[0], type0: words[1], source: line})
+		case strings.HasPrefix(line, "//"):
+			doc = append(doc, line)
+		default:
+			words := strings.Fields(line)
+			switch {
+			case len(words) == 4 && words[0] == "interface":
+				kinds = append(kinds, &kind{name: words[1], visitor: words[2], param: words[3], doc: doc})
+			case len(words) == 3 && (words[0] == "node" || words[0] == "struct") && words[2] == "{":
+				if len(kinds) == 0 {
+					return fail("declaration before the first interface")
+				}
+				current = &decl{name: words[1], node: words[0] == "node", doc: doc}
+				kinds[len(kinds)-1].decls = append(kinds[len(kinds)-1].decls, current)
+			default:
+				return fail("expected an interface, node or struct declaration")
+			}
+			doc = nil
+		}
+	}
+	if current != nil {
+		return nil, errors.New(path + ": unterminated declaration of " + current.name)
+	}
+	return kinds, scanner.Err()
+}
+
+func header(w *bytes.Buffer, source string) {
+	w.WriteString("// Code generated by tool/genAST.go from " + source + ". DO NOT EDIT.\n\n")
+	w.WriteString("package ast\n\n")
+}
+
+func writeDoc(w *bytes.Buffer, doc []string) {
+	for _, line := range doc {
+		w.WriteString(line + "\n")
 	}
-	w := bufio.NewWriter(fd)
-	w.WriteString("package ast\n")
-	w.WriteString("\n")
+}
 
-	w.WriteString("import .\"golox/lox/lexer\"")
-	w.WriteString("\n")
+// visitMethod is the name of the visitor method for a node, e.g. VisitBinaryExpr, or VisitFunctionExpr for a node
+// whose name already ends with the kind.
+func visitMethod(kind *kind, node *decl) string {
+	if strings.HasSuffix(node.name, kind.name) {
+		return "Visit" + node.name
+	}
+	return "Visit" + node.name + kind.name
+}
 
-	w.WriteString("\n")
+func defineAst(source string, kind *kind) []byte {
+	w := &bytes.Buffer{}
+	header(w, source)
+	w.WriteString("import . \"golox/lox/lexer\"\n\n")
 
-	w.WriteString("type " + baseName + " interface {\n")
-	w.WriteString("    Accept(v Visitor) (interface{}, error)\n")
-	w.WriteString("}\n")
+	writeDoc(w, kind.doc)
+	w.WriteString("type " + kind.name + " interface {\n")
+	w.WriteString("Accept(v " + kind.visitor + ") (interface{}, error)\n")
+	w.WriteString("}\n\n")
 
-	w.WriteString("\n")
+	defineVisitor(w, kind)
 
-	defineVisitor(w, baseName, types)
+	for _, decl := range kind.decls {
+		defineType(w, decl)
+		if decl.node {
+			defineTypeMethod(w, kind, decl)
+		}
+	}
+	return w.Bytes()
+}
 
-	for _, type0 := range types {
-		typeName := strings.TrimSpace(strings.Split(type0, ":")[0])
-		fields := strings.TrimSpace(strings.Split(type0, ":")[1])
-		fieldList := strings.Split(fields, ",")
+func defineVisitor(w *bytes.Buffer, kind *kind) {
+	w.WriteString("type " + kind.visitor + " interface {\n")
+	for _, decl := range kind.decls {
+		if decl.node {
+			w.WriteString(visitMethod(kind, decl) + "(" + kind.param + " *" + decl.name + ") (interface{}, error)\n")
+		}
+	}
+	w.WriteString("}\n\n")
+}
 
-		defineType(w, baseName, typeName, fieldList)
-		defineTypeMethod(w, baseName, typeName)
+func defineType(w *bytes.Buffer, decl *decl) {
+	writeDoc(w, decl.doc)
+	w.WriteString("type " + decl.name + " struct {\n")
+	for _, field := range decl.fields {
+		w.WriteString(field.source + "\n")
 	}
-	w.WriteString("\n")
-	w.Flush()
-	fd.Close()
+	w.WriteString("}\n\n")
+}
 
+func defineTypeMethod(w *bytes.Buffer, kind *kind, decl *decl) {
+	w.WriteString("func (t *" + decl.name + ") Accept(v " + kind.visitor + ") (interface{}, error) {\n")
+	w.WriteString("return v." + visitMethod(kind, decl) + "(t)\n")
+	w.WriteString("}\n\n")
 }
 
-func defineVisitor(w *bufio.Writer, baseName string, types []string) {
-	w.WriteString("type Visitor interface {\n")
+// The ways a field can hold children.
+const (
+	noChildren   = iota
+	childNode    // Expr, Stmt or Pattern
+	childNodes   // []Expr, []Stmt or []Pattern
+	childPointer // a pointer to a node type, e.g. *Call
+	childStruct  // a plain struct holding nodes, e.g. Param
+	childStructs // a slice of those
+)
+
+// tree knows which types of the schema are nodes, so it can tell the children of a node.
+type tree struct {
+	kinds   map[string]*kind
+	nodes   map[string]bool
+	structs map[string]*decl
+}
 
-	for _, typeName := range types {
-		typeName = strings.TrimSpace(strings.Split(typeName, ":")[0])
+func newTree(kinds []*kind) *tree {
+	t := &tree{kinds: map[string]*kind{}, nodes: map[string]bool{}, structs: map[string]*decl{}}
+	for _, kind := range kinds {
+		t.kinds[kind.name] = kind
+		for _, decl := range kind.decls {
+			if decl.node {
+				t.nodes[decl.name] = true
+			} else {
+				t.structs[decl.name] = decl
+			}
+		}
+	}
+	return t
+}
 
-		w.WriteString("    Visit" + typeName + baseName + "(expr *" + typeName + ") (interface{}, error)")
-		w.WriteString("\n")
+// children classifies a field type and returns the type of the children it holds.
+func (t *tree) children(type0 string) (int, string) {
+	element := strings.TrimPrefix(type0, "[]")
+	isSlice := element != type0
+	switch {
+	case t.kinds[element] != nil && isSlice:
+		return childNodes, element
+	case t.kinds[element] != nil:
+		return childNode, element
+	case t.structs[element] != nil && isSlice:
+		return childStructs, element
+	case t.structs[element] != nil:
+		return childStruct, element
+	case !isSlice && strings.HasPrefix(type0, "*") && t.nodes[type0[1:]]:
+		return childPointer, type0
 	}
-	w.WriteString("}\n")
-	w.WriteString("\n")
+	return noChildren, ""
 }
 
-func defineType(w *bufio.Writer, baseName string, className string, fieldList []string) {
-	w.WriteString("type " + className + " struct {\n")
-	for _, field := range fieldList {
-		field = strings.TrimSpace(field)
-		w.WriteString("    " + field + "\n")
+func defineWalk(source string, kinds []*kind) []byte {
+	t := newTree(kinds)
+	w := &bytes.Buffer{}
+	header(w, source)
+
+	names := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		names = append(names, kind.name)
 	}
-	w.WriteString("}\n")
-	w.WriteString("\n")
+	w.WriteString("// Node is a node of the syntax tree: " + strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1] + ".\n")
+	w.WriteString("type Node interface{}\n\n")
+
+	w.WriteString(`// Walker is called by Walk for every node, see Walk.
+type Walker interface {
+	Visit(node Node) (w Walker)
 }
 
-func defineTypeMethod(w *bufio.Writer, baseName string, className string) {
-	w.WriteString("func (t *" + className + ") Accept(v Visitor) (interface{}, error) {\n")
-	w.WriteString("    return v.Visit" + className + baseName + "(t)\n")
-	w.WriteString("}\n")
-	w.WriteString("\n")
+// Walk traverses the tree rooted at node depth-first. It starts by calling w.Visit(node); unless that returns nil,
+// Walk then walks every child of node with the walker returned, and finally calls its Visit with nil.
+func Walk(w Walker, node Node) {
+	if w = w.Visit(node); w == nil {
+		return
+	}
+	switch n := node.(type) {
+`)
+	for _, kind := range kinds {
+		for _, decl := range kind.decls {
+			if !decl.node || !t.hasChildren(decl) {
+				continue
+			}
+			w.WriteString("case *" + decl.name + ":\n")
+			t.walkFields(w, "n", decl)
+		}
+	}
+	w.WriteString("}\nw.Visit(nil)\n}\n\n")
+
+	for _, decl := range t.sortedStructs(kinds) {
+		w.WriteString("func walk" + decl.name + "(w Walker, n " + decl.name + ") {\n")
+		t.walkFields(w, "n", decl)
+		w.WriteString("}\n\n")
+	}
+
+	w.WriteString(`type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Walker {
+	if node != nil && f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node depth-first, calling f for every node. The children of a node are
+// skipped when f returns false for it.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Transform rewrites the tree rooted at node bottom-up and returns the new root. The children of a node are
+// transformed first and replaced by the results, then f is called on the node itself and its result takes the
+// node's place. f returns its argument to keep a node, or another node of the same kind: an Expr for an Expr, a
+// *Call for a *Call and so on. Returning nil removes a node from a list and clears an optional field. The tree is
+// rewritten in place.
+func Transform(node Node, f func(Node) Node) Node {
+	switch n := node.(type) {
+	case nil:
+		return nil
+`)
+	for _, kind := range kinds {
+		for _, decl := range kind.decls {
+			if !decl.node || !t.hasChildren(decl) {
+				continue
+			}
+			w.WriteString("case *" + decl.name + ":\n")
+			t.transformFields(w, "n", decl)
+		}
+	}
+	w.WriteString("}\nreturn f(node)\n}\n\n")
+
+	for _, kind := range kinds {
+		w.WriteString("func transform" + kind.name + "(node " + kind.name + ", f func(Node) Node) " + kind.name + " {\n")
+		w.WriteString("if node == nil {\nreturn nil\n}\n")
+		w.WriteString("result, _ := Transform(node, f).(" + kind.name + ")\nreturn result\n}\n\n")
+
+		w.WriteString("func transform" + kind.name + "List(nodes []" + kind.name + ", f func(Node) Node) []" + kind.name + " {\n")
+		w.WriteString("result := nodes[:0]\nfor _, node := range nodes {\n")
+		w.WriteString("if node = transform" + kind.name + "(node, f); node != nil {\nresult = append(result, node)\n}\n}\n")
+		w.WriteString("return result\n}\n\n")
+	}
+	for _, decl := range t.sortedStructs(kinds) {
+		w.WriteString("func transform" + decl.name + "(n *" + decl.name + ", f func(Node) Node) {\n")
+		t.transformFields(w, "n", decl)
+		w.WriteString("}\n\n")
+	}
+
+	w.WriteString("// BaseVisitor implements every visitor interface by doing nothing. Embedding it lets a visitor implement only\n")
+	w.WriteString("// the methods it cares about.\n")
+	w.WriteString("type BaseVisitor struct{}\n\n")
+	for _, kind := range kinds {
+		for _, decl := range kind.decls {
+			if decl.node {
+				w.WriteString("func (BaseVisitor) " + visitMethod(kind, decl) + "(*" + decl.name + ") (interface{}, error) {\nreturn nil, nil\n}\n\n")
+			}
+		}
+	}
+	return w.Bytes()
+}
+
+func (t *tree) hasChildren(decl *decl) bool {
+	for _, field := range decl.fields {
+		if how, _ := t.children(field.type0); how != noChildren {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedStructs returns the plain structs holding children, in schema order.
+func (t *tree) sortedStructs(kinds []*kind) []*decl {
+	structs := make([]*decl, 0)
+	for _, kind := range kinds {
+		for _, decl := range kind.decls {
+			if !decl.node && t.hasChildren(decl) {
+				structs = append(structs, decl)
+			}
+		}
+	}
+	return structs
+}
+
+func (t *tree) walkFields(w *bytes.Buffer, n string, decl *decl) {
+	for _, field := range decl.fields {
+		value := n + "." + field.name
+		switch how, element := t.children(field.type0); how {
+		case childNode, childPointer:
+			w.WriteString("if " + value + " != nil {\nWalk(w, " + value + ")\n}\n")
+		case childNodes:
+			w.WriteString("for _, child := range " + value + " {\nWalk(w, child)\n}\n")
+		case childStruct:
+			w.WriteString("walk" + element + "(w, " + value + ")\n")
+		case childStructs:
+			w.WriteString("for _, child := range " + value + " {\nwalk" + element + "(w, child)\n}\n")
+		}
+	}
+}
+
+func (t *tree) transformFields(w *bytes.Buffer, n string, decl *decl) {
+	for _, field := range decl.fields {
+		value := n + "." + field.name
+		switch how, element := t.children(field.type0); how {
+		case childNode:
+			w.WriteString(value + " = transform" + element + "(" + value + ", f)\n")
+		case childNodes:
+			w.WriteString(value + " = transform" + element + "List(" + value + ", f)\n")
+		case childPointer:
+			w.WriteString("if " + value + " != nil {\n" + value + ", _ = Transform(" + value + ", f).(" + element + ")\n}\n")
+		case childStruct:
+			w.WriteString("transform" + element + "(&" + value + ", f)\n")
+		case childStructs:
+			w.WriteString("for index := range " + value + " {\ntransform" + element + "(&" + value + "[index], f)\n}\n")
+		}
+	}
 }
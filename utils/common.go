@@ -1,16 +1,111 @@
 package utils
 
 import (
-	log "github.com/sirupsen/logrus"
+	"fmt"
+	"io"
+	"os"
 	"strconv"
+	"strings"
+	"sync"
 )
 
+// Reporter prints diagnostics as "[line N] Error where: message" lines to its output stream, and keeps them for
+// tools which need them structured. It is safe for concurrent use.
+type Reporter struct {
+	mutex       sync.Mutex
+	out         io.Writer
+	keep        bool
+	codes       bool
+	diagnostics []Diagnostic
+}
+
+// Diagnostic is an error reported by a Reporter. Where is the part of the line it is at, like "at 'x'" or "at end".
+// Code is its code in the catalog, like "LOX-P004", `golox explain` explains it.
+type Diagnostic struct {
+	Line    int    `json:"line"`
+	Where   string `json:"where,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	Fix     *Fix   `json:"fix,omitempty"`
+}
+
+// Fix is a machine-readable correction of a diagnostic: replacing the word Old, which starts at Column on Line, with
+// New. Columns count bytes from 1.
+type Fix struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Old    string `json:"old"`
+	New    string `json:"new"`
+}
+
+// Hint phrases the fix as a question to the reader.
+func (f *Fix) Hint() string {
+	return "Did you mean '" + f.New + "'?"
+}
+
+func NewReporter(out io.Writer) *Reporter {
+	return &Reporter{out: out, keep: true}
+}
+
+// DefaultReporter writes to the process' standard error, it is used by components which were given no reporter. It
+// is shared by the whole process, so it doesn't keep the diagnostics.
+var DefaultReporter = &Reporter{out: os.Stderr}
+
+// SetCodes sets whether the printed diagnostics show their code, as in "[line 1] Error[LOX-P004] at 'x': ...".
+func (r *Reporter) SetCodes(codes bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.codes = codes
+}
+
+func (r *Reporter) RaiseError(line int, message string) {
+	r.Report(line, "", message)
+}
+
+func (r *Reporter) Report(line int, where string, message string) {
+	r.ReportFix(line, where, message, nil)
+}
+
+// ReportFix reports a diagnostic which fix, if not nil, corrects. Its hint follows the message.
+func (r *Reporter) ReportFix(line int, where string, message string, fix *Fix) {
+	r.Diagnose(Diagnostic{Line: line, Where: where, Message: message, Fix: fix})
+}
+
+// Diagnose reports diagnostic.
+func (r *Reporter) Diagnose(diagnostic Diagnostic) {
+	diagnostic.Where = strings.TrimSpace(diagnostic.Where)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.keep {
+		r.diagnostics = append(r.diagnostics, diagnostic)
+	}
+	header := "[line " + strconv.Itoa(diagnostic.Line) + "] Error"
+	if r.codes && diagnostic.Code != "" {
+		header += "[" + diagnostic.Code + "]"
+	}
+	if diagnostic.Where != "" {
+		header += " " + diagnostic.Where
+	}
+	message := diagnostic.Message
+	if diagnostic.Fix != nil {
+		message += " " + diagnostic.Fix.Hint()
+	}
+	_, _ = fmt.Fprintln(r.out, header+": "+message)
+}
+
+// Diagnostics returns the diagnostics reported so far.
+func (r *Reporter) Diagnostics() []Diagnostic {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Diagnostic(nil), r.diagnostics...)
+}
+
 func RaiseError(line int, message string) {
-	Report(line, "", message)
+	DefaultReporter.RaiseError(line, message)
 }
 
 func Report(line int, where string, message string) {
-	log.Errorln("[line " + strconv.Itoa(line) + "] Error " + where + ": " + message)
+	DefaultReporter.Report(line, where, message)
 }
 
 func InterfaceToFloat64(a interface{}) (float64, bool) {